@@ -2,9 +2,12 @@ package main
 
 import (
 	"campusDoordash/internal/auth"
+	"campusDoordash/internal/dashers"
+	"campusDoordash/internal/locations"
 	"campusDoordash/internal/orders"
 	"campusDoordash/internal/payments"
 	"campusDoordash/internal/restaurants"
+	"campusDoordash/internal/users"
 	"log"
 	"net/http"
 	"os"
@@ -22,23 +25,57 @@ func main() {
 		log.Println("hello? what is the error")
 		log.Fatal("error loading env file")
 	}
-	auth.InitDB()
+	conn := auth.InitDB()
 	payments.InitKey()
 	log.Println("stripe api key", os.Getenv("STRIPE_API_KEY"))
-	auth.SetupAuthClient()
-	restaurantService := restaurants.NewRestaurantService(auth.Conn)
+	identityProvider, tokenVerifier := setupIdentity()
+	roleCacheTTL, err := auth.LoadRoleCacheTTL()
+	if err != nil{
+		log.Fatal("invalid auth configuration: ", err)
+	}
+	roleStore := auth.NewRoleStore(conn, roleCacheTTL)
+	sessionStore := auth.NewSessionStore(conn, roleCacheTTL)
+	emailPolicy := auth.LoadEmailPolicy()
+	emailVerifications := auth.NewEmailVerifications(identityProvider, roleCacheTTL)
+	authHandlers := auth.NewAuthHandlers(identityProvider, conn, roleStore, sessionStore, emailVerifications, emailPolicy)
+	requireAuth := auth.NewAuthenticator(tokenVerifier, roleStore, sessionStore, emailVerifications, emailPolicy).Middleware()
+	campusTimezone, err := restaurants.LoadCampusTimezone()
+	if err != nil{
+		log.Fatal("invalid restaurant configuration: ", err)
+	}
+	restaurantService := restaurants.NewRestaurantService(conn, campusTimezone)
 	restaurantHandlers := restaurants.NewRestaurantHandler(restaurantService)
-	paymentService := &payments.PaymentService{Conn: auth.Conn}
-	orderService := orders.NewOrderService(auth.Conn)
+	feeRules, err := payments.LoadFeeRules()
+	if err != nil{
+		log.Fatal("invalid fee configuration: ", err)
+	}
+	pricing := payments.NewPricingEngine(feeRules)
+	locationService := locations.NewLocationService(conn)
+	locationHandlers := locations.NewLocationHandlers(locationService)
+	orderService := orders.NewOrderService(conn, restaurantService, locationService, pricing)
+	paymentService := &payments.PaymentService{
+		Conn: conn,
+		Orders: orderService,
+		Pricing: pricing,
+		WebhookSecret: os.Getenv("STRIPE_WEBHOOK_SECRET"),
+	}
 	orderHandlers := orders.NewOrderHandlers(orderService)
+	dasherHandlers := dashers.NewDasherHandlers(dashers.NewDasherService(conn, roleStore, sessionStore))
+	userHandlers := users.NewUserHandlers(users.NewUserService(conn, locationService))
 	router := gin.Default()
 	enableCors(router)
-	router.GET("/auth", auth.AuthHandler)
-	router.POST("/auth/register", auth.RegisterHandler)
-	router.POST("/auth/login", auth.LoginHandler)
-	router.POST("/auth/refresh", auth.RefreshTokenHandler)
+	router.GET("/auth", authHandlers.AuthHandler)
+	router.POST("/auth/register", authHandlers.RegisterHandler)
+	router.POST("/auth/login", authHandlers.LoginHandler)
+	router.POST("/auth/refresh", authHandlers.RefreshTokenHandler)
+	router.POST("/auth/verification/resend", authHandlers.ResendVerificationHandler)
+	router.POST("/auth/logout", requireAuth, authHandlers.LogoutHandler)
+	router.POST("/auth/logout-all", requireAuth, authHandlers.LogoutAllHandler)
+	router.POST("/auth/password/forgot", authHandlers.ForgotPasswordHandler)
+	router.POST("/auth/password/reset", authHandlers.ResetPasswordHandler)
+	router.POST("/auth/password/change", requireAuth, authHandlers.ChangePasswordHandler)
 	router.POST("/webhooks/stripe", paymentService.StripeWebhookHandle())
-	router.GET("/test-protected", auth.AuthMiddleware(), func(c *gin.Context) {
+	router.GET("/test-protected", requireAuth, func(c *gin.Context) {
 		user, _ := c.Get("user")
 		c.JSON(http.StatusOK, gin.H{
 			"message": "you are authenticated!",
@@ -48,39 +85,105 @@ func main() {
 
 	//protected api routes
 	protected := router.Group("/api")
-	protected.Use(auth.AuthMiddleware())
+	protected.Use(requireAuth)
 	{	
+		//profile routes
+		protected.GET("/me", userHandlers.GetMeHandler)
+		protected.PATCH("/me", userHandlers.UpdateMeHandler)
+		protected.GET("/me/addresses", locationHandlers.ListAddressesHandler)
+		protected.POST("/me/addresses", locationHandlers.CreateAddressHandler)
+		protected.PUT("/me/addresses/:id", locationHandlers.UpdateAddressHandler)
+		protected.DELETE("/me/addresses/:id", locationHandlers.DeleteAddressHandler)
+		//location routes
+		protected.GET("/locations", locationHandlers.ListLocationsHandler)
+		protected.GET("/locations/:id", locationHandlers.GetLocationHandler)
 		//restaurant routes
 		protected.GET("/restaurants", restaurantHandlers.GetAllRestaurantHandlers)
 
 		protected.GET("/restaurants/:id", restaurantHandlers.GetRestaurantByID)
 
 		protected.GET("/restaurants/:id/menu", restaurantHandlers.GetRestaurantMenuHandler)
+		protected.GET("/restaurants/:id/hours", restaurantHandlers.GetRestaurantHoursHandler)
+		//menu management routes
+		menuStaff := auth.RequireRole(auth.RoleRestaurantStaff, auth.RoleAdmin)
+		protected.GET("/restaurants/:id/categories", menuStaff, restaurantHandlers.ListCategoriesHandler)
+		protected.POST("/restaurants/:id/categories", menuStaff, restaurantHandlers.CreateCategoryHandler)
+		protected.PUT("/restaurants/:id/categories/order", menuStaff, restaurantHandlers.ReorderCategoriesHandler)
+		protected.PATCH("/restaurants/:id/categories/:category_id", menuStaff, restaurantHandlers.RenameCategoryHandler)
+		protected.POST("/restaurants/:id/categories/:category_id/archive", menuStaff, restaurantHandlers.ArchiveCategoryHandler)
+		protected.GET("/restaurants/:id/items", menuStaff, restaurantHandlers.ListFoodItemsHandler)
+		protected.POST("/restaurants/:id/items", menuStaff, restaurantHandlers.CreateFoodItemHandler)
+		protected.PUT("/restaurants/:id/items/order", menuStaff, restaurantHandlers.ReorderFoodItemsHandler)
+		protected.PATCH("/restaurants/:id/items/:food_id", menuStaff, restaurantHandlers.UpdateFoodItemHandler)
+		protected.PUT("/restaurants/:id/items/:food_id/price", menuStaff, restaurantHandlers.SetPriceHandler)
+		protected.PUT("/restaurants/:id/items/:food_id/availability", menuStaff, restaurantHandlers.SetAvailabilityHandler)
+		protected.POST("/restaurants/:id/items/:food_id/archive", menuStaff, restaurantHandlers.ArchiveFoodItemHandler)
+		protected.GET("/restaurants/:id/menu/changes", menuStaff, restaurantHandlers.ListMenuChangesHandler)
 		//order routes
-		protected.POST("/orders", orderHandlers.CreateOrderHandler)
+		protected.POST("/orders", auth.RequireRole(auth.RoleCustomer), orderHandlers.CreateOrderHandler)
 		protected.GET("/orders/:id", orderHandlers.GetOrderByIDHandler)
+		protected.GET("/orders/:id/timeline", orderHandlers.GetOrderTimelineHandler)
 		protected.GET("/customers/:customer_id/orders", orderHandlers.GetCustomerOrdersHandler)
-		protected.GET("/restaurants/:id/orders", orderHandlers.GetRestaurantOrdersHandlers)
-		protected.POST("/orders/:id/status", orderHandlers.UpdateOrderStatusHandler)
-		protected.POST("/orders/:id/dasher", orderHandlers.AssignDasherHandler)
+		protected.GET("/restaurants/:id/orders", auth.RequireRole(auth.RoleRestaurantStaff, auth.RoleAdmin), orderHandlers.GetRestaurantOrdersHandlers)
+		protected.POST("/orders/:id/status", auth.RequireRole(auth.RoleDasher, auth.RoleRestaurantStaff, auth.RoleAdmin), orderHandlers.UpdateOrderStatusHandler)
+		protected.POST("/orders/:id/dasher", auth.RequireRole(auth.RoleAdmin), orderHandlers.AssignDasherHandler)
+		protected.POST("/orders/:id/tip", auth.RequireRole(auth.RoleCustomer), orderHandlers.AddTipHandler)
+		protected.POST("/orders/:id/cancel", orderHandlers.CancelOrderHandler)
 		//dasher routes	
-		protected.GET("/dashers/orders/available", orderHandlers.GetAvailableOrdersHandler)
-		protected.POST("dashers/orders/accept/:id", orderHandlers.AcceptOrderHandler)
-		protected.GET("dashers/orders/active", orderHandlers.GetDasherOrdersHandler)
+		protected.POST("/dashers/application", dasherHandlers.ApplyHandler)
+		protected.GET("/dashers/application", dasherHandlers.GetMyApplicationHandler)
+		protected.GET("/dashers/orders/available", auth.RequireRole(auth.RoleDasher), orderHandlers.GetAvailableOrdersHandler)
+		protected.POST("dashers/orders/accept/:id", auth.RequireRole(auth.RoleDasher), orderHandlers.AcceptOrderHandler)
+		protected.GET("dashers/orders/active", auth.RequireRole(auth.RoleDasher), orderHandlers.GetDasherOrdersHandler)
+		protected.GET("/dashers/earnings", auth.RequireRole(auth.RoleDasher), orderHandlers.GetDasherEarningsHandler)
 
 		protected.GET("/customers/orders/history", orderHandlers.GetHistory)
-		protected.POST("/dashers/orders/:id/complete", orderHandlers.CompleteOrderHandler)
+		protected.POST("/dashers/orders/:id/complete", auth.RequireRole(auth.RoleDasher), orderHandlers.CompleteOrderHandler)
+
+	}
 
+	//admin routes
+	admin := router.Group("/admin")
+	admin.Use(requireAuth, auth.RequireRole(auth.RoleAdmin))
+	{
+		admin.GET("/webhooks/events", paymentService.ListWebhookEventsHandler)
+		admin.POST("/users/:id/logout", authHandlers.ForceLogoutHandler)
+		admin.GET("/dashers/applications", dasherHandlers.ListApplicationsHandler)
+		admin.POST("/dashers/:id/approve", dasherHandlers.ReviewHandler(dashers.DecisionApprove))
+		admin.POST("/dashers/:id/reject", dasherHandlers.ReviewHandler(dashers.DecisionReject))
+		admin.POST("/dashers/:id/suspend", dasherHandlers.ReviewHandler(dashers.DecisionSuspend))
 	}
 	router.Run(":8080")
 }
 
+// setupIdentity picks the identity provider. AUTH_PROVIDER=memory keeps
+// accounts in memory for local development; anything else uses Supabase.
+func setupIdentity() (auth.IdentityProvider, auth.TokenVerifier) {
+	if os.Getenv("AUTH_PROVIDER") == "memory" {
+		secret := os.Getenv("AUTH_MEMORY_SECRET")
+		if secret == "" {
+			secret = "local-development-secret"
+		}
+		provider := auth.NewMemoryProvider([]byte(secret))
+		provider.AutoConfirm = true
+		log.Println("using in-memory identity provider")
+		return provider, provider.Verifier()
+	}
+
+	provider, err := auth.NewSupabaseProvider(os.Getenv("DB_URL"), os.Getenv("DB_API_KEY"))
+	if err != nil {
+		log.Fatal("failed to Connect to supabase client", err)
+	}
+	log.Println("supabase client initialized")
+	return provider, auth.LoadTokenVerifier()
+}
+
 func enableCors(e *gin.Engine) {
 	e.Use(cors.New(cors.Config{
 		AllowOrigins:     []string{"*"},
 		AllowMethods:     []string{"GET", "POST", "PUT", "PATCH", "DELETE", "OPTIONS"},
-		AllowHeaders:     []string{"Origin", "Content-Type", "Authorization"},
-		ExposeHeaders:    []string{"Content-Length"},
+		AllowHeaders:     []string{"Origin", "Content-Type", "Authorization", "Idempotency-Key"},
+		ExposeHeaders:    []string{"Content-Length", "Idempotent-Replayed"},
 		AllowCredentials: true,
 		MaxAge:           12 * time.Hour,
 	}))
@@ -0,0 +1,85 @@
+// Command menuimport loads DineOnCampus JSON exports into the database.
+//
+// It reads locations.json and menus/*.json from -dir, prints what would be
+// created or changed, and writes it unless -dry-run is set. Rows are keyed
+// by their DineOnCampus IDs, so running it again with the same files is a
+// no-op. Rows the data_retrieval scripts created, which have no DineOnCampus
+// ID, are matched by name (within their restaurant, for categories and food)
+// and tagged on the first run instead of being duplicated. Menu changes are
+// audited under -editor, or a fixed importer ID without one. A dry run
+// without DB_STRING diffs against an empty database.
+//
+//	go run ./cmd/menuimport -dir cmd/menuimport/testdata -dry-run
+package main
+
+import (
+	"campusDoordash/internal/menuimport"
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/joho/godotenv"
+)
+
+func main() {
+	dir := flag.String("dir", "", "directory with locations.json and menus/*.json")
+	dryRun := flag.Bool("dry-run", false, "print the changes without writing them")
+	editor := flag.String("editor", "", "user ID of the admin running the import, recorded in menu_changes")
+	flag.Parse()
+	if *dir == "" {
+		flag.Usage()
+		os.Exit(2)
+	}
+	editorID := menuimport.ImporterID
+	if *editor != "" {
+		id, err := uuid.Parse(*editor)
+		if err != nil {
+			log.Fatal("invalid -editor: ", err)
+		}
+		editorID = id
+	}
+
+	// the env file is optional here, DB_STRING may come from the environment
+	_ = godotenv.Load()
+
+	catalog, err := menuimport.Load(*dir)
+	if err != nil {
+		log.Fatal("failed to read import files: ", err)
+	}
+
+	ctx := context.Background()
+	connStr := os.Getenv("DB_STRING")
+	if connStr == "" {
+		if !*dryRun {
+			log.Fatal("DB_STRING is not set")
+		}
+		fmt.Println("DB_STRING is not set, comparing against an empty database")
+		menuimport.Diff(catalog, menuimport.EmptySnapshot()).Write(os.Stdout)
+		return
+	}
+
+	conn, err := pgxpool.New(ctx, connStr)
+	if err != nil {
+		log.Fatal("failed to connect to database: ", err)
+	}
+	defer conn.Close()
+
+	existing, err := menuimport.LoadSnapshot(ctx, conn, catalog)
+	if err != nil {
+		log.Fatal(err)
+	}
+	plan := menuimport.Diff(catalog, existing)
+	plan.Write(os.Stdout)
+
+	if *dryRun || len(plan.Changes) == 0 {
+		return
+	}
+	if err := menuimport.Apply(ctx, conn, catalog, plan, editorID); err != nil {
+		log.Fatal("import failed, nothing was written: ", err)
+	}
+	fmt.Printf("applied %d changes\n", len(plan.Changes))
+}
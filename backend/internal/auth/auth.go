@@ -3,18 +3,39 @@ package auth
 
 import (
 	"context"
+	"errors"
+	"io"
 	"log"
 	"net/http"
 	"os"
 
 	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
 	"github.com/jackc/pgx/v5/pgxpool"
-	"github.com/supabase-community/gotrue-go/types"
-	"github.com/supabase-community/supabase-go"
 )
 
-var Conn * pgxpool.Pool
-var supabaseClient *supabase.Client
+// AuthHandlers serves the sign up, login and token refresh endpoints.
+type AuthHandlers struct{
+	provider IdentityProvider
+	conn * pgxpool.Pool
+	roles * RoleStore
+	sessions * SessionStore
+	verifications * EmailVerifications
+	emails EmailPolicy
+	limits passwordLimits
+}
+
+func NewAuthHandlers(provider IdentityProvider, conn *pgxpool.Pool, roles *RoleStore, sessions *SessionStore, verifications *EmailVerifications, emails EmailPolicy) *AuthHandlers{
+	return &AuthHandlers{
+		provider: provider,
+		conn: conn,
+		roles: roles,
+		sessions: sessions,
+		verifications: verifications,
+		emails: emails,
+		limits: newPasswordLimits(),
+	}
+}
 
 type AuthRequest struct {
 	Email    string `json:"email" binding:"required,email"`
@@ -22,7 +43,7 @@ type AuthRequest struct {
 	IsDasher bool	`json:"is_dasher"`	
 }
 
-func RefreshTokenHandler(c *gin.Context) {
+func (h *AuthHandlers) RefreshTokenHandler(c *gin.Context) {
 	type RefreshRequest struct {
 		RefreshToken string `json:"refresh_token" binding:"required"`
 	}
@@ -37,7 +58,7 @@ func RefreshTokenHandler(c *gin.Context) {
 		return
 	}
 
-	tokenResp, err := supabaseClient.Auth.RefreshToken(req.RefreshToken)
+	tokenResp, err := h.provider.Refresh(c.Request.Context(), req.RefreshToken)
 	if err != nil {
 		log.Printf("token refresh failed %v", err)
 		c.JSON(http.StatusUnauthorized, gin.H{
@@ -47,6 +68,25 @@ func RefreshTokenHandler(c *gin.Context) {
 		return
 	}
 
+	// sessions that started before the user was signed out everywhere must
+	// not keep refreshing; sessions started since then are left alone
+	revoked, err := h.sessions.SessionRevoked(c.Request.Context(), tokenResp.User.ID, tokenResp.SessionID)
+	if err != nil {
+		log.Printf("failed to check session revocation %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to refresh token"})
+		return
+	}
+	if revoked {
+		if err := h.provider.SignOut(c.Request.Context(), tokenResp.AccessToken, SignOutLocal); err != nil {
+			log.Printf("failed to sign out revoked session %v", err)
+		}
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"error":   "this session has been signed out",
+			"message": "please login again",
+		})
+		return
+	}
+
 	c.JSON(http.StatusOK, gin.H{
 		"message":       "Token refreshed successfully",
 		"access_token":  tokenResp.AccessToken,
@@ -56,7 +96,29 @@ func RefreshTokenHandler(c *gin.Context) {
 	})
 }
 
-func AuthMiddleware() gin.HandlerFunc {
+// Authenticator checks every API request's bearer token and who it belongs to.
+type Authenticator struct{
+	verifier TokenVerifier
+	roles RoleSource
+	sessions RevocationSource
+	verifications VerificationSource
+	emails EmailPolicy
+}
+
+// NewAuthenticator verifies tokens locally with verifier and loads the
+// caller's roles from roles on every request, so a revoked role or a
+// suspended dasher loses access once the role cache expires. Tokens from
+// sessions that were revoked are rejected, and whether
+// the email address is confirmed comes from verifications rather than the
+// token. Tests can pass an HMACVerifier with their own key to run without
+// Supabase.
+func NewAuthenticator(verifier TokenVerifier, roles RoleSource, sessions RevocationSource, verifications VerificationSource, emails EmailPolicy) *Authenticator{
+	return &Authenticator{verifier: verifier, roles: roles, sessions: sessions, verifications: verifications, emails: emails}
+}
+
+// Middleware rejects requests without a valid token, and with a 403 those
+// from accounts outside the allowed email domains or not yet verified.
+func (a *Authenticator) Middleware() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		authHeader := c.GetHeader("Authorization")
 		//check if there is a header
@@ -79,9 +141,9 @@ func AuthMiddleware() gin.HandlerFunc {
 		}
 		//verify token
 		token := authHeader[7:]
-		authedClient := supabaseClient.Auth.WithToken(token)
-		userResp, err := authedClient.GetUser()
-		if err != nil || userResp == nil {
+		identity, err := a.verifier.Verify(c.Request.Context(), token)
+		if err != nil {
+			log.Printf("token verification failed: %v", err)
 			c.JSON(http.StatusUnauthorized, gin.H{
 				"error": "invalid or expired token",
 			})
@@ -89,30 +151,81 @@ func AuthMiddleware() gin.HandlerFunc {
 			return
 		}
 
-		var isDasher bool
-		err = Conn.QueryRow(context.Background(), "SELECT EXISTS(SELECT 1 FROM dashers WHERE dasher_id = $1)", userResp.User.ID).Scan(&isDasher)
+		// checked per session rather than by iat: a revoked session's refresh
+		// token still gets fresh access tokens straight from the provider
+		revoked, err := a.sessions.SessionRevoked(c.Request.Context(), identity.UserID, identity.SessionID)
+		if err != nil{
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to verify session"})
+			c.Abort()
+			return
+		}
+		if revoked{
+			c.JSON(http.StatusUnauthorized, gin.H{
+				"error": "this session has been signed out",
+				"code": "session_revoked",
+			})
+			c.Abort()
+			return
+		}
+
+		if !a.emails.Allows(identity.Email){
+			c.JSON(http.StatusForbidden, gin.H{
+				"error": "this service is only available to campus email addresses",
+				"code": "email_domain_not_allowed",
+			})
+			c.Abort()
+			return
+		}
+
+		identity.EmailVerified, err = a.verifications.EmailVerified(c.Request.Context(), identity.UserID, token)
+		if errors.Is(err, ErrInvalidToken){
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid or expired token"})
+			c.Abort()
+			return
+		}
+		if err != nil{
+			log.Printf("failed to check email verification: %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to verify email address"})
+			c.Abort()
+			return
+		}
+		if !identity.EmailVerified{
+			c.JSON(http.StatusForbidden, gin.H{
+				"error": "verify your email address before using your account",
+				"code": "email_not_verified",
+			})
+			c.Abort()
+			return
+		}
+
+		identity.Roles, err = a.roles.Roles(c.Request.Context(), identity.UserID)
 		if err != nil{
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to verify user type"})
 			c.Abort()
 			return
 		}
 
-		c.Set("user", userResp.User)
-		c.Set("user_id", userResp.User.ID.String())
-		c.Set("is_dasher", isDasher)
+		c.Set("user", identity)
+		c.Set("access_token", token)
+		c.Set("user_id", identity.UserID.String())
+		c.Set("roles", identity.Roles)
 
-		log.Printf("Authenticated user_id: %v | is_dasher: %v", userResp.User.ID, isDasher)
 		c.Next()
 	}
 }
 
-func AuthHandler(c *gin.Context) {
-	log.Println("Connecting to db")
-	InitDB()
-	SetupAuthClient()
+// AuthHandler serves GET /auth, reporting whether the auth service can reach
+// its database.
+func (h *AuthHandlers) AuthHandler(c *gin.Context) {
+	if err := h.conn.Ping(c.Request.Context()); err != nil {
+		log.Printf("auth database unreachable %v", err)
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "auth service unavailable"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "auth service ready"})
 }
 
-func LoginHandler(c *gin.Context) {
+func (h *AuthHandlers) LoginHandler(c *gin.Context) {
 	var req AuthRequest
 	//you have to bind json inline
 	if err := c.ShouldBindJSON(&req); err != nil {
@@ -120,30 +233,43 @@ func LoginHandler(c *gin.Context) {
 		return
 	}
 
-	user, err := supabaseClient.Auth.SignInWithEmailPassword(req.Email, req.Password)
+	user, err := h.provider.SignIn(c.Request.Context(), req.Email, req.Password)
 	if err != nil {
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid credentials"})
 		return
 	}
-	
-	var exists bool 
+
+	roles, err := h.roles.Roles(c.Request.Context(), user.User.ID)
+	wanted := RoleCustomer
 	if req.IsDasher{
-		err = Conn.QueryRow(context.Background(), 
-			"SELECT EXISTS(SELECT 1 FROM dashers WHERE dasher_id = $1)", user.User.ID).Scan(&exists)	
-	}else{
-		
-		err = Conn.QueryRow(context.Background(), 
-			"SELECT EXISTS(SELECT 1 FROM users WHERE user_id = $1)", user.User.ID).Scan(&exists)	
+		wanted = RoleDasher
 	}
 
-	if err != nil || !exists{
+	// the provider has started a session either way; end the ones that
+	// aren't handed out so they don't stay live behind a refresh token
+	if err != nil || !roles.Has(wanted){
+		if err := h.provider.SignOut(c.Request.Context(), user.AccessToken, SignOutLocal); err != nil {
+			log.Printf("failed to end rejected session %v", err)
+		}
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "no matching account found"})	
 		return
 	}
 
+	// an unrecorded session would be treated as revoked after the next
+	// revocation, so don't hand it out
+	if err := h.sessions.RecordSignIn(c.Request.Context(), user.User.ID, user.SessionID); err != nil {
+		log.Printf("failed to record sign in %v", err)
+		if err := h.provider.SignOut(c.Request.Context(), user.AccessToken, SignOutLocal); err != nil {
+			log.Printf("failed to end unrecorded session %v", err)
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to sign in"})
+		return
+	}
+
 	c.JSON(http.StatusOK, gin.H{
 		"message":       "login good",
 		"is_dasher": 	 req.IsDasher,
+		"roles":         roles,
 		"user":          user,
 		"access token":  user.AccessToken,
 		"refresh_token": user.RefreshToken,
@@ -151,7 +277,7 @@ func LoginHandler(c *gin.Context) {
 	})
 }
 
-func RegisterHandler(c *gin.Context) {
+func (h *AuthHandlers) RegisterHandler(c *gin.Context) {
 	var req AuthRequest
 
 	if err := c.ShouldBindJSON(&req); err != nil {
@@ -159,78 +285,152 @@ func RegisterHandler(c *gin.Context) {
 		return
 	}
 
-	var err error
-	signupReq := types.SignupRequest{
-		Email:    req.Email,
-		Password: req.Password,
+	if !h.emails.Allows(req.Email){
+		c.JSON(http.StatusForbidden, gin.H{
+			"error": "registration is limited to campus email addresses",
+			"allowed_domains": h.emails.Domains,
+		})
+		return
 	}
 
-	user, err := supabaseClient.Auth.Signup(signupReq)
+	user, err := h.provider.SignUp(c.Request.Context(), req.Email, req.Password)
 	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 	
-	supabaseUserID := user.ID
-	log.Println("Supabase User ID:", supabaseUserID)
-
-	if req.IsDasher{
-		
-		_,err = Conn.Exec(context.Background(), "INSERT INTO dashers (dasher_id, email) VALUES ($1, $2)",
-			supabaseUserID, 
-			req.Email,
-		)
-
-		if err != nil{	
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to create dasher record"})
-			return
-		}
+	userID := user.ID
+	log.Println("registered user ID:", userID)
 
-	}else{
-		_,err = Conn.Exec(context.Background(), "INSERT INTO users (user_id, email) VALUES ($1, $2)",
-			supabaseUserID, 
-			req.Email,
-		)
+	// every account can order food; delivering needs an approved dasher application
+	_,err = h.conn.Exec(c.Request.Context(), "INSERT INTO users (user_id, email) VALUES ($1, $2)",
+		userID, 
+		req.Email,
+	)
 
-		if err != nil{	
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to create user record"})
-			return
-		}
+	if err == nil{
+		err = h.roles.Grant(c.Request.Context(), userID, RoleCustomer, nil)
+	}
+	if err != nil{	
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to create user record"})
+		return
 	}
 
 	c.JSON(http.StatusCreated, gin.H{
 		"message": "user created successfulty",
 		"user":    user,
+		"verification_required": !user.EmailVerified,
+		"dasher_application_required": req.IsDasher,
 	})
 }
 
-func SetupAuthClient() {
-	var err error
-	supabaseClient, err = supabase.NewClient(
-		os.Getenv("DB_URL"),
-		os.Getenv("DB_API_KEY"),
-		&supabase.ClientOptions{},
-	)
+// LogoutHandler serves POST /auth/logout. It ends the caller's current
+// session; the access token keeps working until it expires.
+func (h *AuthHandlers) LogoutHandler(c *gin.Context) {
+	if err := h.provider.SignOut(c.Request.Context(), c.GetString("access_token"), SignOutLocal); err != nil {
+		log.Printf("logout failed %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to log out"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "logged out"})
+}
+
+// LogoutAllHandler serves POST /auth/logout-all. Every session the caller has,
+// on any device, stops working immediately.
+func (h *AuthHandlers) LogoutAllHandler(c *gin.Context) {
+	userID, err := uuid.Parse(c.GetString("user_id"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "invalid user id"})
+		return
+	}
+
+	if err := h.sessions.RevokeAll(c.Request.Context(), userID, nil, "logout_all"); err != nil {
+		log.Printf("failed to revoke sessions %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to log out"})
+		return
+	}
+	if err := h.provider.SignOut(c.Request.Context(), c.GetString("access_token"), SignOutGlobal); err != nil {
+		log.Printf("provider sign out failed %v", err)
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "logged out of all devices"})
+}
 
+// ForceLogoutHandler serves POST /admin/users/:id/logout, signing a user out
+// of every session. The provider is not told, since that needs one of the
+// user's own tokens; their refresh tokens may still be exchanged there, but
+// every token those sessions get is rejected here and by /auth/refresh.
+func (h *AuthHandlers) ForceLogoutHandler(c *gin.Context) {
+	userID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid user id"})
+		return
+	}
+	adminID, err := uuid.Parse(c.GetString("user_id"))
 	if err != nil {
-		log.Fatal("failed to Connect to supabase client", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "invalid user id"})
+		return
+	}
+
+	var req struct {
+		Reason string `json:"reason"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil && !errors.Is(err, io.EOF) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request body"})
+		return
+	}
+	if req.Reason == "" {
+		req.Reason = "admin_logout"
+	}
+
+	if err := h.sessions.RevokeAll(c.Request.Context(), userID, &adminID, req.Reason); err != nil {
+		log.Printf("failed to revoke sessions %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to log user out"})
+		return
 	}
-	log.Println("supabase client initialized")
+
+	c.JSON(http.StatusOK, gin.H{"message": "user logged out of all sessions", "user_id": userID})
+}
+
+// ResendVerificationHandler sends a new confirmation email. The response is
+// the same whether or not the address has an unverified account.
+func (h *AuthHandlers) ResendVerificationHandler(c *gin.Context) {
+	var req struct {
+		Email string `json:"email" binding:"required,email"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "a valid email is required"})
+		return
+	}
+
+	if h.emails.Allows(req.Email){
+		// the new link may be followed right away
+		h.verifications.Forget(req.Email)
+		if err := h.provider.ResendVerification(c.Request.Context(), req.Email); err != nil{
+			log.Printf("failed to resend verification email: %v", err)
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "if that address has an unverified account, a new verification email is on its way",
+	})
 }
 
-func InitDB() {
+// InitDB connects to the database at DB_STRING.
+func InitDB() *pgxpool.Pool {
 	ConnStr := os.Getenv("DB_STRING")
 
-	var err error
-	Conn, err = pgxpool.New(context.Background(), ConnStr)
+	conn, err := pgxpool.New(context.Background(), ConnStr)
 	if err != nil {
 		log.Fatal("failed to Connect to database", err)
 	}
 
 	var version string
-	if err := Conn.QueryRow(context.Background(), "SELECT version()").Scan(&version); err != nil {
+	if err := conn.QueryRow(context.Background(), "SELECT version()").Scan(&version); err != nil {
 		log.Fatal("Query failed:", err)
 	}
 
 	log.Println("Connected to database!")
+	return conn
 }
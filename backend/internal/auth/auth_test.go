@@ -0,0 +1,143 @@
+package auth
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+)
+
+// fakeRoles serves roles from a map, as the RoleStore would after a grant or revoke.
+type fakeRoles map[uuid.UUID]Roles
+
+func (f fakeRoles) Roles(ctx context.Context, userID uuid.UUID) (Roles, error) {
+	return f[userID], nil
+}
+
+// noRevocations never reports a revoked session.
+type noRevocations struct{}
+
+func (noRevocations) SessionRevoked(ctx context.Context, userID uuid.UUID, sessionID string) (bool, error) {
+	return false, nil
+}
+
+// verified treats every account as confirmed.
+type verified struct{}
+
+func (verified) EmailVerified(ctx context.Context, userID uuid.UUID, accessToken string) (bool, error) {
+	return true, nil
+}
+
+// serve runs the Authenticator for one request and returns the response
+// and the roles the handler saw.
+func serve(a *Authenticator, token string) (*httptest.ResponseRecorder, Roles) {
+	gin.SetMode(gin.TestMode)
+	var seen Roles
+	router := gin.New()
+	router.GET("/me", a.Middleware(), func(c *gin.Context) {
+		seen = RolesFrom(c)
+		c.Status(http.StatusOK)
+	})
+
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/me", nil)
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	router.ServeHTTP(w, req)
+	return w, seen
+}
+
+func TestMiddlewareLoadsRolesFromTheStore(t *testing.T) {
+	userID := uuid.New()
+	roles := fakeRoles{userID: Roles{{Role: RoleDasher}}}
+	a := NewAuthenticator(NewHMACVerifier(testSecret), roles, noRevocations{}, verified{}, EmailPolicy{Domains: []string{"umbc.edu"}})
+
+	claims := testClaims(userID)
+	// a token minted while the user was an admin, or with an empty list
+	claims["app_metadata"] = map[string]any{"roles": []map[string]any{{"role": "admin"}}}
+	token := sign(t, jwt.SigningMethodHS256, claims, testSecret, "")
+
+	w, seen := serve(a, token)
+	if w.Code != http.StatusOK {
+		t.Fatalf("status %d: %s", w.Code, w.Body)
+	}
+	if !seen.Has(RoleDasher) || seen.Has(RoleAdmin) {
+		t.Errorf("roles = %v, want only the stored dasher role", seen)
+	}
+
+	// revoking the role takes effect without a new token
+	roles[userID] = Roles{}
+	if _, seen := serve(a, token); seen.Has(RoleDasher) {
+		t.Errorf("roles = %v after the dasher role was revoked", seen)
+	}
+}
+
+func TestMiddlewareRejectsBadTokens(t *testing.T) {
+	a := NewAuthenticator(NewHMACVerifier(testSecret), fakeRoles{}, noRevocations{}, verified{}, EmailPolicy{Domains: []string{"umbc.edu"}})
+
+	forged := sign(t, jwt.SigningMethodHS256, testClaims(uuid.New()), []byte("someone-elses-secret"), "")
+	for name, token := range map[string]string{"no token": "", "forged": forged} {
+		if w, _ := serve(a, token); w.Code != http.StatusUnauthorized {
+			t.Errorf("%s: status %d, want 401", name, w.Code)
+		}
+	}
+}
+
+func TestMiddlewareChecksVerificationWithTheProvider(t *testing.T) {
+	ctx := context.Background()
+	p := NewMemoryProvider(testSecret)
+	user, err := p.SignUp(ctx, "student@umbc.edu", "hunter22")
+	if err != nil {
+		t.Fatal(err)
+	}
+	counted := &countingProvider{IdentityProvider: p}
+	verifications := NewEmailVerifications(counted, time.Minute)
+	a := NewAuthenticator(p.Verifier(), fakeRoles{}, noRevocations{}, verifications, EmailPolicy{Domains: []string{"umbc.edu"}})
+
+	// users can set user_metadata themselves, so claiming it proves nothing
+	claims := testClaims(user.ID)
+	claims["user_metadata"] = map[string]any{"email_verified": true}
+	if w, _ := serve(a, sign(t, jwt.SigningMethodHS256, claims, testSecret, "")); w.Code != http.StatusForbidden {
+		t.Fatalf("self-verified token: status %d, want 403", w.Code)
+	}
+
+	session, err := p.SignIn(ctx, "student@umbc.edu", "hunter22")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 3; i++ {
+		if w, _ := serve(a, session.AccessToken); w.Code != http.StatusForbidden {
+			t.Fatalf("unverified account: status %d, want 403", w.Code)
+		}
+	}
+	// a client retrying in a loop doesn't cost a provider call each time
+	if counted.calls != 1 {
+		t.Errorf("%d provider calls for 4 requests, want 1", counted.calls)
+	}
+
+	// the unconfirmed answer is kept briefly; asking for a new email drops
+	// it, and the account then works even with a token issued beforehand
+	token, _ := p.VerificationToken("student@umbc.edu")
+	p.ConfirmEmail(token)
+	verifications.Forget("Student@UMBC.edu")
+	if w, _ := serve(a, session.AccessToken); w.Code != http.StatusOK {
+		t.Fatalf("confirmed account: status %d: %s", w.Code, w.Body)
+	}
+}
+
+// countingProvider counts the GetUser round trips made to the provider.
+type countingProvider struct {
+	IdentityProvider
+	calls int
+}
+
+func (p *countingProvider) GetUser(ctx context.Context, accessToken string) (*User, error) {
+	p.calls++
+	return p.IdentityProvider.GetUser(ctx, accessToken)
+}
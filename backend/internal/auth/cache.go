@@ -0,0 +1,73 @@
+package auth
+
+import (
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// ttlCache keeps one value per user (or session) ID for a fixed time.
+// Expired entries are dropped when read and swept out once per TTL, so the
+// map only holds IDs seen within about the last two TTLs.
+type ttlCache[V any] struct {
+	ttl time.Duration
+
+	mu      sync.Mutex
+	entries map[uuid.UUID]cacheEntry[V]
+	swept   time.Time
+}
+
+type cacheEntry[V any] struct {
+	value     V
+	expiresAt time.Time
+}
+
+func newTTLCache[V any](ttl time.Duration) *ttlCache[V] {
+	return &ttlCache[V]{ttl: ttl, entries: map[uuid.UUID]cacheEntry[V]{}}
+}
+
+func (c *ttlCache[V]) get(id uuid.UUID) (V, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	entry, ok := c.entries[id]
+	if !ok || !time.Now().Before(entry.expiresAt) {
+		delete(c.entries, id)
+		var zero V
+		return zero, false
+	}
+	return entry.value, true
+}
+
+func (c *ttlCache[V]) set(id uuid.UUID, value V) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := time.Now()
+	if now.Sub(c.swept) >= c.ttl {
+		for k, entry := range c.entries {
+			if !now.Before(entry.expiresAt) {
+				delete(c.entries, k)
+			}
+		}
+		c.swept = now
+	}
+	c.entries[id] = cacheEntry[V]{value: value, expiresAt: now.Add(c.ttl)}
+}
+
+func (c *ttlCache[V]) invalidate(id uuid.UUID) {
+	c.mu.Lock()
+	delete(c.entries, id)
+	c.mu.Unlock()
+}
+
+// invalidateWhere drops every entry whose value matches.
+func (c *ttlCache[V]) invalidateWhere(match func(V) bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for id, entry := range c.entries {
+		if match(entry.value) {
+			delete(c.entries, id)
+		}
+	}
+}
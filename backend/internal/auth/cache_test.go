@@ -0,0 +1,33 @@
+package auth
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func TestTTLCacheDropsExpiredEntries(t *testing.T) {
+	c := newTTLCache[bool](20 * time.Millisecond)
+	ids := make([]uuid.UUID, 100)
+	for i := range ids {
+		ids[i] = uuid.New()
+		c.set(ids[i], true)
+	}
+	if _, ok := c.get(ids[0]); !ok {
+		t.Fatal("a fresh entry was missing")
+	}
+
+	time.Sleep(30 * time.Millisecond)
+	if _, ok := c.get(ids[0]); ok {
+		t.Fatal("an expired entry was returned")
+	}
+	// one new session is enough to sweep out everything that expired
+	c.set(uuid.New(), true)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if len(c.entries) != 1 {
+		t.Errorf("%d entries left, want only the new one", len(c.entries))
+	}
+}
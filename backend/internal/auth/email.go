@@ -0,0 +1,49 @@
+package auth
+
+import (
+	"os"
+	"strings"
+)
+
+// DefaultEmailDomains are used when ALLOWED_EMAIL_DOMAINS is not set.
+var DefaultEmailDomains = []string{"umbc.edu"}
+
+// EmailPolicy limits accounts to campus email addresses. An address is
+// allowed if its domain is one of Domains or a subdomain of one, so
+// umbc.edu also allows gl.umbc.edu. A "*" entry allows any domain.
+type EmailPolicy struct {
+	Domains []string
+}
+
+// LoadEmailPolicy reads ALLOWED_EMAIL_DOMAINS, a comma separated list such as
+// "umbc.edu,gl.umbc.edu".
+func LoadEmailPolicy() EmailPolicy {
+	v := os.Getenv("ALLOWED_EMAIL_DOMAINS")
+	if v == "" {
+		return EmailPolicy{Domains: DefaultEmailDomains}
+	}
+
+	var domains []string
+	for _, d := range strings.Split(v, ",") {
+		if d = strings.ToLower(strings.TrimSpace(d)); d != "" {
+			domains = append(domains, strings.TrimPrefix(d, "@"))
+		}
+	}
+	return EmailPolicy{Domains: domains}
+}
+
+// Allows reports whether email belongs to one of the allowed domains.
+func (p EmailPolicy) Allows(email string) bool {
+	at := strings.LastIndex(email, "@")
+	if at < 0 {
+		return false
+	}
+	domain := strings.ToLower(email[at+1:])
+
+	for _, d := range p.Domains {
+		if d == "*" || domain == d || strings.HasSuffix(domain, "."+d) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,297 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// memoryTokenTTL is how long access tokens issued by MemoryProvider last.
+const memoryTokenTTL = time.Hour
+
+// MemoryProvider is an IdentityProvider that keeps accounts in memory and
+// signs its own HS256 access tokens, so tests and local development can run
+// without Supabase. Pair it with Verifier() in AuthMiddleware. New accounts
+// must confirm their email with ConfirmEmail unless AutoConfirm is set.
+type MemoryProvider struct {
+	secret []byte
+
+	AutoConfirm bool
+
+	mu            sync.Mutex
+	users         map[string]*memoryUser // by lower-cased email
+	refreshTokens map[string]memorySession
+	resetTokens   map[string]string // email -> token
+	verifyTokens  map[string]string // email -> token
+}
+
+type memoryUser struct {
+	User
+	passwordHash []byte
+}
+
+type memorySession struct {
+	userID    uuid.UUID
+	sessionID string
+}
+
+// NewMemoryProvider creates an empty provider that signs tokens with secret.
+func NewMemoryProvider(secret []byte) *MemoryProvider {
+	return &MemoryProvider{
+		secret:        secret,
+		users:         map[string]*memoryUser{},
+		refreshTokens: map[string]memorySession{},
+		resetTokens:   map[string]string{},
+		verifyTokens:  map[string]string{},
+	}
+}
+
+// Verifier returns a TokenVerifier that accepts this provider's access tokens.
+func (p *MemoryProvider) Verifier() TokenVerifier {
+	return NewHMACVerifier(p.secret)
+}
+
+func (p *MemoryProvider) SignUp(ctx context.Context, email, password string) (*User, error) {
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.MinCost)
+	if err != nil {
+		return nil, err
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	key := strings.ToLower(email)
+	if _, ok := p.users[key]; ok {
+		return nil, ErrEmailTaken
+	}
+	u := &memoryUser{User: User{ID: uuid.New(), Email: email, EmailVerified: p.AutoConfirm}, passwordHash: hash}
+	if !u.EmailVerified {
+		token, err := randomToken()
+		if err != nil {
+			return nil, err
+		}
+		p.verifyTokens[key] = token
+	}
+	p.users[key] = u
+	return &u.User, nil
+}
+
+func (p *MemoryProvider) SignIn(ctx context.Context, email, password string) (*Session, error) {
+	p.mu.Lock()
+	u, ok := p.users[strings.ToLower(email)]
+	p.mu.Unlock()
+	if !ok || bcrypt.CompareHashAndPassword(u.passwordHash, []byte(password)) != nil {
+		return nil, ErrInvalidCredentials
+	}
+	return p.newSession(u.User, uuid.NewString())
+}
+
+func (p *MemoryProvider) Refresh(ctx context.Context, refreshToken string) (*Session, error) {
+	p.mu.Lock()
+	session, ok := p.refreshTokens[refreshToken]
+	delete(p.refreshTokens, refreshToken)
+	var user *User
+	for _, u := range p.users {
+		if u.ID == session.userID {
+			user = &u.User
+		}
+	}
+	p.mu.Unlock()
+
+	if !ok || user == nil {
+		return nil, ErrInvalidRefreshToken
+	}
+	return p.newSession(*user, session.sessionID)
+}
+
+// GetUser returns the account as stored, so EmailVerified reflects
+// ConfirmEmail even for tokens issued before it.
+func (p *MemoryProvider) GetUser(ctx context.Context, accessToken string) (*User, error) {
+	identity, err := p.Verifier().Verify(ctx, accessToken)
+	if err != nil {
+		return nil, err
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for _, u := range p.users {
+		if u.ID == identity.UserID {
+			user := u.User
+			return &user, nil
+		}
+	}
+	return nil, ErrInvalidToken
+}
+
+// SignOut revokes the refresh tokens of the access token's session, or of
+// every session with SignOutGlobal. Access tokens already issued stay valid
+// until they expire, as with Supabase.
+func (p *MemoryProvider) SignOut(ctx context.Context, accessToken string, scope SignOutScope) error {
+	identity, err := p.Verifier().Verify(ctx, accessToken)
+	if err != nil {
+		return err
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for token, session := range p.refreshTokens {
+		if session.userID == identity.UserID && (scope == SignOutGlobal || session.sessionID == identity.SessionID) {
+			delete(p.refreshTokens, token)
+		}
+	}
+	return nil
+}
+
+// SendPasswordReset stores a reset token instead of emailing it; tests can
+// read it back with PasswordResetToken.
+func (p *MemoryProvider) SendPasswordReset(ctx context.Context, email string) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	key := strings.ToLower(email)
+	if _, ok := p.users[key]; !ok {
+		return nil
+	}
+	token, err := randomToken()
+	if err != nil {
+		return err
+	}
+	p.resetTokens[key] = token
+	return nil
+}
+
+func (p *MemoryProvider) ResetPassword(ctx context.Context, email, token, newPassword string) (*User, error) {
+	hash, err := bcrypt.GenerateFromPassword([]byte(newPassword), bcrypt.MinCost)
+	if err != nil {
+		return nil, err
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	key := strings.ToLower(email)
+	if t, ok := p.resetTokens[key]; !ok || t != token {
+		return nil, ErrInvalidResetToken
+	}
+	delete(p.resetTokens, key)
+	u := p.users[key]
+	u.passwordHash = hash
+	return &u.User, nil
+}
+
+func (p *MemoryProvider) ChangePassword(ctx context.Context, accessToken, newPassword string) error {
+	identity, err := p.Verifier().Verify(ctx, accessToken)
+	if err != nil {
+		return err
+	}
+	hash, err := bcrypt.GenerateFromPassword([]byte(newPassword), bcrypt.MinCost)
+	if err != nil {
+		return err
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for _, u := range p.users {
+		if u.ID == identity.UserID {
+			u.passwordHash = hash
+			return nil
+		}
+	}
+	return ErrInvalidToken
+}
+
+func (p *MemoryProvider) ResendVerification(ctx context.Context, email string) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	key := strings.ToLower(email)
+	if u, ok := p.users[key]; !ok || u.EmailVerified {
+		return nil
+	}
+	token, err := randomToken()
+	if err != nil {
+		return err
+	}
+	p.verifyTokens[key] = token
+	return nil
+}
+
+// VerificationToken returns the last confirmation token sent to email.
+func (p *MemoryProvider) VerificationToken(email string) (string, bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	token, ok := p.verifyTokens[strings.ToLower(email)]
+	return token, ok
+}
+
+// ConfirmEmail marks the account that was sent token as verified.
+func (p *MemoryProvider) ConfirmEmail(token string) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for email, t := range p.verifyTokens {
+		if t == token {
+			p.users[email].EmailVerified = true
+			delete(p.verifyTokens, email)
+			return true
+		}
+	}
+	return false
+}
+
+// PasswordResetToken returns the last reset token sent to email.
+func (p *MemoryProvider) PasswordResetToken(email string) (string, bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	token, ok := p.resetTokens[strings.ToLower(email)]
+	return token, ok
+}
+
+func (p *MemoryProvider) newSession(user User, sessionID string) (*Session, error) {
+	now := time.Now()
+	claims := tokenClaims{
+		RegisteredClaims: jwt.RegisteredClaims{
+			Subject:   user.ID.String(),
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(memoryTokenTTL)),
+		},
+		Email:     user.Email,
+		SessionID: sessionID,
+	}
+	access, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString(p.secret)
+	if err != nil {
+		return nil, err
+	}
+
+	refresh, err := randomToken()
+	if err != nil {
+		return nil, err
+	}
+	p.mu.Lock()
+	p.refreshTokens[refresh] = memorySession{userID: user.ID, sessionID: sessionID}
+	p.mu.Unlock()
+
+	return &Session{
+		AccessToken:  access,
+		RefreshToken: refresh,
+		TokenType:    "bearer",
+		ExpiresIn:    int(memoryTokenTTL.Seconds()),
+		User:         user,
+		SessionID:    sessionID,
+	}, nil
+}
+
+func randomToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("failed to generate token: %w", err)
+	}
+	return hex.EncodeToString(b), nil
+}
@@ -0,0 +1,235 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func newTestProvider(t *testing.T) (*MemoryProvider, *User) {
+	t.Helper()
+	p := NewMemoryProvider(testSecret)
+	user, err := p.SignUp(context.Background(), "Student@umbc.edu", "hunter22")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return p, user
+}
+
+func TestMemoryProviderSignUpAndSignIn(t *testing.T) {
+	ctx := context.Background()
+	p, user := newTestProvider(t)
+
+	if _, err := p.SignUp(ctx, "student@UMBC.edu", "other-password"); !errors.Is(err, ErrEmailTaken) {
+		t.Errorf("second sign up error = %v, want ErrEmailTaken", err)
+	}
+	if _, err := p.SignIn(ctx, "student@umbc.edu", "wrong-password"); !errors.Is(err, ErrInvalidCredentials) {
+		t.Errorf("wrong password error = %v, want ErrInvalidCredentials", err)
+	}
+	if _, err := p.SignIn(ctx, "nobody@umbc.edu", "hunter22"); !errors.Is(err, ErrInvalidCredentials) {
+		t.Errorf("unknown email error = %v, want ErrInvalidCredentials", err)
+	}
+
+	session, err := p.SignIn(ctx, "student@umbc.edu", "hunter22")
+	if err != nil {
+		t.Fatal(err)
+	}
+	identity, err := p.Verifier().Verify(ctx, session.AccessToken)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if identity.UserID != user.ID || identity.SessionID == "" {
+		t.Errorf("identity = %+v, want user %s with a session", identity, user.ID)
+	}
+
+	// tokens from another provider's key are not accepted
+	if _, err := NewMemoryProvider([]byte("other-secret")).Verifier().Verify(ctx, session.AccessToken); err == nil {
+		t.Error("a token verified under another provider's secret")
+	}
+}
+
+func TestMemoryProviderRefreshRotatesTokens(t *testing.T) {
+	ctx := context.Background()
+	p, _ := newTestProvider(t)
+
+	first, err := p.SignIn(ctx, "student@umbc.edu", "hunter22")
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := p.Refresh(ctx, first.RefreshToken)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := p.Refresh(ctx, first.RefreshToken); !errors.Is(err, ErrInvalidRefreshToken) {
+		t.Errorf("reused refresh token error = %v, want ErrInvalidRefreshToken", err)
+	}
+
+	before, _ := p.Verifier().Verify(ctx, first.AccessToken)
+	after, _ := p.Verifier().Verify(ctx, second.AccessToken)
+	if before.SessionID != after.SessionID {
+		t.Errorf("refresh moved session %s to %s", before.SessionID, after.SessionID)
+	}
+	if first.SessionID != before.SessionID || second.SessionID != after.SessionID {
+		t.Errorf("sessions report ids %q and %q, tokens say %q", first.SessionID, second.SessionID, before.SessionID)
+	}
+}
+
+func TestMemoryProviderSignOut(t *testing.T) {
+	ctx := context.Background()
+	p, _ := newTestProvider(t)
+
+	phone, err := p.SignIn(ctx, "student@umbc.edu", "hunter22")
+	if err != nil {
+		t.Fatal(err)
+	}
+	laptop, err := p.SignIn(ctx, "student@umbc.edu", "hunter22")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := p.SignOut(ctx, phone.AccessToken, SignOutLocal); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := p.Refresh(ctx, phone.RefreshToken); !errors.Is(err, ErrInvalidRefreshToken) {
+		t.Errorf("signed out session refreshed: %v", err)
+	}
+	laptop, err = p.Refresh(ctx, laptop.RefreshToken)
+	if err != nil {
+		t.Fatalf("local sign out ended another session: %v", err)
+	}
+
+	tablet, err := p.SignIn(ctx, "student@umbc.edu", "hunter22")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := p.SignOut(ctx, tablet.AccessToken, SignOutGlobal); err != nil {
+		t.Fatal(err)
+	}
+	for name, s := range map[string]*Session{"laptop": laptop, "tablet": tablet} {
+		if _, err := p.Refresh(ctx, s.RefreshToken); !errors.Is(err, ErrInvalidRefreshToken) {
+			t.Errorf("%s refreshed after a global sign out: %v", name, err)
+		}
+	}
+}
+
+func TestMemoryProviderPasswordReset(t *testing.T) {
+	ctx := context.Background()
+	p, user := newTestProvider(t)
+
+	// unknown addresses succeed silently and get no token
+	if err := p.SendPasswordReset(ctx, "nobody@umbc.edu"); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := p.PasswordResetToken("nobody@umbc.edu"); ok {
+		t.Error("a reset token was issued for an unknown address")
+	}
+
+	if err := p.SendPasswordReset(ctx, "student@umbc.edu"); err != nil {
+		t.Fatal(err)
+	}
+	token, ok := p.PasswordResetToken("student@umbc.edu")
+	if !ok {
+		t.Fatal("no reset token was issued")
+	}
+	if _, err := p.ResetPassword(ctx, "student@umbc.edu", "wrong-token", "new-password"); !errors.Is(err, ErrInvalidResetToken) {
+		t.Errorf("wrong token error = %v, want ErrInvalidResetToken", err)
+	}
+
+	reset, err := p.ResetPassword(ctx, "student@umbc.edu", token, "new-password")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if reset.ID != user.ID {
+		t.Errorf("reset user %s, want %s", reset.ID, user.ID)
+	}
+	if _, err := p.ResetPassword(ctx, "student@umbc.edu", token, "another-password"); !errors.Is(err, ErrInvalidResetToken) {
+		t.Errorf("reused token error = %v, want ErrInvalidResetToken", err)
+	}
+
+	if _, err := p.SignIn(ctx, "student@umbc.edu", "hunter22"); !errors.Is(err, ErrInvalidCredentials) {
+		t.Errorf("old password still works: %v", err)
+	}
+	if _, err := p.SignIn(ctx, "student@umbc.edu", "new-password"); err != nil {
+		t.Errorf("new password rejected: %v", err)
+	}
+}
+
+func TestMemoryProviderChangePassword(t *testing.T) {
+	ctx := context.Background()
+	p, _ := newTestProvider(t)
+
+	session, err := p.SignIn(ctx, "student@umbc.edu", "hunter22")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := p.ChangePassword(ctx, session.AccessToken, "new-password"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := p.SignIn(ctx, "student@umbc.edu", "new-password"); err != nil {
+		t.Errorf("new password rejected: %v", err)
+	}
+	if err := p.ChangePassword(ctx, "not-a-token", "whatever"); err == nil {
+		t.Error("changed a password without a valid token")
+	}
+}
+
+func TestMemoryProviderConfirmEmail(t *testing.T) {
+	ctx := context.Background()
+	p, user := newTestProvider(t)
+	if user.EmailVerified {
+		t.Fatal("new account is verified without AutoConfirm")
+	}
+
+	token, ok := p.VerificationToken("student@umbc.edu")
+	if !ok {
+		t.Fatal("no verification token was issued")
+	}
+	if err := p.ResendVerification(ctx, "student@umbc.edu"); err != nil {
+		t.Fatal(err)
+	}
+	resent, _ := p.VerificationToken("student@umbc.edu")
+	if resent == token {
+		t.Fatal("resending reused the old verification token")
+	}
+	if p.ConfirmEmail(token) {
+		t.Error("a superseded verification token was accepted")
+	}
+	if !p.ConfirmEmail(resent) {
+		t.Fatal("the resent verification token was rejected")
+	}
+
+	session, err := p.SignIn(ctx, "student@umbc.edu", "hunter22")
+	if err != nil {
+		t.Fatal(err)
+	}
+	confirmed, err := p.GetUser(ctx, session.AccessToken)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !confirmed.EmailVerified {
+		t.Error("account is still unverified after confirming")
+	}
+
+	// nothing more is sent once the address is confirmed
+	if err := p.ResendVerification(ctx, "student@umbc.edu"); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := p.VerificationToken("student@umbc.edu"); ok {
+		t.Error("a verification token was issued for a confirmed account")
+	}
+}
+
+func TestMemoryProviderAutoConfirm(t *testing.T) {
+	p := NewMemoryProvider(testSecret)
+	p.AutoConfirm = true
+	user, err := p.SignUp(context.Background(), "student@umbc.edu", "hunter22")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !user.EmailVerified {
+		t.Error("AutoConfirm account is not verified")
+	}
+	if _, ok := p.VerificationToken("student@umbc.edu"); ok {
+		t.Error("a verification token was issued with AutoConfirm")
+	}
+}
@@ -0,0 +1,156 @@
+package auth
+
+import (
+	"errors"
+	"log"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// passwordLimits caps password attempts per email (or per user for changes)
+// so the endpoints can't be used to spam inboxes or guess reset codes. The
+// per-IP limits stop one client from working through many addresses.
+type passwordLimits struct {
+	forgot   *RateLimiter
+	reset    *RateLimiter
+	change   *RateLimiter
+	forgotIP *RateLimiter
+	resetIP  *RateLimiter
+}
+
+func newPasswordLimits() passwordLimits {
+	return passwordLimits{
+		forgot:   NewRateLimiter(3, time.Hour),
+		reset:    NewRateLimiter(5, 15*time.Minute),
+		change:   NewRateLimiter(5, 15*time.Minute),
+		forgotIP: NewRateLimiter(10, time.Hour),
+		resetIP:  NewRateLimiter(20, 15*time.Minute),
+	}
+}
+
+// allow checks limiter for key and writes a 429 if it is over the limit.
+func allow(c *gin.Context, limiter *RateLimiter, key string) bool {
+	ok, retryAfter := limiter.Allow(key)
+	if !ok {
+		c.Header("Retry-After", strconv.Itoa(int(math.Ceil(retryAfter.Seconds()))))
+		c.JSON(http.StatusTooManyRequests, gin.H{"error": "too many attempts, try again later"})
+	}
+	return ok
+}
+
+// ForgotPasswordHandler serves POST /auth/password/forgot. The response is
+// the same whether or not the email has an account.
+func (h *AuthHandlers) ForgotPasswordHandler(c *gin.Context) {
+	var req struct {
+		Email string `json:"email" binding:"required,email"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "a valid email is required"})
+		return
+	}
+
+	email := strings.ToLower(req.Email)
+	if !allow(c, h.limits.forgotIP, c.ClientIP()) || !allow(c, h.limits.forgot, email) {
+		return
+	}
+
+	if h.emails.Allows(email) {
+		if err := h.provider.SendPasswordReset(c.Request.Context(), email); err != nil {
+			log.Printf("failed to send password reset email: %v", err)
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "if that address has an account, a password reset email is on its way",
+	})
+}
+
+// ResetPasswordHandler serves POST /auth/password/reset with the code from
+// the reset email. Every existing session is signed out afterwards.
+func (h *AuthHandlers) ResetPasswordHandler(c *gin.Context) {
+	var req struct {
+		Email       string `json:"email" binding:"required,email"`
+		Token       string `json:"token" binding:"required"`
+		NewPassword string `json:"new_password" binding:"required,min=6"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "email, token and a new_password of at least 6 characters are required"})
+		return
+	}
+
+	email := strings.ToLower(req.Email)
+	if !allow(c, h.limits.resetIP, c.ClientIP()) || !allow(c, h.limits.reset, email) {
+		return
+	}
+
+	user, err := h.provider.ResetPassword(c.Request.Context(), email, req.Token, req.NewPassword)
+	if errors.Is(err, ErrInvalidResetToken) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": ErrInvalidResetToken.Error()})
+		return
+	}
+	if err != nil {
+		log.Printf("password reset failed: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to reset password"})
+		return
+	}
+
+	if err := h.sessions.RevokeAll(c.Request.Context(), user.ID, nil, "password_reset"); err != nil {
+		log.Printf("failed to revoke sessions after password reset: %v", err)
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "password updated, please login again"})
+}
+
+// ChangePasswordHandler serves POST /auth/password/change for a signed in
+// user. The current password is checked first, and every session including
+// this one is signed out afterwards.
+func (h *AuthHandlers) ChangePasswordHandler(c *gin.Context) {
+	var req struct {
+		CurrentPassword string `json:"current_password" binding:"required"`
+		NewPassword     string `json:"new_password" binding:"required,min=6"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "current_password and a new_password of at least 6 characters are required"})
+		return
+	}
+
+	value, _ := c.Get("user")
+	identity, ok := value.(*Identity)
+	if !ok {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "invalid user"})
+		return
+	}
+	if !allow(c, h.limits.change, identity.UserID.String()) {
+		return
+	}
+
+	check, err := h.provider.SignIn(c.Request.Context(), identity.Email, req.CurrentPassword)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "current password is incorrect"})
+		return
+	}
+	// the sign in above was only to check the password
+	if err := h.provider.SignOut(c.Request.Context(), check.AccessToken, SignOutLocal); err != nil {
+		log.Printf("failed to end password check session: %v", err)
+	}
+
+	if err := h.provider.ChangePassword(c.Request.Context(), c.GetString("access_token"), req.NewPassword); err != nil {
+		log.Printf("password change failed: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to change password"})
+		return
+	}
+
+	if err := h.sessions.RevokeAll(c.Request.Context(), identity.UserID, nil, "password_changed"); err != nil {
+		log.Printf("failed to revoke sessions after password change: %v", err)
+	}
+	if err := h.provider.SignOut(c.Request.Context(), c.GetString("access_token"), SignOutGlobal); err != nil {
+		log.Printf("provider sign out failed: %v", err)
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "password changed, please login again"})
+}
@@ -0,0 +1,65 @@
+package auth
+
+import (
+	"context"
+	"errors"
+
+	"github.com/google/uuid"
+)
+
+var (
+	ErrInvalidCredentials  = errors.New("invalid credentials")
+	ErrInvalidRefreshToken = errors.New("invalid or expired refresh token")
+	ErrEmailTaken          = errors.New("an account with this email already exists")
+	ErrInvalidResetToken   = errors.New("invalid or expired password reset code")
+)
+
+// User is an account as the identity provider sees it.
+type User struct {
+	ID            uuid.UUID `json:"id"`
+	Email         string    `json:"email"`
+	EmailVerified bool      `json:"email_verified"`
+}
+
+// Session is a signed in user's token pair. SessionID is the access token's
+// session_id claim, which stays the same across refreshes.
+type Session struct {
+	AccessToken  string `json:"access_token"`
+	RefreshToken string `json:"refresh_token"`
+	TokenType    string `json:"token_type"`
+	ExpiresIn    int    `json:"expires_in"`
+	User         User   `json:"user"`
+	SessionID    string `json:"-"`
+}
+
+// SignOutScope says which of a user's sessions SignOut ends.
+type SignOutScope string
+
+const (
+	SignOutLocal  SignOutScope = "local"  // only the session the access token belongs to
+	SignOutGlobal SignOutScope = "global" // every session the user has
+)
+
+// IdentityProvider owns user credentials and issues the access tokens that
+// AuthMiddleware verifies. Supabase is used in production; MemoryProvider
+// backs tests and local development.
+type IdentityProvider interface {
+	SignUp(ctx context.Context, email, password string) (*User, error)
+	SignIn(ctx context.Context, email, password string) (*Session, error)
+	Refresh(ctx context.Context, refreshToken string) (*Session, error)
+	GetUser(ctx context.Context, accessToken string) (*User, error)
+	// SignOut revokes refresh tokens in scope. The access token itself stays
+	// valid until it expires.
+	SignOut(ctx context.Context, accessToken string, scope SignOutScope) error
+	// SendPasswordReset emails a reset link. It does not report whether the
+	// email belongs to an account.
+	SendPasswordReset(ctx context.Context, email string) error
+	// ResetPassword sets a new password using the code from a reset email
+	// and returns whose password it was.
+	ResetPassword(ctx context.Context, email, token, newPassword string) (*User, error)
+	// ChangePassword sets a new password for the access token's user.
+	ChangePassword(ctx context.Context, accessToken, newPassword string) error
+	// ResendVerification sends a new confirmation email to an unverified
+	// account. Like SendPasswordReset it does not reveal whether one exists.
+	ResendVerification(ctx context.Context, email string) error
+}
@@ -0,0 +1,59 @@
+package auth
+
+import (
+	"sync"
+	"time"
+)
+
+// RateLimiter allows at most limit attempts per key within any window. It is
+// kept in memory, so each server instance counts separately. Keys that stop
+// being used are swept out once per window, so the map only holds keys seen
+// within about the last two windows.
+type RateLimiter struct {
+	limit  int
+	window time.Duration
+
+	mu       sync.Mutex
+	attempts map[string][]time.Time
+	swept    time.Time
+}
+
+func NewRateLimiter(limit int, window time.Duration) *RateLimiter {
+	return &RateLimiter{limit: limit, window: window, attempts: map[string][]time.Time{}}
+}
+
+// Allow records an attempt for key. If key is over the limit the attempt is
+// not counted, and retryAfter says how long until the next one is allowed.
+func (l *RateLimiter) Allow(key string) (ok bool, retryAfter time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := time.Now()
+	if now.Sub(l.swept) >= l.window {
+		for k := range l.attempts {
+			l.prune(k, now)
+		}
+		l.swept = now
+	}
+
+	recent := l.prune(key, now)
+	if len(recent) >= l.limit {
+		return false, recent[0].Add(l.window).Sub(now)
+	}
+	l.attempts[key] = append(recent, now)
+	return true, 0
+}
+
+// prune drops attempts older than the window for key and returns the rest.
+func (l *RateLimiter) prune(key string, now time.Time) []time.Time {
+	recent := l.attempts[key]
+	for len(recent) > 0 && now.Sub(recent[0]) >= l.window {
+		recent = recent[1:]
+	}
+	if len(recent) == 0 {
+		delete(l.attempts, key)
+		return nil
+	}
+	l.attempts[key] = recent
+	return recent
+}
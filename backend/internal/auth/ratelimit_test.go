@@ -0,0 +1,45 @@
+package auth
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestRateLimiter(t *testing.T) {
+	l := NewRateLimiter(2, time.Hour)
+
+	for i := 0; i < 2; i++ {
+		if ok, _ := l.Allow("a@umbc.edu"); !ok {
+			t.Fatalf("attempt %d was refused", i+1)
+		}
+	}
+	ok, retryAfter := l.Allow("a@umbc.edu")
+	if ok {
+		t.Fatal("third attempt was allowed")
+	}
+	if retryAfter <= 0 || retryAfter > time.Hour {
+		t.Errorf("retry after %s", retryAfter)
+	}
+	if ok, _ := l.Allow("b@umbc.edu"); !ok {
+		t.Error("another key was refused")
+	}
+}
+
+func TestRateLimiterSweepsIdleKeys(t *testing.T) {
+	l := NewRateLimiter(1, 20*time.Millisecond)
+	for i := 0; i < 100; i++ {
+		l.Allow(fmt.Sprintf("user%d@umbc.edu", i))
+	}
+
+	time.Sleep(30 * time.Millisecond)
+	if ok, _ := l.Allow("someone-else@umbc.edu"); !ok {
+		t.Fatal("a new key was refused")
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if len(l.attempts) != 1 {
+		t.Errorf("%d keys kept after the window, want only the new one", len(l.attempts))
+	}
+}
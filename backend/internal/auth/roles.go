@@ -0,0 +1,81 @@
+package auth
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+type Role string
+
+const (
+	RoleCustomer        Role = "customer"
+	RoleDasher          Role = "dasher"
+	RoleRestaurantStaff Role = "restaurant_staff"
+	RoleAdmin           Role = "admin"
+)
+
+// Grant is one role held by a user. RestaurantID is only set for restaurant staff.
+type Grant struct {
+	Role         Role       `json:"role"`
+	RestaurantID *uuid.UUID `json:"restaurant_id,omitempty"`
+}
+
+// Roles is every role a user holds. AuthMiddleware loads it once per request.
+type Roles []Grant
+
+func (r Roles) Has(role Role) bool {
+	for _, g := range r {
+		if g.Role == role {
+			return true
+		}
+	}
+	return false
+}
+
+// HasAny reports whether the user holds at least one of roles.
+func (r Roles) HasAny(roles ...Role) bool {
+	for _, role := range roles {
+		if r.Has(role) {
+			return true
+		}
+	}
+	return false
+}
+
+// StaffOf reports whether the user works at restaurantID.
+func (r Roles) StaffOf(restaurantID uuid.UUID) bool {
+	for _, g := range r {
+		if g.Role == RoleRestaurantStaff && g.RestaurantID != nil && *g.RestaurantID == restaurantID {
+			return true
+		}
+	}
+	return false
+}
+
+// RolesFrom returns the roles AuthMiddleware stored on the request.
+func RolesFrom(c *gin.Context) Roles {
+	if v, ok := c.Get("roles"); ok {
+		if roles, ok := v.(Roles); ok {
+			return roles
+		}
+	}
+	return nil
+}
+
+// RequireRole only lets through users holding at least one of roles. It must
+// run after AuthMiddleware.
+func RequireRole(roles ...Role) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !RolesFrom(c).HasAny(roles...) {
+			c.JSON(http.StatusForbidden, gin.H{
+				"error":          "you do not have access to this resource",
+				"required_roles": roles,
+			})
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
@@ -0,0 +1,107 @@
+package auth
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// RoleSource looks up the roles a user holds.
+type RoleSource interface {
+	Roles(ctx context.Context, userID uuid.UUID) (Roles, error)
+}
+
+// DefaultRoleCacheTTL is used when ROLE_CACHE_TTL is not set.
+const DefaultRoleCacheTTL = 30 * time.Second
+
+// RoleStore reads and grants roles in user_roles. Lookups are cached for a
+// short TTL so authenticated requests don't all hit the database; granting a
+// role drops the user's cached entry.
+type RoleStore struct {
+	conn  *pgxpool.Pool
+	cache *ttlCache[Roles]
+}
+
+func NewRoleStore(conn *pgxpool.Pool, ttl time.Duration) *RoleStore {
+	return &RoleStore{conn: conn, cache: newTTLCache[Roles](ttl)}
+}
+
+// Roles returns every role granted to userID.
+func (s *RoleStore) Roles(ctx context.Context, userID uuid.UUID) (Roles, error) {
+	if roles, ok := s.cache.get(userID); ok {
+		return roles, nil
+	}
+
+	rows, err := s.conn.Query(ctx, "SELECT role, restaurant_id FROM user_roles WHERE user_id = $1", userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	roles := Roles{}
+	for rows.Next() {
+		var g Grant
+		if err := rows.Scan(&g.Role, &g.RestaurantID); err != nil {
+			return nil, err
+		}
+		roles = append(roles, g)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	s.cache.set(userID, roles)
+	return roles, nil
+}
+
+// Grant gives userID a role. restaurantID must be set for restaurant staff only.
+func (s *RoleStore) Grant(ctx context.Context, userID uuid.UUID, role Role, restaurantID *uuid.UUID) error {
+	if _, err := s.conn.Exec(ctx, grantRole, userID, role, restaurantID); err != nil {
+		return err
+	}
+	s.Invalidate(userID)
+	return nil
+}
+
+// GrantTx is Grant inside tx. Call Invalidate once tx has committed.
+func (s *RoleStore) GrantTx(ctx context.Context, tx pgx.Tx, userID uuid.UUID, role Role, restaurantID *uuid.UUID) error {
+	_, err := tx.Exec(ctx, grantRole, userID, role, restaurantID)
+	return err
+}
+
+// RevokeTx removes role from userID inside tx, for every restaurant if the
+// role is restaurant staff. Call Invalidate once tx has committed.
+func (s *RoleStore) RevokeTx(ctx context.Context, tx pgx.Tx, userID uuid.UUID, role Role) error {
+	_, err := tx.Exec(ctx, "DELETE FROM user_roles WHERE user_id = $1 AND role = $2", userID, role)
+	return err
+}
+
+const grantRole = `
+	INSERT INTO user_roles (user_id, role, restaurant_id)
+	VALUES ($1, $2, $3)
+	ON CONFLICT DO NOTHING
+`
+
+// Invalidate drops userID's cached roles so the next request reloads them.
+func (s *RoleStore) Invalidate(userID uuid.UUID) {
+	s.cache.invalidate(userID)
+}
+
+// LoadRoleCacheTTL reads ROLE_CACHE_TTL, e.g. 30s. Zero disables caching.
+// The same TTL applies to cached session revocations.
+func LoadRoleCacheTTL() (time.Duration, error) {
+	v := os.Getenv("ROLE_CACHE_TTL")
+	if v == "" {
+		return DefaultRoleCacheTTL, nil
+	}
+	ttl, err := time.ParseDuration(v)
+	if err != nil || ttl < 0 {
+		return DefaultRoleCacheTTL, fmt.Errorf("ROLE_CACHE_TTL must be a duration like 30s, got %q", v)
+	}
+	return ttl, nil
+}
@@ -0,0 +1,123 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// RevocationSource reports whether a session has been signed out.
+type RevocationSource interface {
+	SessionRevoked(ctx context.Context, userID uuid.UUID, sessionID string) (bool, error)
+}
+
+// SessionStore records sign-ins in auth_sessions and "sign out everywhere"
+// revocations in user_sessions. After a revocation only sessions that
+// started later are accepted; that is checked per session, because refresh
+// tokens are opaque and can be exchanged at the provider without us.
+// Lookups are cached like roles; revoking through the store drops the
+// user's cached entry at once.
+type SessionStore struct {
+	conn   *pgxpool.Pool
+	cache  *ttlCache[*time.Time]
+	starts *ttlCache[*time.Time]
+}
+
+func NewSessionStore(conn *pgxpool.Pool, ttl time.Duration) *SessionStore {
+	return &SessionStore{conn: conn, cache: newTTLCache[*time.Time](ttl), starts: newTTLCache[*time.Time](ttl)}
+}
+
+// RevokedAt returns when userID's sessions were last revoked, or nil if never.
+func (s *SessionStore) RevokedAt(ctx context.Context, userID uuid.UUID) (*time.Time, error) {
+	if revokedAt, ok := s.cache.get(userID); ok {
+		return revokedAt, nil
+	}
+
+	var revokedAt *time.Time
+	err := s.conn.QueryRow(ctx, "SELECT revoked_at FROM user_sessions WHERE user_id = $1", userID).Scan(&revokedAt)
+	if err != nil && !errors.Is(err, pgx.ErrNoRows) {
+		return nil, err
+	}
+
+	s.cache.set(userID, revokedAt)
+	return revokedAt, nil
+}
+
+// SessionRevoked reports whether sessionID, one of userID's sessions, was
+// signed out: it started before the user's last revocation, or was never
+// recorded by RecordSignIn and so can't be shown to have started after it.
+func (s *SessionStore) SessionRevoked(ctx context.Context, userID uuid.UUID, sessionID string) (bool, error) {
+	revokedAt, err := s.RevokedAt(ctx, userID)
+	if err != nil || revokedAt == nil {
+		return false, err
+	}
+
+	id, err := uuid.Parse(sessionID)
+	if err != nil {
+		return true, nil
+	}
+	startedAt, err := s.startedAt(ctx, userID, id)
+	if err != nil {
+		return false, err
+	}
+	return startedAt == nil || !startedAt.After(*revokedAt), nil
+}
+
+// startedAt returns when sessionID was recorded, or nil if it never was.
+// Neither changes once the session exists, so misses are cached too.
+func (s *SessionStore) startedAt(ctx context.Context, userID, sessionID uuid.UUID) (*time.Time, error) {
+	if startedAt, ok := s.starts.get(sessionID); ok {
+		return startedAt, nil
+	}
+
+	var startedAt *time.Time
+	err := s.conn.QueryRow(ctx, `
+		SELECT started_at FROM auth_sessions WHERE session_id = $1 AND user_id = $2
+	`, sessionID, userID).Scan(&startedAt)
+	if err != nil && !errors.Is(err, pgx.ErrNoRows) {
+		return nil, err
+	}
+
+	s.starts.set(sessionID, startedAt)
+	return startedAt, nil
+}
+
+// RevokeAll ends every session userID has. revokedBy is nil when users sign
+// themselves out.
+func (s *SessionStore) RevokeAll(ctx context.Context, userID uuid.UUID, revokedBy *uuid.UUID, reason string) error {
+	_, err := s.conn.Exec(ctx, `
+		INSERT INTO user_sessions (user_id, revoked_at, revoked_by, revoke_reason)
+		VALUES ($1, NOW(), $2, $3)
+		ON CONFLICT (user_id) DO UPDATE
+		SET revoked_at = NOW(), revoked_by = EXCLUDED.revoked_by, revoke_reason = EXCLUDED.revoke_reason
+	`, userID, revokedBy, reason)
+	if err != nil {
+		return err
+	}
+	s.cache.invalidate(userID)
+	return nil
+}
+
+// RecordSignIn notes that userID started sessionID with their password. Only
+// recorded sessions survive a later revocation of an earlier one.
+func (s *SessionStore) RecordSignIn(ctx context.Context, userID uuid.UUID, sessionID string) error {
+	id, err := uuid.Parse(sessionID)
+	if err != nil {
+		return fmt.Errorf("invalid session id %q: %w", sessionID, err)
+	}
+	_, err = s.conn.Exec(ctx, `
+		INSERT INTO auth_sessions (session_id, user_id)
+		VALUES ($1, $2)
+		ON CONFLICT (session_id) DO NOTHING
+	`, id, userID)
+	if err != nil {
+		return err
+	}
+	s.starts.invalidate(id)
+	return nil
+}
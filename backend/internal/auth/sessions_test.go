@@ -0,0 +1,133 @@
+package auth
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/supabase-community/gotrue-go/types"
+)
+
+// testDB connects to the Postgres at TEST_DB_STRING, which must already have
+// the app's schema and migrations. Tests that need it are skipped without one.
+func testDB(t *testing.T) *pgxpool.Pool {
+	t.Helper()
+	connStr := os.Getenv("TEST_DB_STRING")
+	if connStr == "" {
+		t.Skip("TEST_DB_STRING is not set")
+	}
+	conn, err := pgxpool.New(context.Background(), connStr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(conn.Close)
+	return conn
+}
+
+func TestSessionRevokedPerSession(t *testing.T) {
+	ctx := context.Background()
+	s := NewSessionStore(testDB(t), 0)
+	userID := uuid.New()
+	phone, laptop, tablet := uuid.NewString(), uuid.NewString(), uuid.NewString()
+
+	for _, session := range []string{phone, laptop} {
+		if err := s.RecordSignIn(ctx, userID, session); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if revoked, err := s.SessionRevoked(ctx, userID, phone); err != nil || revoked {
+		t.Fatalf("phone revoked = %t, %v before anything was revoked", revoked, err)
+	}
+
+	if err := s.RevokeAll(ctx, userID, nil, "logout_all"); err != nil {
+		t.Fatal(err)
+	}
+	// signing in again on one device must not bring the others back
+	if err := s.RecordSignIn(ctx, userID, tablet); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		session string
+		want    bool
+	}{
+		{"phone", phone, true},
+		{"laptop", laptop, true},
+		{"tablet", tablet, false},
+		{"never recorded", uuid.NewString(), true},
+		{"no session id", "", true},
+	}
+	for _, tt := range tests {
+		revoked, err := s.SessionRevoked(ctx, userID, tt.session)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if revoked != tt.want {
+			t.Errorf("%s: revoked = %t, want %t", tt.name, revoked, tt.want)
+		}
+	}
+
+	// another user can't borrow a session that survived
+	if revoked, err := s.SessionRevoked(ctx, uuid.New(), tablet); err != nil || revoked {
+		t.Errorf("user without revocations: revoked = %t, %v", revoked, err)
+	}
+}
+
+func TestSupabaseSessionReadsSessionID(t *testing.T) {
+	sessionID := uuid.NewString()
+	claims := testClaims(uuid.New())
+	claims["session_id"] = sessionID
+
+	session := supabaseSession(types.Session{AccessToken: sign(t, jwt.SigningMethodHS256, claims, testSecret, "")})
+	if session.SessionID != sessionID {
+		t.Errorf("session id = %q, want %q", session.SessionID, sessionID)
+	}
+}
+
+func TestLoginWithTheWrongRoleEndsTheSession(t *testing.T) {
+	conn := testDB(t)
+	ctx := context.Background()
+	p := NewMemoryProvider(testSecret)
+	p.AutoConfirm = true
+	user, err := p.SignUp(ctx, "student@umbc.edu", "hunter22")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// a customer role only, so asking to sign in as a dasher fails
+	_, err = conn.Exec(ctx, "INSERT INTO user_roles (user_id, role) VALUES ($1, $2)", user.ID, RoleCustomer)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	h := NewAuthHandlers(p, conn, NewRoleStore(conn, 0), NewSessionStore(conn, 0), NewEmailVerifications(p, 0), EmailPolicy{Domains: []string{"umbc.edu"}})
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.POST("/login", h.LoginHandler)
+	w := httptest.NewRecorder()
+	body := `{"email": "student@umbc.edu", "password": "hunter22", "is_dasher": true}`
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/login", strings.NewReader(body)))
+	if w.Code != http.StatusUnauthorized {
+		t.Fatalf("status %d, want 401", w.Code)
+	}
+
+	var recorded int
+	if err := conn.QueryRow(ctx, "SELECT COUNT(*) FROM auth_sessions WHERE user_id = $1", user.ID).Scan(&recorded); err != nil {
+		t.Fatal(err)
+	}
+	if recorded != 0 {
+		t.Errorf("%d sessions recorded for a rejected login", recorded)
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if len(p.refreshTokens) != 0 {
+		t.Error("the rejected session can still be refreshed")
+	}
+}
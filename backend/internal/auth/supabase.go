@@ -0,0 +1,167 @@
+package auth
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/supabase-community/gotrue-go/types"
+	"github.com/supabase-community/supabase-go"
+)
+
+// SupabaseProvider is the IdentityProvider backed by Supabase Auth.
+type SupabaseProvider struct {
+	client *supabase.Client
+	url    string
+	apiKey string
+}
+
+func NewSupabaseProvider(url, apiKey string) (*SupabaseProvider, error) {
+	client, err := supabase.NewClient(url, apiKey, &supabase.ClientOptions{})
+	if err != nil {
+		return nil, err
+	}
+	return &SupabaseProvider{client: client, url: strings.TrimSuffix(url, "/"), apiKey: apiKey}, nil
+}
+
+func (p *SupabaseProvider) SignUp(ctx context.Context, email, password string) (*User, error) {
+	resp, err := p.client.Auth.Signup(types.SignupRequest{Email: email, Password: password})
+	if err != nil {
+		return nil, err
+	}
+	return &User{ID: resp.ID, Email: resp.Email, EmailVerified: resp.EmailConfirmedAt != nil}, nil
+}
+
+func (p *SupabaseProvider) SignIn(ctx context.Context, email, password string) (*Session, error) {
+	resp, err := p.client.Auth.SignInWithEmailPassword(email, password)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidCredentials, err)
+	}
+	return supabaseSession(resp.Session), nil
+}
+
+func (p *SupabaseProvider) Refresh(ctx context.Context, refreshToken string) (*Session, error) {
+	resp, err := p.client.Auth.RefreshToken(refreshToken)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidRefreshToken, err)
+	}
+	return supabaseSession(resp.Session), nil
+}
+
+func (p *SupabaseProvider) GetUser(ctx context.Context, accessToken string) (*User, error) {
+	resp, err := p.client.Auth.WithToken(accessToken).GetUser()
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidToken, err)
+	}
+	return &User{ID: resp.ID, Email: resp.Email, EmailVerified: resp.EmailConfirmedAt != nil}, nil
+}
+
+// SignOut calls the logout endpoint directly since the gotrue client always
+// signs out globally.
+func (p *SupabaseProvider) SignOut(ctx context.Context, accessToken string, scope SignOutScope) error {
+	return p.post(ctx, "/auth/v1/logout?scope="+string(scope), accessToken, nil, nil)
+}
+
+func (p *SupabaseProvider) SendPasswordReset(ctx context.Context, email string) error {
+	return p.client.Auth.Recover(types.RecoverRequest{Email: email})
+}
+
+// ResetPassword trades the recovery code from the reset email for a session
+// and sets the new password with it. The gotrue client's VerifyForUser
+// expects a redirect, so the verify endpoint is called directly.
+func (p *SupabaseProvider) ResetPassword(ctx context.Context, email, token, newPassword string) (*User, error) {
+	var session types.Session
+	body := map[string]string{"type": "recovery", "email": email, "token": token}
+	if err := p.post(ctx, "/auth/v1/verify", "", body, &session); err != nil {
+		// only a rejected code is the caller's fault; outages and Supabase's
+		// own rate limiting are reported as failures
+		var status *statusError
+		if errors.As(err, &status) && status.code >= 400 && status.code < 500 && status.code != http.StatusTooManyRequests {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidResetToken, err)
+		}
+		return nil, fmt.Errorf("failed to verify reset code: %w", err)
+	}
+	if err := p.ChangePassword(ctx, session.AccessToken, newPassword); err != nil {
+		return nil, err
+	}
+	return &supabaseSession(session).User, nil
+}
+
+func (p *SupabaseProvider) ChangePassword(ctx context.Context, accessToken, newPassword string) error {
+	_, err := p.client.Auth.WithToken(accessToken).UpdateUser(types.UpdateUserRequest{Password: &newPassword})
+	return err
+}
+
+// ResendVerification calls Supabase's resend endpoint directly; the gotrue
+// client doesn't wrap it.
+func (p *SupabaseProvider) ResendVerification(ctx context.Context, email string) error {
+	return p.post(ctx, "/auth/v1/resend", "", map[string]string{"type": "signup", "email": email}, nil)
+}
+
+// post sends body as JSON to a Supabase Auth endpoint the gotrue client
+// doesn't cover, decoding the response into out if it is set.
+func (p *SupabaseProvider) post(ctx context.Context, path, accessToken string, body, out any) error {
+	var payload io.Reader
+	if body != nil {
+		b, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		payload = bytes.NewReader(b)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.url+path, payload)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("apikey", p.apiKey)
+	if accessToken != "" {
+		req.Header.Set("Authorization", "Bearer "+accessToken)
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return &statusError{path: path, code: resp.StatusCode}
+	}
+	if out != nil {
+		return json.NewDecoder(resp.Body).Decode(out)
+	}
+	return nil
+}
+
+// statusError is a non-2xx response from a Supabase Auth endpoint.
+type statusError struct {
+	path string
+	code int
+}
+
+func (e *statusError) Error() string {
+	return fmt.Sprintf("POST %s failed: status %d", e.path, e.code)
+}
+
+func supabaseSession(s types.Session) *Session {
+	// the token came straight from Supabase, and every request that uses it
+	// verifies it again, so its claims are only read here
+	var claims tokenClaims
+	jwt.NewParser().ParseUnverified(s.AccessToken, &claims)
+
+	return &Session{
+		AccessToken:  s.AccessToken,
+		RefreshToken: s.RefreshToken,
+		TokenType:    s.TokenType,
+		ExpiresIn:    s.ExpiresIn,
+		User:         User{ID: s.User.ID, Email: s.User.Email, EmailVerified: s.User.EmailConfirmedAt != nil},
+		SessionID:    claims.SessionID,
+	}
+}
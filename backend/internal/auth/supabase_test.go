@@ -0,0 +1,46 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestSupabaseResetPasswordErrors(t *testing.T) {
+	tests := []struct {
+		status       int
+		invalidToken bool
+	}{
+		{http.StatusBadRequest, true},
+		{http.StatusForbidden, true},
+		{http.StatusNotFound, true},
+		{http.StatusTooManyRequests, false},
+		{http.StatusInternalServerError, false},
+		{http.StatusBadGateway, false},
+	}
+
+	for _, tt := range tests {
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Path != "/auth/v1/verify" {
+				t.Errorf("unexpected request to %s", r.URL.Path)
+			}
+			w.WriteHeader(tt.status)
+		}))
+		p, err := NewSupabaseProvider(srv.URL, "anon-key")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		_, err = p.ResetPassword(context.Background(), "student@umbc.edu", "123456", "new-password")
+		srv.Close()
+		if err == nil {
+			t.Errorf("status %d: no error", tt.status)
+			continue
+		}
+		if got := errors.Is(err, ErrInvalidResetToken); got != tt.invalidToken {
+			t.Errorf("status %d: invalid reset token = %t, want %t (%v)", tt.status, got, tt.invalidToken, err)
+		}
+	}
+}
@@ -0,0 +1,249 @@
+package auth
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+)
+
+// ErrInvalidToken is returned for any access token that fails verification.
+var ErrInvalidToken = errors.New("invalid or expired token")
+
+// Identity is what a verified access token says about its holder. Roles and
+// EmailVerified are filled in by the Authenticator from the role store and
+// the identity provider; the token's own claims for them are ignored, since
+// roles in it outlive a revocation and users can edit user_metadata.
+type Identity struct {
+	UserID        uuid.UUID `json:"id"`
+	Email         string    `json:"email"`
+	EmailVerified bool      `json:"email_verified"`
+	Roles         Roles     `json:"roles,omitempty"`
+	SessionID     string    `json:"session_id,omitempty"`
+	IssuedAt      time.Time `json:"issued_at"`
+}
+
+// TokenVerifier checks an access token and returns who it belongs to.
+type TokenVerifier interface {
+	Verify(ctx context.Context, token string) (*Identity, error)
+}
+
+// tokenClaims are the Supabase access token claims we care about.
+type tokenClaims struct {
+	jwt.RegisteredClaims
+	Email     string `json:"email"`
+	SessionID string `json:"session_id"`
+}
+
+func (c *tokenClaims) identity() (*Identity, error) {
+	userID, err := uuid.Parse(c.Subject)
+	if err != nil {
+		return nil, ErrInvalidToken
+	}
+	identity := &Identity{
+		UserID:    userID,
+		Email:     c.Email,
+		SessionID: c.SessionID,
+	}
+	if c.IssuedAt != nil {
+		identity.IssuedAt = c.IssuedAt.Time
+	}
+	return identity, nil
+}
+
+// parseToken verifies token's signature and expiry with keyFunc and returns its identity.
+func parseToken(token string, methods []string, keyFunc jwt.Keyfunc) (*Identity, error) {
+	var claims tokenClaims
+	_, err := jwt.ParseWithClaims(token, &claims, keyFunc,
+		jwt.WithValidMethods(methods),
+		jwt.WithExpirationRequired(),
+		jwt.WithIssuedAt(),
+		jwt.WithLeeway(30*time.Second),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidToken, err)
+	}
+	return claims.identity()
+}
+
+// HMACVerifier checks tokens signed with a shared HS256 secret, such as
+// Supabase's legacy JWT secret or a key generated for tests.
+type HMACVerifier struct {
+	secret []byte
+}
+
+func NewHMACVerifier(secret []byte) *HMACVerifier {
+	return &HMACVerifier{secret: secret}
+}
+
+func (v *HMACVerifier) Verify(ctx context.Context, token string) (*Identity, error) {
+	return parseToken(token, []string{"HS256"}, func(*jwt.Token) (any, error) {
+		return v.secret, nil
+	})
+}
+
+const (
+	// jwksTTL is how long fetched signing keys are trusted before refetching.
+	jwksTTL = 10 * time.Minute
+	// jwksMinRefresh stops tokens with unknown key IDs from hammering the JWKS endpoint.
+	jwksMinRefresh = 30 * time.Second
+)
+
+// JWKSVerifier checks RS256 and ES256 tokens against the public keys served
+// at a JWKS URL. Keys are cached and refetched when they expire or when a
+// token names a key ID we haven't seen, so rotations are picked up without
+// a restart.
+type JWKSVerifier struct {
+	url    string
+	client *http.Client
+
+	mu        sync.Mutex
+	keys      map[string]crypto.PublicKey
+	fetchedAt time.Time
+}
+
+func NewJWKSVerifier(url string) *JWKSVerifier {
+	return &JWKSVerifier{
+		url:    url,
+		client: &http.Client{Timeout: 5 * time.Second},
+	}
+}
+
+func (v *JWKSVerifier) Verify(ctx context.Context, token string) (*Identity, error) {
+	return parseToken(token, []string{"RS256", "ES256"}, func(t *jwt.Token) (any, error) {
+		kid, _ := t.Header["kid"].(string)
+		return v.key(ctx, kid)
+	})
+}
+
+// key returns the public key for kid, refreshing the cache if needed.
+func (v *JWKSVerifier) key(ctx context.Context, kid string) (crypto.PublicKey, error) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	key, ok := v.keys[kid]
+	stale := time.Since(v.fetchedAt) > jwksTTL
+	if ok && !stale {
+		return key, nil
+	}
+	if stale || time.Since(v.fetchedAt) > jwksMinRefresh {
+		keys, err := v.fetch(ctx)
+		if err != nil {
+			// keep using the old keys if the endpoint is briefly down
+			if ok {
+				return key, nil
+			}
+			return nil, err
+		}
+		v.keys, v.fetchedAt = keys, time.Now()
+		key, ok = v.keys[kid]
+	}
+	if !ok {
+		return nil, fmt.Errorf("unknown signing key %q", kid)
+	}
+	return key, nil
+}
+
+type jwk struct {
+	Kid string `json:"kid"`
+	Kty string `json:"kty"`
+	Crv string `json:"crv"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+func (v *JWKSVerifier) fetch(ctx context.Context) (map[string]crypto.PublicKey, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, v.url, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := v.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch JWKS: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to fetch JWKS: status %d", resp.StatusCode)
+	}
+
+	var set struct {
+		Keys []jwk `json:"keys"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&set); err != nil {
+		return nil, fmt.Errorf("failed to decode JWKS: %w", err)
+	}
+
+	keys := make(map[string]crypto.PublicKey, len(set.Keys))
+	for _, k := range set.Keys {
+		key, err := k.publicKey()
+		if err != nil {
+			// skip key types we don't verify with rather than failing every token
+			continue
+		}
+		keys[k.Kid] = key
+	}
+	return keys, nil
+}
+
+func (k jwk) publicKey() (crypto.PublicKey, error) {
+	switch k.Kty {
+	case "RSA":
+		n, err := base64.RawURLEncoding.DecodeString(k.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := base64.RawURLEncoding.DecodeString(k.E)
+		if err != nil {
+			return nil, err
+		}
+		return &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())}, nil
+
+	case "EC":
+		if k.Crv != "P-256" {
+			return nil, fmt.Errorf("unsupported curve %q", k.Crv)
+		}
+		x, err := base64.RawURLEncoding.DecodeString(k.X)
+		if err != nil {
+			return nil, err
+		}
+		y, err := base64.RawURLEncoding.DecodeString(k.Y)
+		if err != nil {
+			return nil, err
+		}
+		return &ecdsa.PublicKey{Curve: elliptic.P256(), X: new(big.Int).SetBytes(x), Y: new(big.Int).SetBytes(y)}, nil
+	}
+	return nil, fmt.Errorf("unsupported key type %q", k.Kty)
+}
+
+// LoadTokenVerifier picks a verifier from the environment:
+//
+//	SUPABASE_JWT_SECRET   verify HS256 tokens with this shared secret
+//	SUPABASE_JWKS_URL     verify RS256/ES256 tokens against this key set
+//
+// With neither set, the key set is fetched from the project at DB_URL.
+func LoadTokenVerifier() TokenVerifier {
+	if secret := os.Getenv("SUPABASE_JWT_SECRET"); secret != "" {
+		return NewHMACVerifier([]byte(secret))
+	}
+	url := os.Getenv("SUPABASE_JWKS_URL")
+	if url == "" {
+		url = strings.TrimSuffix(os.Getenv("DB_URL"), "/") + "/auth/v1/.well-known/jwks.json"
+	}
+	return NewJWKSVerifier(url)
+}
@@ -0,0 +1,228 @@
+package auth
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+)
+
+var testSecret = []byte("test-signing-secret")
+
+// testClaims returns claims for a token issued now to userID.
+func testClaims(userID uuid.UUID) jwt.MapClaims {
+	now := time.Now()
+	return jwt.MapClaims{
+		"sub":        userID.String(),
+		"email":      "student@umbc.edu",
+		"session_id": "session-1",
+		"iat":        now.Unix(),
+		"exp":        now.Add(time.Hour).Unix(),
+	}
+}
+
+func sign(t *testing.T, method jwt.SigningMethod, claims jwt.MapClaims, key any, kid string) string {
+	t.Helper()
+	token := jwt.NewWithClaims(method, claims)
+	if kid != "" {
+		token.Header["kid"] = kid
+	}
+	signed, err := token.SignedString(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return signed
+}
+
+func TestHMACVerifier(t *testing.T) {
+	userID := uuid.New()
+	v := NewHMACVerifier(testSecret)
+
+	identity, err := v.Verify(context.Background(), sign(t, jwt.SigningMethodHS256, testClaims(userID), testSecret, ""))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if identity.UserID != userID || identity.Email != "student@umbc.edu" || identity.SessionID != "session-1" {
+		t.Errorf("identity = %+v", identity)
+	}
+	if identity.IssuedAt.IsZero() {
+		t.Error("issued at was not read from the token")
+	}
+
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name  string
+		token func() string
+	}{
+		{"wrong key", func() string {
+			return sign(t, jwt.SigningMethodHS256, testClaims(userID), []byte("someone-elses-secret"), "")
+		}},
+		{"expired", func() string {
+			claims := testClaims(userID)
+			claims["iat"] = time.Now().Add(-2 * time.Hour).Unix()
+			claims["exp"] = time.Now().Add(-time.Hour).Unix()
+			return sign(t, jwt.SigningMethodHS256, claims, testSecret, "")
+		}},
+		{"no expiry", func() string {
+			claims := testClaims(userID)
+			delete(claims, "exp")
+			return sign(t, jwt.SigningMethodHS256, claims, testSecret, "")
+		}},
+		{"issued in the future", func() string {
+			claims := testClaims(userID)
+			claims["iat"] = time.Now().Add(time.Hour).Unix()
+			return sign(t, jwt.SigningMethodHS256, claims, testSecret, "")
+		}},
+		{"subject is not a user id", func() string {
+			claims := testClaims(userID)
+			claims["sub"] = "admin"
+			return sign(t, jwt.SigningMethodHS256, claims, testSecret, "")
+		}},
+		{"other algorithm", func() string {
+			return sign(t, jwt.SigningMethodHS384, testClaims(userID), testSecret, "")
+		}},
+		{"RS256", func() string {
+			return sign(t, jwt.SigningMethodRS256, testClaims(userID), rsaKey, "")
+		}},
+		{"unsigned", func() string {
+			return sign(t, jwt.SigningMethodNone, testClaims(userID), jwt.UnsafeAllowNoneSignatureType, "")
+		}},
+		{"garbage", func() string { return "not.a.token" }},
+	}
+	for _, tt := range tests {
+		if _, err := v.Verify(context.Background(), tt.token()); !errors.Is(err, ErrInvalidToken) {
+			t.Errorf("%s: error = %v, want ErrInvalidToken", tt.name, err)
+		}
+	}
+}
+
+func TestTokenRolesAreIgnored(t *testing.T) {
+	claims := testClaims(uuid.New())
+	claims["app_metadata"] = map[string]any{"roles": []map[string]any{{"role": "admin"}}}
+
+	identity, err := NewHMACVerifier(testSecret).Verify(context.Background(), sign(t, jwt.SigningMethodHS256, claims, testSecret, ""))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if identity.Roles != nil {
+		t.Errorf("roles = %v, want them left for the role store", identity.Roles)
+	}
+}
+
+func b64(b []byte) string {
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+// jwksServer serves keys as a JWKS and counts how often it is fetched.
+func jwksServer(t *testing.T, keys *[]jwk) (*httptest.Server, *atomic.Int32) {
+	t.Helper()
+	var fetches atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fetches.Add(1)
+		json.NewEncoder(w).Encode(map[string]any{"keys": *keys})
+	}))
+	t.Cleanup(srv.Close)
+	return srv, &fetches
+}
+
+func TestJWKSVerifier(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	keys := []jwk{
+		{Kid: "rsa-1", Kty: "RSA", N: b64(rsaKey.N.Bytes()), E: b64(big.NewInt(int64(rsaKey.E)).Bytes())},
+		{Kid: "ec-1", Kty: "EC", Crv: "P-256", X: b64(ecKey.X.FillBytes(make([]byte, 32))), Y: b64(ecKey.Y.FillBytes(make([]byte, 32)))},
+		{Kid: "okp-1", Kty: "OKP", Crv: "Ed25519", X: b64([]byte("skipped"))},
+	}
+	srv, fetches := jwksServer(t, &keys)
+	v := NewJWKSVerifier(srv.URL)
+	userID := uuid.New()
+
+	for _, token := range []string{
+		sign(t, jwt.SigningMethodRS256, testClaims(userID), rsaKey, "rsa-1"),
+		sign(t, jwt.SigningMethodES256, testClaims(userID), ecKey, "ec-1"),
+	} {
+		identity, err := v.Verify(context.Background(), token)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if identity.UserID != userID {
+			t.Errorf("user = %s, want %s", identity.UserID, userID)
+		}
+	}
+	if n := fetches.Load(); n != 1 {
+		t.Errorf("fetched the key set %d times, want 1", n)
+	}
+
+	otherKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rejected := map[string]string{
+		"signed by another key": sign(t, jwt.SigningMethodRS256, testClaims(userID), otherKey, "rsa-1"),
+		"HS256 with the public key": sign(t, jwt.SigningMethodHS256, testClaims(userID),
+			[]byte(keys[0].N), "rsa-1"),
+		"unknown key id": sign(t, jwt.SigningMethodRS256, testClaims(userID), otherKey, "rsa-2"),
+	}
+	for name, token := range rejected {
+		if _, err := v.Verify(context.Background(), token); !errors.Is(err, ErrInvalidToken) {
+			t.Errorf("%s: error = %v, want ErrInvalidToken", name, err)
+		}
+	}
+	// the unknown key id may not refetch again so soon
+	if n := fetches.Load(); n != 1 {
+		t.Errorf("fetched the key set %d times, want 1", n)
+	}
+}
+
+func TestJWKSVerifierPicksUpRotatedKeys(t *testing.T) {
+	oldKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	newKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keys := []jwk{{Kid: "old", Kty: "RSA", N: b64(oldKey.N.Bytes()), E: b64(big.NewInt(int64(oldKey.E)).Bytes())}}
+	srv, _ := jwksServer(t, &keys)
+	v := NewJWKSVerifier(srv.URL)
+	userID := uuid.New()
+
+	if _, err := v.Verify(context.Background(), sign(t, jwt.SigningMethodRS256, testClaims(userID), oldKey, "old")); err != nil {
+		t.Fatal(err)
+	}
+
+	keys = append(keys, jwk{Kid: "new", Kty: "RSA", N: b64(newKey.N.Bytes()), E: b64(big.NewInt(int64(newKey.E)).Bytes())})
+	// pretend the last fetch was long enough ago to allow another
+	v.mu.Lock()
+	v.fetchedAt = time.Now().Add(-jwksMinRefresh - time.Second)
+	v.mu.Unlock()
+
+	if _, err := v.Verify(context.Background(), sign(t, jwt.SigningMethodRS256, testClaims(userID), newKey, "new")); err != nil {
+		t.Fatalf("token signed with the rotated key: %v", err)
+	}
+}
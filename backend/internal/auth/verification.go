@@ -0,0 +1,74 @@
+package auth
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// VerificationSource reports whether a user has confirmed their email address.
+type VerificationSource interface {
+	EmailVerified(ctx context.Context, userID uuid.UUID, accessToken string) (bool, error)
+}
+
+// EmailVerifications asks the identity provider whether an address has been
+// confirmed, which for Supabase is the server-set email_confirmed_at. The
+// token's user_metadata can't be used: users can write to it themselves.
+//
+// Confirmed users are cached for the TTL. Unconfirmed ones are cached for
+// only unverifiedTTL, so a client looping with a valid token can't make a
+// provider round trip per request, yet an address works within seconds of
+// its confirmation link being followed. Asking for a new confirmation email
+// drops the cached answer at once.
+type EmailVerifications struct {
+	provider   IdentityProvider
+	cache      *ttlCache[bool]
+	unverified *ttlCache[string]
+}
+
+// unverifiedTTL is how long an unconfirmed address is remembered.
+const unverifiedTTL = 5 * time.Second
+
+func NewEmailVerifications(provider IdentityProvider, ttl time.Duration) *EmailVerifications {
+	return &EmailVerifications{
+		provider:   provider,
+		cache:      newTTLCache[bool](ttl),
+		unverified: newTTLCache[string](min(ttl, unverifiedTTL)),
+	}
+}
+
+// EmailVerified reports whether userID, who holds accessToken, has confirmed
+// their email address.
+func (v *EmailVerifications) EmailVerified(ctx context.Context, userID uuid.UUID, accessToken string) (bool, error) {
+	if _, ok := v.cache.get(userID); ok {
+		return true, nil
+	}
+	if _, ok := v.unverified.get(userID); ok {
+		return false, nil
+	}
+
+	user, err := v.provider.GetUser(ctx, accessToken)
+	if err != nil {
+		return false, err
+	}
+	if user.ID != userID {
+		return false, fmt.Errorf("%w: token belongs to another user", ErrInvalidToken)
+	}
+
+	if user.EmailVerified {
+		v.cache.set(userID, true)
+	} else {
+		v.unverified.set(userID, strings.ToLower(user.Email))
+	}
+	return user.EmailVerified, nil
+}
+
+// Forget drops a cached unconfirmed answer for email, so the account is
+// checked with the provider again on its next request.
+func (v *EmailVerifications) Forget(email string) {
+	email = strings.ToLower(email)
+	v.unverified.invalidateWhere(func(cached string) bool { return cached == email })
+}
@@ -0,0 +1,275 @@
+// Package dashers handles dasher applications and their review by admins.
+package dashers
+
+import (
+	"campusDoordash/internal/auth"
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type ApplicationStatus string
+
+const (
+	StatusPendingReview ApplicationStatus = "pending_review"
+	StatusApproved      ApplicationStatus = "approved"
+	StatusRejected      ApplicationStatus = "rejected"
+	StatusSuspended     ApplicationStatus = "suspended"
+)
+
+type TransportMode string
+
+const (
+	TransportWalking TransportMode = "walking"
+	TransportBike    TransportMode = "bike"
+	TransportScooter TransportMode = "scooter"
+	TransportCar     TransportMode = "car"
+)
+
+func (m TransportMode) IsValid() bool {
+	switch m {
+	case TransportWalking, TransportBike, TransportScooter, TransportCar:
+		return true
+	}
+	return false
+}
+
+// Decision is what an admin does with an application.
+type Decision string
+
+const (
+	DecisionApprove Decision = "approve"
+	DecisionReject  Decision = "reject"
+	DecisionSuspend Decision = "suspend"
+)
+
+// reviews lists, for each decision, the statuses it can be made from and the
+// status it leads to. Approving a suspended dasher reinstates them.
+var reviews = map[Decision]struct {
+	from []ApplicationStatus
+	to   ApplicationStatus
+}{
+	DecisionApprove: {from: []ApplicationStatus{StatusPendingReview, StatusSuspended}, to: StatusApproved},
+	DecisionReject:  {from: []ApplicationStatus{StatusPendingReview}, to: StatusRejected},
+	DecisionSuspend: {from: []ApplicationStatus{StatusApproved}, to: StatusSuspended},
+}
+
+var (
+	ErrApplicationNotFound  = errors.New("dasher application not found")
+	ErrApplicationExists    = errors.New("you already have a dasher application that is pending or approved")
+	ErrInvalidTransportMode = errors.New("transport_mode must be one of walking, bike, scooter or car")
+	ErrInvalidDecision      = errors.New("decision must be approve, reject or suspend")
+)
+
+// ReviewError is returned when a decision can't be made from the
+// application's current status.
+type ReviewError struct {
+	Decision Decision
+	Status   ApplicationStatus
+}
+
+func (e *ReviewError) Error() string {
+	return fmt.Sprintf("cannot %s an application that is %s", e.Decision, e.Status)
+}
+
+type Application struct {
+	UserID        uuid.UUID         `json:"user_id"`
+	Email         string            `json:"email"`
+	StudentID     *string           `json:"student_id"`
+	Availability  *string           `json:"availability"`
+	TransportMode *TransportMode    `json:"transport_mode"`
+	Status        ApplicationStatus `json:"status"`
+	ReviewReason  *string           `json:"review_reason,omitempty"`
+	ReviewedBy    *uuid.UUID        `json:"reviewed_by,omitempty"`
+	ReviewedAt    *time.Time        `json:"reviewed_at,omitempty"`
+	CreatedAt     time.Time         `json:"created_at"`
+	UpdatedAt     time.Time         `json:"updated_at"`
+}
+
+type ApplicationRequest struct {
+	StudentID     string        `json:"student_id" binding:"required"`
+	Availability  string        `json:"availability" binding:"required"`
+	TransportMode TransportMode `json:"transport_mode" binding:"required"`
+}
+
+const applicationColumns = `user_id, email, student_id, availability, transport_mode, status,
+	review_reason, reviewed_by, reviewed_at, created_at, updated_at`
+
+func scanApplication(row pgx.Row) (Application, error) {
+	var a Application
+	err := row.Scan(
+		&a.UserID,
+		&a.Email,
+		&a.StudentID,
+		&a.Availability,
+		&a.TransportMode,
+		&a.Status,
+		&a.ReviewReason,
+		&a.ReviewedBy,
+		&a.ReviewedAt,
+		&a.CreatedAt,
+		&a.UpdatedAt,
+	)
+	return a, err
+}
+
+type DasherService struct {
+	conn     *pgxpool.Pool
+	roles    *auth.RoleStore
+	sessions *auth.SessionStore
+}
+
+func NewDasherService(conn *pgxpool.Pool, roles *auth.RoleStore, sessions *auth.SessionStore) *DasherService {
+	return &DasherService{conn: conn, roles: roles, sessions: sessions}
+}
+
+// Apply submits a dasher application for review. A rejected applicant can
+// apply again; anyone else with an application gets ErrApplicationExists.
+func (s *DasherService) Apply(ctx context.Context, userID uuid.UUID, email string, req ApplicationRequest) (*Application, error) {
+	if !req.TransportMode.IsValid() {
+		return nil, ErrInvalidTransportMode
+	}
+
+	a, err := scanApplication(s.conn.QueryRow(ctx, `
+		INSERT INTO dasher_applications (user_id, email, student_id, availability, transport_mode)
+		VALUES ($1, $2, $3, $4, $5)
+		ON CONFLICT (user_id) DO UPDATE
+		SET student_id = EXCLUDED.student_id, availability = EXCLUDED.availability,
+			transport_mode = EXCLUDED.transport_mode, status = 'pending_review',
+			review_reason = NULL, reviewed_by = NULL, reviewed_at = NULL, updated_at = NOW()
+		WHERE dasher_applications.status = 'rejected'
+		RETURNING `+applicationColumns,
+		userID, email, req.StudentID, req.Availability, req.TransportMode))
+
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrApplicationExists
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &a, nil
+}
+
+func (s *DasherService) GetApplication(ctx context.Context, userID uuid.UUID) (*Application, error) {
+	a, err := scanApplication(s.conn.QueryRow(ctx,
+		"SELECT "+applicationColumns+" FROM dasher_applications WHERE user_id = $1", userID))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrApplicationNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &a, nil
+}
+
+// ListApplications returns applications oldest first, optionally only those with status.
+func (s *DasherService) ListApplications(ctx context.Context, status ApplicationStatus, limit int) ([]Application, error) {
+	rows, err := s.conn.Query(ctx, `
+		SELECT `+applicationColumns+`
+		FROM dasher_applications
+		WHERE $1 = '' OR status = $1
+		ORDER BY created_at
+		LIMIT $2
+	`, status, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	applications := []Application{}
+	for rows.Next() {
+		a, err := scanApplication(rows)
+		if err != nil {
+			return nil, err
+		}
+		applications = append(applications, a)
+	}
+	return applications, rows.Err()
+}
+
+// Review applies an admin's decision. Approving gives the applicant the
+// dasher role and a dashers row; suspending takes the role away and signs
+// the dasher out everywhere, so they are locked out of every dasher endpoint
+// at once.
+func (s *DasherService) Review(ctx context.Context, userID uuid.UUID, decision Decision, reviewerID uuid.UUID, reason string) (*Application, error) {
+	review, ok := reviews[decision]
+	if !ok {
+		return nil, ErrInvalidDecision
+	}
+
+	tx, err := s.conn.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback(ctx)
+
+	var status ApplicationStatus
+	var email string
+	var transportMode *TransportMode
+	err = tx.QueryRow(ctx, `
+		SELECT status, email, transport_mode FROM dasher_applications WHERE user_id = $1 FOR UPDATE
+	`, userID).Scan(&status, &email, &transportMode)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrApplicationNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	allowed := false
+	for _, from := range review.from {
+		if from == status {
+			allowed = true
+		}
+	}
+	if !allowed {
+		return nil, &ReviewError{Decision: decision, Status: status}
+	}
+
+	var reviewReason *string
+	if reason != "" {
+		reviewReason = &reason
+	}
+	a, err := scanApplication(tx.QueryRow(ctx, `
+		UPDATE dasher_applications
+		SET status = $1, review_reason = $2, reviewed_by = $3, reviewed_at = NOW(), updated_at = NOW()
+		WHERE user_id = $4
+		RETURNING `+applicationColumns,
+		review.to, reviewReason, reviewerID, userID))
+	if err != nil {
+		return nil, err
+	}
+
+	switch review.to {
+	case StatusApproved:
+		if _, err := tx.Exec(ctx, `
+			INSERT INTO dashers (dasher_id, email, transport_mode) VALUES ($1, $2, $3) ON CONFLICT DO NOTHING
+		`, userID, email, transportMode); err != nil {
+			return nil, fmt.Errorf("failed to create dasher record: %v", err)
+		}
+		err = s.roles.GrantTx(ctx, tx, userID, auth.RoleDasher, nil)
+	case StatusSuspended:
+		err = s.roles.RevokeTx(ctx, tx, userID, auth.RoleDasher)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to update dasher role: %v", err)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return nil, err
+	}
+	s.roles.Invalidate(userID)
+
+	if review.to == StatusSuspended {
+		if err := s.sessions.RevokeAll(ctx, userID, &reviewerID, "dasher suspended"); err != nil {
+			return nil, fmt.Errorf("dasher suspended but failed to sign them out: %v", err)
+		}
+	}
+
+	return &a, nil
+}
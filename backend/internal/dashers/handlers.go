@@ -0,0 +1,151 @@
+package dashers
+
+import (
+	"campusDoordash/internal/auth"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+type DasherHandlers struct {
+	service *DasherService
+}
+
+func NewDasherHandlers(service *DasherService) *DasherHandlers {
+	return &DasherHandlers{service: service}
+}
+
+// ApplyHandler serves POST /api/dashers/application.
+func (h *DasherHandlers) ApplyHandler(c *gin.Context) {
+	identity, ok := c.Get("user")
+	user, _ := identity.(*auth.Identity)
+	if !ok || user == nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "invalid user"})
+		return
+	}
+
+	var req ApplicationRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "student_id, availability and transport_mode are required"})
+		return
+	}
+
+	application, err := h.service.Apply(c.Request.Context(), user.UserID, user.Email, req)
+	switch {
+	case errors.Is(err, ErrInvalidTransportMode):
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error()})
+		return
+	case errors.Is(err, ErrApplicationExists):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	case err != nil:
+		log.Printf("failed to submit dasher application: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to submit application"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"application": application})
+}
+
+// GetMyApplicationHandler serves GET /api/dashers/application.
+func (h *DasherHandlers) GetMyApplicationHandler(c *gin.Context) {
+	userID, err := uuid.Parse(c.GetString("user_id"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "invalid user id"})
+		return
+	}
+
+	application, err := h.service.GetApplication(c.Request.Context(), userID)
+	if errors.Is(err, ErrApplicationNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		log.Printf("failed to fetch dasher application: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch application"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"application": application})
+}
+
+// ListApplicationsHandler serves GET /admin/dashers/applications?status=pending_review&limit=50.
+func (h *DasherHandlers) ListApplicationsHandler(c *gin.Context) {
+	status := ApplicationStatus(c.Query("status"))
+	switch status {
+	case "", StatusPendingReview, StatusApproved, StatusRejected, StatusSuspended:
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid status"})
+		return
+	}
+
+	limit := 50
+	if v := c.Query("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > 200 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "limit must be between 1 and 200"})
+			return
+		}
+		limit = n
+	}
+
+	applications, err := h.service.ListApplications(c.Request.Context(), status, limit)
+	if err != nil {
+		log.Printf("failed to list dasher applications %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to list applications"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"applications": applications,
+		"count":        len(applications),
+	})
+}
+
+// ReviewHandler serves POST /admin/dashers/:id/approve, /reject and /suspend,
+// where :id is the applicant's user ID.
+func (h *DasherHandlers) ReviewHandler(decision Decision) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, err := uuid.Parse(c.Param("id"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid user id"})
+			return
+		}
+
+		reviewerID, err := uuid.Parse(c.GetString("user_id"))
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "invalid user id"})
+			return
+		}
+
+		var req struct {
+			Reason string `json:"reason"`
+		}
+		if err := c.ShouldBindJSON(&req); err != nil && !errors.Is(err, io.EOF) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request body"})
+			return
+		}
+
+		application, err := h.service.Review(c.Request.Context(), userID, decision, reviewerID, req.Reason)
+		var reviewErr *ReviewError
+		switch {
+		case errors.Is(err, ErrApplicationNotFound):
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		case errors.As(err, &reviewErr):
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+			return
+		case err != nil:
+			log.Printf("failed to %s dasher application: %v", decision, err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to review application"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"application": application})
+	}
+}
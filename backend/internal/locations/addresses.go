@@ -0,0 +1,318 @@
+package locations
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+)
+
+// MaxSavedAddresses is how many addresses one user can save.
+const MaxSavedAddresses = 10
+
+const (
+	maxLabelLength        = 50
+	maxRoomLength         = 20
+	maxInstructionsLength = 300
+)
+
+var (
+	ErrAddressNotFound     = errors.New("saved address not found")
+	ErrTooManyAddresses    = fmt.Errorf("you can save at most %d addresses", MaxSavedAddresses)
+	ErrInvalidLabel        = fmt.Errorf("label is required and must be at most %d characters", maxLabelLength)
+	ErrInvalidRoom         = fmt.Errorf("room must be at most %d characters", maxRoomLength)
+	ErrInvalidInstructions = fmt.Errorf("instructions must be at most %d characters", maxInstructionsLength)
+)
+
+type SavedAddress struct {
+	AddressID      uuid.UUID  `json:"address_id"`
+	UserID         uuid.UUID  `json:"user_id"`
+	Label          string     `json:"label"`
+	LocationID     uuid.UUID  `json:"location_id"`
+	DropOffPointID *uuid.UUID `json:"drop_off_point_id"`
+	Room           *string    `json:"room"`
+	Instructions   *string    `json:"instructions"`
+	IsDefault      bool       `json:"is_default"`
+	// DeliveryLabel is what orders to this address show, e.g.
+	// "Potomac Hall, Front desk, Room 214".
+	DeliveryLabel string    `json:"delivery_label"`
+	CreatedAt     time.Time `json:"created_at"`
+	UpdatedAt     time.Time `json:"updated_at"`
+}
+
+type AddressRequest struct {
+	Label          string     `json:"label"`
+	LocationID     uuid.UUID  `json:"location_id" binding:"required"`
+	DropOffPointID *uuid.UUID `json:"drop_off_point_id"`
+	Room           string     `json:"room"`
+	Instructions   string     `json:"instructions"`
+	IsDefault      bool       `json:"is_default"`
+}
+
+const addressQuery = `
+	SELECT a.address_id, a.user_id, a.label, a.location_id, a.drop_off_point_id, a.room,
+		a.instructions, a.is_default, a.created_at, a.updated_at, l.location_name, p.name
+	FROM saved_addresses a
+	JOIN locations l ON l.location_id = a.location_id
+	LEFT JOIN drop_off_points p ON p.drop_off_point_id = a.drop_off_point_id
+`
+
+func scanAddress(row pgx.Row) (SavedAddress, error) {
+	var a SavedAddress
+	var location string
+	var point *string
+	err := row.Scan(
+		&a.AddressID,
+		&a.UserID,
+		&a.Label,
+		&a.LocationID,
+		&a.DropOffPointID,
+		&a.Room,
+		&a.Instructions,
+		&a.IsDefault,
+		&a.CreatedAt,
+		&a.UpdatedAt,
+		&location,
+		&point,
+	)
+	a.DeliveryLabel = renderLabel(location, point, a.Room)
+	return a, err
+}
+
+// renderLabel joins a building, drop-off point and room into the label
+// shown to dashers and stored on orders.
+func renderLabel(location string, point, room *string) string {
+	parts := []string{location}
+	if point != nil {
+		parts = append(parts, *point)
+	}
+	if room != nil {
+		parts = append(parts, "Room "+*room)
+	}
+	return strings.Join(parts, ", ")
+}
+
+// ListAddresses returns userID's saved addresses, the default one first.
+func (s *LocationService) ListAddresses(ctx context.Context, userID uuid.UUID) ([]SavedAddress, error) {
+	rows, err := s.conn.Query(ctx, addressQuery+`
+		WHERE a.user_id = $1
+		ORDER BY a.is_default DESC, a.created_at
+	`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	addresses := []SavedAddress{}
+	for rows.Next() {
+		a, err := scanAddress(rows)
+		if err != nil {
+			return nil, err
+		}
+		addresses = append(addresses, a)
+	}
+	return addresses, rows.Err()
+}
+
+// GetAddress returns one of userID's saved addresses. Other users' addresses
+// are reported as not found.
+func (s *LocationService) GetAddress(ctx context.Context, userID, addressID uuid.UUID) (*SavedAddress, error) {
+	a, err := scanAddress(s.conn.QueryRow(ctx, addressQuery+`
+		WHERE a.address_id = $1 AND a.user_id = $2
+	`, addressID, userID))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrAddressNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &a, nil
+}
+
+// CreateAddress saves an address for userID. The first address saved
+// becomes the default.
+func (s *LocationService) CreateAddress(ctx context.Context, userID uuid.UUID, req AddressRequest) (*SavedAddress, error) {
+	room, instructions, err := req.normalize()
+	if err != nil {
+		return nil, err
+	}
+	if _, err := s.resolve(ctx, req.LocationID, req.DropOffPointID, room, nil); err != nil {
+		return nil, err
+	}
+
+	tx, err := s.conn.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback(ctx)
+
+	// concurrent saves can't pass the limit or both become the first default
+	if err := lockAddresses(ctx, tx, userID); err != nil {
+		return nil, err
+	}
+	var count int
+	err = tx.QueryRow(ctx, `
+		SELECT COUNT(*) FROM saved_addresses WHERE user_id = $1
+	`, userID).Scan(&count)
+	if err != nil {
+		return nil, err
+	}
+	if count >= MaxSavedAddresses {
+		return nil, ErrTooManyAddresses
+	}
+
+	isDefault := req.IsDefault || count == 0
+	if isDefault {
+		if err := clearDefault(ctx, tx, userID); err != nil {
+			return nil, err
+		}
+	}
+
+	var addressID uuid.UUID
+	err = tx.QueryRow(ctx, `
+		INSERT INTO saved_addresses (user_id, label, location_id, drop_off_point_id, room, instructions, is_default)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		RETURNING address_id
+	`, userID, req.Label, req.LocationID, req.DropOffPointID, room, instructions, isDefault).Scan(&addressID)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return nil, err
+	}
+	return s.GetAddress(ctx, userID, addressID)
+}
+
+// UpdateAddress replaces one of userID's saved addresses with req.
+func (s *LocationService) UpdateAddress(ctx context.Context, userID, addressID uuid.UUID, req AddressRequest) (*SavedAddress, error) {
+	room, instructions, err := req.normalize()
+	if err != nil {
+		return nil, err
+	}
+	if _, err := s.resolve(ctx, req.LocationID, req.DropOffPointID, room, nil); err != nil {
+		return nil, err
+	}
+
+	tx, err := s.conn.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback(ctx)
+
+	if req.IsDefault {
+		if err := lockAddresses(ctx, tx, userID); err != nil {
+			return nil, err
+		}
+		if err := clearDefault(ctx, tx, userID); err != nil {
+			return nil, err
+		}
+	}
+
+	tag, err := tx.Exec(ctx, `
+		UPDATE saved_addresses
+		SET label = $1, location_id = $2, drop_off_point_id = $3, room = $4, instructions = $5,
+			is_default = is_default OR $6, updated_at = NOW()
+		WHERE address_id = $7 AND user_id = $8
+	`, req.Label, req.LocationID, req.DropOffPointID, room, instructions, req.IsDefault, addressID, userID)
+	if err != nil {
+		return nil, err
+	}
+	if tag.RowsAffected() == 0 {
+		return nil, ErrAddressNotFound
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return nil, err
+	}
+	return s.GetAddress(ctx, userID, addressID)
+}
+
+// DeleteAddress removes one of userID's saved addresses. Orders placed to it
+// keep their own copy of the location.
+func (s *LocationService) DeleteAddress(ctx context.Context, userID, addressID uuid.UUID) error {
+	tag, err := s.conn.Exec(ctx, `
+		DELETE FROM saved_addresses WHERE address_id = $1 AND user_id = $2
+	`, addressID, userID)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrAddressNotFound
+	}
+	return nil
+}
+
+// SetDefaultAddressTx makes addressID, one of userID's saved addresses, their
+// default inside tx. It returns ErrAddressNotFound for anyone else's address.
+func (s *LocationService) SetDefaultAddressTx(ctx context.Context, tx pgx.Tx, userID, addressID uuid.UUID) error {
+	if err := lockAddresses(ctx, tx, userID); err != nil {
+		return err
+	}
+	if err := clearDefault(ctx, tx, userID); err != nil {
+		return err
+	}
+	tag, err := tx.Exec(ctx, `
+		UPDATE saved_addresses SET is_default = TRUE, updated_at = NOW()
+		WHERE address_id = $1 AND user_id = $2
+	`, addressID, userID)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrAddressNotFound
+	}
+	return nil
+}
+
+// lockAddresses serializes changes to userID's saved addresses until tx
+// ends. Row locks aren't enough: a user with no addresses has no rows to
+// lock, and two first saves would both try to become the default.
+func lockAddresses(ctx context.Context, tx pgx.Tx, userID uuid.UUID) error {
+	_, err := tx.Exec(ctx, `
+		SELECT pg_advisory_xact_lock(hashtextextended('saved_addresses:' || $1::text, 0))
+	`, userID)
+	return err
+}
+
+func clearDefault(ctx context.Context, tx pgx.Tx, userID uuid.UUID) error {
+	_, err := tx.Exec(ctx, `
+		UPDATE saved_addresses SET is_default = FALSE, updated_at = NOW()
+		WHERE user_id = $1 AND is_default
+	`, userID)
+	return err
+}
+
+// normalize trims req and returns its optional room and instructions.
+func (r *AddressRequest) normalize() (room, instructions *string, err error) {
+	r.Label = strings.TrimSpace(r.Label)
+	if r.Label == "" || len(r.Label) > maxLabelLength {
+		return nil, nil, ErrInvalidLabel
+	}
+	room, err = normalizeRoom(r.Room)
+	if err != nil {
+		return nil, nil, err
+	}
+	if v := strings.TrimSpace(r.Instructions); v != "" {
+		if len(v) > maxInstructionsLength {
+			return nil, nil, ErrInvalidInstructions
+		}
+		instructions = &v
+	}
+	return room, instructions, nil
+}
+
+func normalizeRoom(room string) (*string, error) {
+	room = strings.TrimSpace(room)
+	if room == "" {
+		return nil, nil
+	}
+	if len(room) > maxRoomLength {
+		return nil, ErrInvalidRoom
+	}
+	return &room, nil
+}
@@ -0,0 +1,83 @@
+package locations
+
+import (
+	"context"
+	"os"
+	"sync"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// testDB connects to the Postgres at TEST_DB_STRING, which must already have
+// the app's schema and migrations. Tests that need it are skipped without one.
+func testDB(t *testing.T) *pgxpool.Pool {
+	t.Helper()
+	connStr := os.Getenv("TEST_DB_STRING")
+	if connStr == "" {
+		t.Skip("TEST_DB_STRING is not set")
+	}
+	conn, err := pgxpool.New(context.Background(), connStr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(conn.Close)
+	return conn
+}
+
+func TestCreateAddressConcurrentFirstSaves(t *testing.T) {
+	conn := testDB(t)
+	ctx := context.Background()
+	s := NewLocationService(conn)
+
+	var locationID uuid.UUID
+	err := conn.QueryRow(ctx, `
+		INSERT INTO locations (location_name) VALUES ('Address Test Hall') RETURNING location_id
+	`).Scan(&locationID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	userID := uuid.New()
+	t.Cleanup(func() {
+		conn.Exec(ctx, `DELETE FROM saved_addresses WHERE user_id = $1`, userID)
+		conn.Exec(ctx, `DELETE FROM locations WHERE location_id = $1`, locationID)
+	})
+
+	const saves = 5
+	var wg sync.WaitGroup
+	errs := make(chan error, saves)
+	start := make(chan struct{})
+	for i := 0; i < saves; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			<-start
+			_, err := s.CreateAddress(ctx, userID, AddressRequest{Label: "Dorm", LocationID: locationID})
+			errs <- err
+		}()
+	}
+	close(start)
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		if err != nil {
+			t.Errorf("concurrent save failed: %v", err)
+		}
+	}
+
+	addresses, err := s.ListAddresses(ctx, userID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defaults := 0
+	for _, a := range addresses {
+		if a.IsDefault {
+			defaults++
+		}
+	}
+	if len(addresses) != saves || defaults != 1 {
+		t.Errorf("%d addresses with %d defaults, want %d with 1", len(addresses), defaults, saves)
+	}
+}
@@ -0,0 +1,160 @@
+package locations
+
+import (
+	"errors"
+	"log"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+type LocationHandlers struct {
+	service *LocationService
+}
+
+func NewLocationHandlers(service *LocationService) *LocationHandlers {
+	return &LocationHandlers{service: service}
+}
+
+// ListLocationsHandler serves GET /api/locations?type=residence.
+func (h *LocationHandlers) ListLocationsHandler(c *gin.Context) {
+	locations, err := h.service.ListLocations(c.Request.Context(), c.Query("type"))
+	if err != nil {
+		log.Printf("failed to list locations: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to list locations"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"locations": locations,
+		"count":     len(locations),
+	})
+}
+
+// GetLocationHandler serves GET /api/locations/:id with the building's drop-off points.
+func (h *LocationHandlers) GetLocationHandler(c *gin.Context) {
+	locationID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid location id"})
+		return
+	}
+
+	location, err := h.service.GetLocation(c.Request.Context(), locationID)
+	if errors.Is(err, ErrLocationNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		log.Printf("failed to fetch location: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch location"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"location": location})
+}
+
+// ListAddressesHandler serves GET /api/me/addresses.
+func (h *LocationHandlers) ListAddressesHandler(c *gin.Context) {
+	userID, err := uuid.Parse(c.GetString("user_id"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "invalid user id"})
+		return
+	}
+
+	addresses, err := h.service.ListAddresses(c.Request.Context(), userID)
+	if err != nil {
+		log.Printf("failed to list saved addresses: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to list addresses"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"addresses": addresses})
+}
+
+// CreateAddressHandler serves POST /api/me/addresses.
+func (h *LocationHandlers) CreateAddressHandler(c *gin.Context) {
+	userID, err := uuid.Parse(c.GetString("user_id"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "invalid user id"})
+		return
+	}
+
+	var req AddressRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "label and location_id are required"})
+		return
+	}
+
+	address, err := h.service.CreateAddress(c.Request.Context(), userID, req)
+	if respondAddressError(c, err) {
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"address": address})
+}
+
+// UpdateAddressHandler serves PUT /api/me/addresses/:id.
+func (h *LocationHandlers) UpdateAddressHandler(c *gin.Context) {
+	userID, err := uuid.Parse(c.GetString("user_id"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "invalid user id"})
+		return
+	}
+	addressID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid address id"})
+		return
+	}
+
+	var req AddressRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "label and location_id are required"})
+		return
+	}
+
+	address, err := h.service.UpdateAddress(c.Request.Context(), userID, addressID, req)
+	if respondAddressError(c, err) {
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"address": address})
+}
+
+// DeleteAddressHandler serves DELETE /api/me/addresses/:id.
+func (h *LocationHandlers) DeleteAddressHandler(c *gin.Context) {
+	userID, err := uuid.Parse(c.GetString("user_id"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "invalid user id"})
+		return
+	}
+	addressID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid address id"})
+		return
+	}
+
+	if respondAddressError(c, h.service.DeleteAddress(c.Request.Context(), userID, addressID)) {
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// respondAddressError writes the response for err and reports whether it did.
+func respondAddressError(c *gin.Context, err error) bool {
+	switch {
+	case err == nil:
+		return false
+	case errors.Is(err, ErrAddressNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, ErrTooManyAddresses):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	case IsDeliveryError(err):
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error()})
+	default:
+		log.Printf("failed to save address: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to save address"})
+	}
+	return true
+}
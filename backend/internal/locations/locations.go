@@ -0,0 +1,204 @@
+// Package locations serves campus buildings, their drop-off points and the
+// delivery addresses users save against them.
+package locations
+
+import (
+	"context"
+	"errors"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+var (
+	ErrLocationNotFound         = errors.New("delivery location not found")
+	ErrLocationNotDeliverable   = errors.New("orders can't be delivered to this location")
+	ErrDropOffPointNotFound     = errors.New("drop-off point not found at this location")
+	ErrDeliveryLocationRequired = errors.New("delivery_location_id or saved_address_id is required")
+)
+
+// IsDeliveryError reports whether err means the delivery location or
+// address details sent by the client are unusable, as opposed to a server
+// failure.
+func IsDeliveryError(err error) bool {
+	for _, target := range []error{
+		ErrLocationNotFound, ErrLocationNotDeliverable, ErrDropOffPointNotFound,
+		ErrDeliveryLocationRequired, ErrAddressNotFound,
+		ErrInvalidLabel, ErrInvalidRoom, ErrInvalidInstructions,
+	} {
+		if errors.Is(err, target) {
+			return true
+		}
+	}
+	return false
+}
+
+type Location struct {
+	LocationID    uuid.UUID      `json:"location_id"`
+	Name          string         `json:"location_name"`
+	Type          *string        `json:"location_type"`
+	Latitude      *float64       `json:"latitude"`
+	Longitude     *float64       `json:"longitude"`
+	Deliverable   bool           `json:"deliverable"`
+	DropOffPoints []DropOffPoint `json:"drop_off_points,omitempty"`
+}
+
+type DropOffPoint struct {
+	DropOffPointID uuid.UUID `json:"drop_off_point_id"`
+	LocationID     uuid.UUID `json:"location_id"`
+	Name           string    `json:"name"`
+	Kind           string    `json:"kind"`
+	Latitude       *float64  `json:"latitude"`
+	Longitude      *float64  `json:"longitude"`
+	Instructions   *string   `json:"instructions,omitempty"`
+}
+
+// DeliveryRequest is where a customer wants an order brought: either one of
+// their saved addresses or a location with an optional drop-off point and room.
+type DeliveryRequest struct {
+	SavedAddressID     *uuid.UUID `json:"saved_address_id,omitempty"`
+	DeliveryLocationID *uuid.UUID `json:"delivery_location_id,omitempty"`
+	DropOffPointID     *uuid.UUID `json:"drop_off_point_id,omitempty"`
+	DeliveryRoom       string     `json:"delivery_room,omitempty"`
+}
+
+// Delivery is a checked DeliveryRequest, ready to be stored on an order.
+type Delivery struct {
+	LocationID     uuid.UUID
+	DropOffPointID *uuid.UUID
+	Room           *string
+	Label          string
+	// Instructions are the saved address's, if the request used one.
+	Instructions *string
+}
+
+type LocationService struct {
+	conn *pgxpool.Pool
+}
+
+func NewLocationService(conn *pgxpool.Pool) *LocationService {
+	return &LocationService{conn: conn}
+}
+
+// ListLocations returns campus buildings by name, optionally only those of locationType.
+func (s *LocationService) ListLocations(ctx context.Context, locationType string) ([]Location, error) {
+	rows, err := s.conn.Query(ctx, `
+		SELECT location_id, location_name, location_type, latitude, longitude, deliverable
+		FROM locations
+		WHERE $1 = '' OR location_type = $1
+		ORDER BY location_name
+	`, locationType)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	locations := []Location{}
+	for rows.Next() {
+		var l Location
+		if err := rows.Scan(&l.LocationID, &l.Name, &l.Type, &l.Latitude, &l.Longitude, &l.Deliverable); err != nil {
+			return nil, err
+		}
+		locations = append(locations, l)
+	}
+	return locations, rows.Err()
+}
+
+// GetLocation returns a building with its active drop-off points.
+func (s *LocationService) GetLocation(ctx context.Context, locationID uuid.UUID) (*Location, error) {
+	var l Location
+	err := s.conn.QueryRow(ctx, `
+		SELECT location_id, location_name, location_type, latitude, longitude, deliverable
+		FROM locations
+		WHERE location_id = $1
+	`, locationID).Scan(&l.LocationID, &l.Name, &l.Type, &l.Latitude, &l.Longitude, &l.Deliverable)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrLocationNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := s.conn.Query(ctx, `
+		SELECT drop_off_point_id, location_id, name, kind, latitude, longitude, instructions
+		FROM drop_off_points
+		WHERE location_id = $1 AND active
+		ORDER BY name
+	`, locationID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	l.DropOffPoints = []DropOffPoint{}
+	for rows.Next() {
+		var p DropOffPoint
+		if err := rows.Scan(&p.DropOffPointID, &p.LocationID, &p.Name, &p.Kind, &p.Latitude, &p.Longitude, &p.Instructions); err != nil {
+			return nil, err
+		}
+		l.DropOffPoints = append(l.DropOffPoints, p)
+	}
+	return &l, rows.Err()
+}
+
+// ResolveDelivery checks req for customerID and renders its label. A saved
+// address must belong to the customer; a location must exist and take
+// deliveries, and a drop-off point must be an active one in that location.
+func (s *LocationService) ResolveDelivery(ctx context.Context, customerID uuid.UUID, req DeliveryRequest) (*Delivery, error) {
+	if req.SavedAddressID != nil {
+		address, err := s.GetAddress(ctx, customerID, *req.SavedAddressID)
+		if err != nil {
+			return nil, err
+		}
+		return s.resolve(ctx, address.LocationID, address.DropOffPointID, address.Room, address.Instructions)
+	}
+
+	if req.DeliveryLocationID == nil {
+		return nil, ErrDeliveryLocationRequired
+	}
+	room, err := normalizeRoom(req.DeliveryRoom)
+	if err != nil {
+		return nil, err
+	}
+	return s.resolve(ctx, *req.DeliveryLocationID, req.DropOffPointID, room, nil)
+}
+
+func (s *LocationService) resolve(ctx context.Context, locationID uuid.UUID, dropOffPointID *uuid.UUID, room, instructions *string) (*Delivery, error) {
+	var name string
+	var deliverable bool
+	err := s.conn.QueryRow(ctx, `
+		SELECT location_name, deliverable FROM locations WHERE location_id = $1
+	`, locationID).Scan(&name, &deliverable)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrLocationNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	if !deliverable {
+		return nil, ErrLocationNotDeliverable
+	}
+
+	var point *string
+	if dropOffPointID != nil {
+		err := s.conn.QueryRow(ctx, `
+			SELECT name FROM drop_off_points
+			WHERE drop_off_point_id = $1 AND location_id = $2 AND active
+		`, *dropOffPointID, locationID).Scan(&point)
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrDropOffPointNotFound
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return &Delivery{
+		LocationID:     locationID,
+		DropOffPointID: dropOffPointID,
+		Room:           room,
+		Label:          renderLabel(name, point, room),
+		Instructions:   instructions,
+	}, nil
+}
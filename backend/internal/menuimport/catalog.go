@@ -0,0 +1,388 @@
+package menuimport
+
+import (
+	"campusDoordash/internal/money"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/google/uuid"
+)
+
+// namespace seeds the UUIDs derived from DineOnCampus IDs. Changing it would
+// make the next import create every row again.
+var namespace = uuid.MustParse("f9fa9f10-be08-4367-a8f5-9ee9d14b501d")
+
+func deriveID(entity Entity, externalID string) uuid.UUID {
+	return uuid.NewSHA1(namespace, []byte(string(entity)+":"+externalID))
+}
+
+type Entity string
+
+const (
+	EntityLocation   Entity = "location"
+	EntityRestaurant Entity = "restaurant"
+	EntityCategory   Entity = "category"
+	EntityFood       Entity = "food"
+)
+
+// Location is a campus building.
+type Location struct {
+	ID         uuid.UUID
+	ExternalID string
+	Name       string
+	Type       *string
+}
+
+type Restaurant struct {
+	ID         uuid.UUID
+	ExternalID string
+	Name       string
+	LocationID *uuid.UUID
+}
+
+// Category is a menu section. Sections with the same name in different
+// periods (Breakfast "Entrees", Lunch "Entrees") become one category.
+type Category struct {
+	ID           uuid.UUID
+	ExternalID   string
+	RestaurantID uuid.UUID
+	Name         string
+	DisplayOrder int
+}
+
+type Food struct {
+	ID           uuid.UUID
+	ExternalID   string
+	RestaurantID uuid.UUID
+	CategoryID   *uuid.UUID
+	Name         string
+	Description  *string
+	Price        *money.Money
+	DisplayOrder int
+}
+
+// Catalog is everything read from one set of DineOnCampus files.
+type Catalog struct {
+	Locations   []Location
+	Restaurants []Restaurant
+	Categories  []Category
+	Foods       []Food
+
+	restaurants map[string]*Restaurant
+	categories  map[string]bool
+	foods       map[string]bool
+	// next display orders per restaurant
+	nextCategory map[uuid.UUID]int
+	nextFood     map[uuid.UUID]int
+}
+
+// Load reads dir/locations.json and every dir/menus/*.json. A menu file
+// belongs to the DineOnCampus location in its location_id field or, without
+// one, to the location ID its file name starts with, e.g.
+// 5b9bf6a5f3eeb60c7a2a4d8e_lunch.json.
+func Load(dir string) (*Catalog, error) {
+	f, err := os.Open(filepath.Join(dir, "locations.json"))
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	locations, err := decodeLocations(f)
+	if err != nil {
+		return nil, fmt.Errorf("locations.json: %v", err)
+	}
+
+	c := newCatalog()
+	if err := c.addLocations(locations); err != nil {
+		return nil, fmt.Errorf("locations.json: %v", err)
+	}
+
+	paths, err := filepath.Glob(filepath.Join(dir, "menus", "*.json"))
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(paths)
+	for _, path := range paths {
+		if err := c.addMenuFile(path); err != nil {
+			return nil, fmt.Errorf("%s: %v", filepath.Base(path), err)
+		}
+	}
+	return c, nil
+}
+
+func newCatalog() *Catalog {
+	return &Catalog{
+		restaurants:  map[string]*Restaurant{},
+		categories:   map[string]bool{},
+		foods:        map[string]bool{},
+		nextCategory: map[uuid.UUID]int{},
+		nextFood:     map[uuid.UUID]int{},
+	}
+}
+
+func (c *Catalog) addLocations(f *locationsFile) error {
+	seen := map[string]bool{}
+	for _, b := range f.Buildings {
+		if b.ID == "" || strings.TrimSpace(b.Name) == "" {
+			return fmt.Errorf("building without an id or name")
+		}
+		location := Location{ID: deriveID(EntityLocation, b.ID), ExternalID: b.ID, Name: strings.TrimSpace(b.Name)}
+		if b.Type != "" {
+			location.Type = &b.Type
+		}
+		c.Locations = append(c.Locations, location)
+
+		for _, l := range b.Locations {
+			if l.ID == "" || strings.TrimSpace(l.Name) == "" {
+				return fmt.Errorf("location in %s without an id or name", b.Name)
+			}
+			if seen[l.ID] {
+				return fmt.Errorf("location %s is listed twice", l.ID)
+			}
+			seen[l.ID] = true
+			c.Restaurants = append(c.Restaurants, Restaurant{
+				ID:         deriveID(EntityRestaurant, l.ID),
+				ExternalID: l.ID,
+				Name:       strings.TrimSpace(l.Name),
+				LocationID: &location.ID,
+			})
+		}
+	}
+	for i := range c.Restaurants {
+		c.restaurants[c.Restaurants[i].ExternalID] = &c.Restaurants[i]
+	}
+	return nil
+}
+
+func (c *Catalog) addMenuFile(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	menu, err := decodeMenu(f)
+	if err != nil {
+		return err
+	}
+
+	locationID := menu.LocationID
+	if locationID == "" {
+		name := strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+		locationID, _, _ = strings.Cut(name, "_")
+	}
+	restaurant, ok := c.restaurants[locationID]
+	if !ok {
+		return fmt.Errorf("location %s is not in locations.json", locationID)
+	}
+	return c.addMenu(restaurant, menu)
+}
+
+// addMenu adds a restaurant's categories and items in display order:
+// periods, then categories, then items, each by sort_order when given.
+// Items already seen in an earlier period or category keep their first place.
+func (c *Catalog) addMenu(restaurant *Restaurant, menu *menuFile) error {
+	periods := append(dcPeriods{}, menu.Menu.Periods...)
+	sort.SliceStable(periods, func(i, j int) bool { return lessSortOrder(periods[i].SortOrder, periods[j].SortOrder) })
+
+	for _, period := range periods {
+		categories := append([]dcCategory{}, period.Categories...)
+		sort.SliceStable(categories, func(i, j int) bool { return lessSortOrder(categories[i].SortOrder, categories[j].SortOrder) })
+
+		for _, dc := range categories {
+			name := strings.TrimSpace(dc.Name)
+			if name == "" {
+				return fmt.Errorf("category %s in period %s has no name", dc.ID, period.Name)
+			}
+			externalID := restaurant.ExternalID + "/" + strings.ToLower(name)
+			categoryID := deriveID(EntityCategory, externalID)
+			if !c.categories[externalID] {
+				c.categories[externalID] = true
+				c.Categories = append(c.Categories, Category{
+					ID:           categoryID,
+					ExternalID:   externalID,
+					RestaurantID: restaurant.ID,
+					Name:         name,
+					DisplayOrder: c.nextCategory[restaurant.ID],
+				})
+				c.nextCategory[restaurant.ID]++
+			}
+
+			items := append([]dcItem{}, dc.Items...)
+			sort.SliceStable(items, func(i, j int) bool { return lessSortOrder(items[i].SortOrder, items[j].SortOrder) })
+			for _, item := range items {
+				if item.ID == "" || strings.TrimSpace(item.Name) == "" {
+					return fmt.Errorf("item in %s without an id or name", name)
+				}
+				if c.foods[item.ID] {
+					continue
+				}
+				c.foods[item.ID] = true
+
+				food := Food{
+					ID:           deriveID(EntityFood, item.ID),
+					ExternalID:   item.ID,
+					RestaurantID: restaurant.ID,
+					CategoryID:   &categoryID,
+					Name:         strings.TrimSpace(item.Name),
+					Price:        item.Price.Amount,
+					DisplayOrder: c.nextFood[restaurant.ID],
+				}
+				if desc := strings.TrimSpace(item.Desc); desc != "" {
+					food.Description = &desc
+				}
+				c.Foods = append(c.Foods, food)
+				c.nextFood[restaurant.ID]++
+			}
+		}
+	}
+	return nil
+}
+
+// lessSortOrder orders items with a sort_order before those without,
+// leaving the rest in file order.
+func lessSortOrder(a, b *int) bool {
+	if a == nil || b == nil {
+		return a != nil && b == nil
+	}
+	return *a < *b
+}
+
+// existingRow is a row already in the database as far as adoption cares.
+// scope is the restaurant for categories and food, and uuid.Nil otherwise.
+type existingRow struct {
+	id         uuid.UUID
+	externalID string
+	name       string
+	scope      uuid.UUID
+}
+
+// match returns the ID of the existing row a catalog row should update: the
+// row with its own ID, else one already tagged with its external ID, else
+// the first untagged row with the same name in the same scope. Untagged rows
+// were created before the importer, by the data_retrieval scripts. claimed
+// keeps two catalog rows from taking the same existing one.
+func match(id uuid.UUID, externalID, name string, scope uuid.UUID, rows []existingRow, claimed map[uuid.UUID]bool) uuid.UUID {
+	for _, r := range rows {
+		if r.id == id {
+			claimed[id] = true
+			return id
+		}
+	}
+	for _, r := range rows {
+		if r.externalID == externalID && !claimed[r.id] {
+			claimed[r.id] = true
+			return r.id
+		}
+	}
+	for _, r := range rows {
+		if r.externalID == "" && r.scope == scope && strings.EqualFold(r.name, name) && !claimed[r.id] {
+			claimed[r.id] = true
+			return r.id
+		}
+	}
+	return id
+}
+
+// sortRows orders rows by ID so that which duplicate gets adopted doesn't
+// depend on map order.
+func sortRows(rows []existingRow) []existingRow {
+	sort.Slice(rows, func(i, j int) bool { return rows[i].id.String() < rows[j].id.String() })
+	return rows
+}
+
+// adoptLocations points c's locations, and the restaurants in them, at the
+// existing rows match picks.
+func (c *Catalog) adoptLocations(existing *Snapshot) {
+	var rows []existingRow
+	for _, l := range existing.Locations {
+		rows = append(rows, existingRow{id: l.ID, externalID: l.ExternalID, name: l.Name})
+	}
+	rows = sortRows(rows)
+	claimed := map[uuid.UUID]bool{}
+	moved := map[uuid.UUID]uuid.UUID{}
+	for i := range c.Locations {
+		l := &c.Locations[i]
+		if to := match(l.ID, l.ExternalID, l.Name, uuid.Nil, rows, claimed); to != l.ID {
+			moved[l.ID], l.ID = to, to
+		}
+	}
+	for i := range c.Restaurants {
+		r := &c.Restaurants[i]
+		if r.LocationID != nil {
+			if to, ok := moved[*r.LocationID]; ok {
+				r.LocationID = &to
+			}
+		}
+	}
+}
+
+// adoptRestaurants points c's restaurants, and their categories and food, at
+// the existing rows match picks.
+func (c *Catalog) adoptRestaurants(existing *Snapshot) {
+	var rows []existingRow
+	for _, r := range existing.Restaurants {
+		rows = append(rows, existingRow{id: r.ID, externalID: r.ExternalID, name: r.Name})
+	}
+	rows = sortRows(rows)
+	claimed := map[uuid.UUID]bool{}
+	moved := map[uuid.UUID]uuid.UUID{}
+	for i := range c.Restaurants {
+		r := &c.Restaurants[i]
+		if to := match(r.ID, r.ExternalID, r.Name, uuid.Nil, rows, claimed); to != r.ID {
+			moved[r.ID], r.ID = to, to
+		}
+	}
+	for i := range c.Categories {
+		if to, ok := moved[c.Categories[i].RestaurantID]; ok {
+			c.Categories[i].RestaurantID = to
+		}
+	}
+	for i := range c.Foods {
+		if to, ok := moved[c.Foods[i].RestaurantID]; ok {
+			c.Foods[i].RestaurantID = to
+		}
+	}
+}
+
+// adoptCategories points c's categories, and the food in them, at the
+// existing rows match picks within the same restaurant.
+func (c *Catalog) adoptCategories(existing *Snapshot) {
+	var rows []existingRow
+	for _, cat := range existing.Categories {
+		rows = append(rows, existingRow{id: cat.ID, externalID: cat.ExternalID, name: cat.Name, scope: cat.RestaurantID})
+	}
+	rows = sortRows(rows)
+	claimed := map[uuid.UUID]bool{}
+	moved := map[uuid.UUID]uuid.UUID{}
+	for i := range c.Categories {
+		cat := &c.Categories[i]
+		if to := match(cat.ID, cat.ExternalID, cat.Name, cat.RestaurantID, rows, claimed); to != cat.ID {
+			moved[cat.ID], cat.ID = to, to
+		}
+	}
+	for i := range c.Foods {
+		f := &c.Foods[i]
+		if f.CategoryID != nil {
+			if to, ok := moved[*f.CategoryID]; ok {
+				f.CategoryID = &to
+			}
+		}
+	}
+}
+
+// adoptFoods points c's food at the existing rows match picks within the
+// same restaurant.
+func (c *Catalog) adoptFoods(existing *Snapshot) {
+	var rows []existingRow
+	for _, f := range existing.Foods {
+		rows = append(rows, existingRow{id: f.ID, externalID: f.ExternalID, name: f.Name, scope: f.RestaurantID})
+	}
+	rows = sortRows(rows)
+	claimed := map[uuid.UUID]bool{}
+	for i := range c.Foods {
+		f := &c.Foods[i]
+		f.ID = match(f.ID, f.ExternalID, f.Name, f.RestaurantID, rows, claimed)
+	}
+}
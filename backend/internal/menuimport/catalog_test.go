@@ -0,0 +1,103 @@
+package menuimport
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// fixtures are the sample exports the command's docs use.
+const fixtures = "../../cmd/menuimport/testdata"
+
+func loadFixtures(t *testing.T) *Catalog {
+	t.Helper()
+	c, err := Load(fixtures)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return c
+}
+
+func TestLoad(t *testing.T) {
+	c := loadFixtures(t)
+
+	if len(c.Locations) != 2 || len(c.Restaurants) != 2 || len(c.Categories) != 4 || len(c.Foods) != 8 {
+		t.Fatalf("loaded %d locations, %d restaurants, %d categories, %d foods; want 2, 2, 4, 8",
+			len(c.Locations), len(c.Restaurants), len(c.Categories), len(c.Foods))
+	}
+	if c.Restaurants[0].Name != "Union Grill" || *c.Restaurants[0].LocationID != c.Locations[0].ID {
+		t.Errorf("first restaurant = %+v, want Union Grill in %s", c.Restaurants[0], c.Locations[0].Name)
+	}
+
+	// lunch comes before dinner, and items seen at lunch keep their place
+	var grill []string
+	for _, f := range c.Foods {
+		if f.RestaurantID == c.Restaurants[0].ID {
+			grill = append(grill, f.Name)
+		}
+	}
+	want := "Classic Cheeseburger, Veggie Burger, Seasoned Fries, Side Salad, Grilled Chicken Plate"
+	if got := strings.Join(grill, ", "); got != want {
+		t.Errorf("Union Grill menu = %s, want %s", got, want)
+	}
+
+	foods := map[string]Food{}
+	for _, f := range c.Foods {
+		foods[f.Name] = f
+	}
+	if p := foods["Classic Cheeseburger"].Price; p == nil || p.Cents != 749 {
+		t.Errorf("cheeseburger price = %v, want 7.49", p)
+	}
+	if p := foods["Drip Coffee"].Price; p == nil || p.Cents != 195 {
+		t.Errorf("coffee price = %v, want 1.95", p)
+	}
+	if foods["Side Salad"].Price != nil {
+		t.Errorf("side salad price = %v, want none", foods["Side Salad"].Price)
+	}
+	if foods["Seasoned Fries"].Description != nil {
+		t.Errorf("empty description loaded as %q", *foods["Seasoned Fries"].Description)
+	}
+
+	// the same section in two periods is one category
+	entrees := 0
+	for _, cat := range c.Categories {
+		if cat.Name == "Entrees" {
+			entrees++
+		}
+	}
+	if entrees != 1 {
+		t.Errorf("%d Entrees categories, want 1", entrees)
+	}
+}
+
+func TestLoadIsStable(t *testing.T) {
+	a, b := loadFixtures(t), loadFixtures(t)
+	for i := range a.Foods {
+		if a.Foods[i].ID != b.Foods[i].ID || a.Foods[i].DisplayOrder != b.Foods[i].DisplayOrder {
+			t.Fatalf("food %d differs between loads: %+v and %+v", i, a.Foods[i], b.Foods[i])
+		}
+	}
+}
+
+func TestLoadRejectsUnknownLocations(t *testing.T) {
+	dir := t.TempDir()
+	locations, err := os.ReadFile(filepath.Join(fixtures, "locations.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "locations.json"), locations, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Mkdir(filepath.Join(dir, "menus"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	menu := `{"menu": {"periods": {"name": "All Day", "categories": []}}}`
+	if err := os.WriteFile(filepath.Join(dir, "menus", "000000000000000000000000.json"), []byte(menu), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := Load(dir); err == nil || !strings.Contains(err.Error(), "not in locations.json") {
+		t.Errorf("error = %v, want the unknown location reported", err)
+	}
+}
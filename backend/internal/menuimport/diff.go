@@ -0,0 +1,187 @@
+package menuimport
+
+import (
+	"campusDoordash/internal/money"
+	"fmt"
+	"io"
+
+	"github.com/google/uuid"
+)
+
+// Snapshot is what the database already holds for a catalog's IDs.
+type Snapshot struct {
+	Locations   map[uuid.UUID]Location
+	Restaurants map[uuid.UUID]Restaurant
+	Categories  map[uuid.UUID]Category
+	Foods       map[uuid.UUID]Food
+}
+
+// EmptySnapshot is an empty database, for dry runs without one.
+func EmptySnapshot() *Snapshot {
+	return &Snapshot{
+		Locations:   map[uuid.UUID]Location{},
+		Restaurants: map[uuid.UUID]Restaurant{},
+		Categories:  map[uuid.UUID]Category{},
+		Foods:       map[uuid.UUID]Food{},
+	}
+}
+
+type ChangeKind string
+
+const (
+	ChangeCreate ChangeKind = "create"
+	ChangeUpdate ChangeKind = "update"
+)
+
+type FieldDiff struct {
+	Field string
+	From  string
+	To    string
+}
+
+// Change is one row the import will insert or update.
+type Change struct {
+	Kind   ChangeKind
+	Entity Entity
+	ID     uuid.UUID
+	Name   string
+	Fields []FieldDiff
+}
+
+// Plan is the difference between a catalog and a snapshot.
+type Plan struct {
+	Changes   []Change
+	Unchanged map[Entity]int
+
+	changed  map[uuid.UUID]bool
+	existing *Snapshot
+}
+
+// Changed reports whether the row with id will be written.
+func (p *Plan) Changed(id uuid.UUID) bool {
+	return p.changed[id]
+}
+
+// Diff works out what importing c would change in a database holding existing.
+// Rows adopted from before the importer get their external_id set. Only
+// fields the importer owns are compared: display order, availability
+// and archiving are left to restaurant staff once a row exists, and a price
+// missing from the import keeps the current one.
+func Diff(c *Catalog, existing *Snapshot) *Plan {
+	p := &Plan{Unchanged: map[Entity]int{}, changed: map[uuid.UUID]bool{}, existing: existing}
+
+	for _, l := range c.Locations {
+		old, ok := existing.Locations[l.ID]
+		p.add(EntityLocation, l.ID, l.Name, ok, func(d *differ) {
+			d.field("external_id", old.ExternalID, l.ExternalID)
+			d.field("name", old.Name, l.Name)
+			d.field("type", str(old.Type), str(l.Type))
+		})
+	}
+	for _, r := range c.Restaurants {
+		old, ok := existing.Restaurants[r.ID]
+		p.add(EntityRestaurant, r.ID, r.Name, ok, func(d *differ) {
+			d.field("external_id", old.ExternalID, r.ExternalID)
+			d.field("name", old.Name, r.Name)
+			d.field("location_id", id(old.LocationID), id(r.LocationID))
+		})
+	}
+	for _, cat := range c.Categories {
+		old, ok := existing.Categories[cat.ID]
+		p.add(EntityCategory, cat.ID, cat.Name, ok, func(d *differ) {
+			d.field("external_id", old.ExternalID, cat.ExternalID)
+			d.field("name", old.Name, cat.Name)
+		})
+	}
+	for _, f := range c.Foods {
+		old, ok := existing.Foods[f.ID]
+		p.add(EntityFood, f.ID, f.Name, ok, func(d *differ) {
+			d.field("external_id", old.ExternalID, f.ExternalID)
+			d.field("name", old.Name, f.Name)
+			d.field("description", str(old.Description), str(f.Description))
+			d.field("category_id", id(old.CategoryID), id(f.CategoryID))
+			if f.Price != nil {
+				d.field("price", price(old.Price), price(f.Price))
+			}
+		})
+	}
+	return p
+}
+
+type differ struct {
+	fields []FieldDiff
+}
+
+func (d *differ) field(name, from, to string) {
+	if from != to {
+		d.fields = append(d.fields, FieldDiff{Field: name, From: from, To: to})
+	}
+}
+
+func (p *Plan) add(entity Entity, rowID uuid.UUID, name string, exists bool, compare func(*differ)) {
+	if !exists {
+		p.Changes = append(p.Changes, Change{Kind: ChangeCreate, Entity: entity, ID: rowID, Name: name})
+		p.changed[rowID] = true
+		return
+	}
+
+	var d differ
+	compare(&d)
+	if len(d.fields) == 0 {
+		p.Unchanged[entity]++
+		return
+	}
+	p.Changes = append(p.Changes, Change{Kind: ChangeUpdate, Entity: entity, ID: rowID, Name: name, Fields: d.fields})
+	p.changed[rowID] = true
+}
+
+// Write prints the plan as a diff, + for new rows and ~ for changed ones,
+// followed by counts per entity.
+func (p *Plan) Write(w io.Writer) {
+	for _, c := range p.Changes {
+		mark := "+"
+		if c.Kind == ChangeUpdate {
+			mark = "~"
+		}
+		fmt.Fprintf(w, "%s %-10s %s (%s)\n", mark, c.Entity, c.Name, c.ID)
+		for _, f := range c.Fields {
+			fmt.Fprintf(w, "      %s: %q -> %q\n", f.Field, f.From, f.To)
+		}
+	}
+
+	for _, entity := range []Entity{EntityLocation, EntityRestaurant, EntityCategory, EntityFood} {
+		created, updated := 0, 0
+		for _, c := range p.Changes {
+			if c.Entity != entity {
+				continue
+			}
+			if c.Kind == ChangeCreate {
+				created++
+			} else {
+				updated++
+			}
+		}
+		fmt.Fprintf(w, "%s: %d new, %d changed, %d unchanged\n", entity, created, updated, p.Unchanged[entity])
+	}
+}
+
+func str(s *string) string {
+	if s == nil {
+		return ""
+	}
+	return *s
+}
+
+func id(u *uuid.UUID) string {
+	if u == nil {
+		return ""
+	}
+	return u.String()
+}
+
+func price(m *money.Money) string {
+	if m == nil {
+		return ""
+	}
+	return m.String()
+}
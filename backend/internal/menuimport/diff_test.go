@@ -0,0 +1,226 @@
+package menuimport
+
+import (
+	"bytes"
+	"campusDoordash/internal/money"
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+// applied is the snapshot a database would hold right after applying c.
+func applied(c *Catalog) *Snapshot {
+	s := EmptySnapshot()
+	for _, l := range c.Locations {
+		s.Locations[l.ID] = l
+	}
+	for _, r := range c.Restaurants {
+		s.Restaurants[r.ID] = r
+	}
+	for _, cat := range c.Categories {
+		s.Categories[cat.ID] = cat
+	}
+	for _, f := range c.Foods {
+		s.Foods[f.ID] = f
+	}
+	return s
+}
+
+func TestDiffAgainstEmptyDatabase(t *testing.T) {
+	c := loadFixtures(t)
+	plan := Diff(c, EmptySnapshot())
+
+	if len(plan.Changes) != 16 {
+		t.Fatalf("%d changes, want 16", len(plan.Changes))
+	}
+	for _, change := range plan.Changes {
+		if change.Kind != ChangeCreate || !plan.Changed(change.ID) {
+			t.Errorf("%s %s is not a create", change.Entity, change.Name)
+		}
+	}
+
+	var out bytes.Buffer
+	plan.Write(&out)
+	for _, line := range []string{
+		"+ location   Student Union (",
+		"+ food       Classic Cheeseburger (",
+		"food: 8 new, 0 changed, 0 unchanged",
+	} {
+		if !strings.Contains(out.String(), line) {
+			t.Errorf("output is missing %q:\n%s", line, out.String())
+		}
+	}
+}
+
+func TestDiffAfterApplyIsEmpty(t *testing.T) {
+	c := loadFixtures(t)
+	plan := Diff(loadFixtures(t), applied(c))
+
+	if len(plan.Changes) != 0 {
+		var out bytes.Buffer
+		plan.Write(&out)
+		t.Fatalf("second import would change:\n%s", out.String())
+	}
+	if plan.Unchanged[EntityFood] != 8 || plan.Unchanged[EntityLocation] != 2 {
+		t.Errorf("unchanged = %v", plan.Unchanged)
+	}
+
+	var out bytes.Buffer
+	plan.Write(&out)
+	if !strings.Contains(out.String(), "food: 0 new, 0 changed, 8 unchanged") {
+		t.Errorf("output:\n%s", out.String())
+	}
+}
+
+func TestDiffUpdates(t *testing.T) {
+	c := loadFixtures(t)
+	existing := applied(c)
+
+	foods := map[string]Food{}
+	for _, f := range c.Foods {
+		foods[f.Name] = f
+	}
+	burger := foods["Classic Cheeseburger"]
+	oldPrice := money.FromCents(699)
+	burger.Price = &oldPrice
+	existing.Foods[burger.ID] = burger
+
+	// a price missing from the import keeps the one set by staff
+	salad := foods["Side Salad"]
+	staffPrice := money.FromCents(350)
+	salad.Price = &staffPrice
+	existing.Foods[salad.ID] = salad
+
+	// staff ordering isn't the importer's business
+	fries := foods["Seasoned Fries"]
+	fries.DisplayOrder = 99
+	existing.Foods[fries.ID] = fries
+
+	plan := Diff(c, existing)
+	if len(plan.Changes) != 1 {
+		t.Fatalf("%d changes, want only the burger: %+v", len(plan.Changes), plan.Changes)
+	}
+	change := plan.Changes[0]
+	if change.Kind != ChangeUpdate || change.ID != burger.ID || len(change.Fields) != 1 {
+		t.Fatalf("change = %+v", change)
+	}
+	if f := change.Fields[0]; f.Field != "price" || f.From != "6.99" || f.To != "7.49" {
+		t.Errorf("field = %+v, want price 6.99 -> 7.49", f)
+	}
+
+	var out bytes.Buffer
+	plan.Write(&out)
+	if !strings.Contains(out.String(), `price: "6.99" -> "7.49"`) {
+		t.Errorf("output:\n%s", out.String())
+	}
+}
+
+// legacy is the snapshot the data_retrieval scripts would have left for c:
+// the same rows under random IDs and without external IDs.
+func legacy(c *Catalog) *Snapshot {
+	ids := map[uuid.UUID]uuid.UUID{}
+	to := func(id uuid.UUID) uuid.UUID {
+		if _, ok := ids[id]; !ok {
+			ids[id] = uuid.New()
+		}
+		return ids[id]
+	}
+
+	s := EmptySnapshot()
+	for _, l := range c.Locations {
+		l.ID, l.ExternalID = to(l.ID), ""
+		s.Locations[l.ID] = l
+	}
+	for _, r := range c.Restaurants {
+		r.ID, r.ExternalID = to(r.ID), ""
+		if r.LocationID != nil {
+			id := to(*r.LocationID)
+			r.LocationID = &id
+		}
+		s.Restaurants[r.ID] = r
+	}
+	for _, cat := range c.Categories {
+		cat.ID, cat.ExternalID, cat.RestaurantID = to(cat.ID), "", to(cat.RestaurantID)
+		s.Categories[cat.ID] = cat
+	}
+	for _, f := range c.Foods {
+		f.ID, f.ExternalID, f.RestaurantID = to(f.ID), "", to(f.RestaurantID)
+		if f.CategoryID != nil {
+			id := to(*f.CategoryID)
+			f.CategoryID = &id
+		}
+		s.Foods[f.ID] = f
+	}
+	return s
+}
+
+// adopt does what LoadSnapshot does once each table is read.
+func adopt(c *Catalog, existing *Snapshot) {
+	c.adoptLocations(existing)
+	c.adoptRestaurants(existing)
+	c.adoptCategories(existing)
+	c.adoptFoods(existing)
+}
+
+func TestDiffAdoptsLegacyRows(t *testing.T) {
+	c := loadFixtures(t)
+	existing := legacy(c)
+	adopt(c, existing)
+
+	legacyIDs := map[uuid.UUID]bool{}
+	for id := range existing.Locations {
+		legacyIDs[id] = true
+	}
+	for id := range existing.Restaurants {
+		legacyIDs[id] = true
+	}
+	for id := range existing.Categories {
+		legacyIDs[id] = true
+	}
+	for id := range existing.Foods {
+		legacyIDs[id] = true
+	}
+
+	plan := Diff(c, existing)
+	if len(plan.Changes) != 16 {
+		t.Fatalf("%d changes, want 16", len(plan.Changes))
+	}
+	for _, change := range plan.Changes {
+		if change.Kind != ChangeUpdate {
+			t.Errorf("%s %s is a %s, want the legacy row updated", change.Entity, change.Name, change.Kind)
+			continue
+		}
+		if !legacyIDs[change.ID] {
+			t.Errorf("%s %s was not matched to a legacy row", change.Entity, change.Name)
+		}
+		if len(change.Fields) != 1 || change.Fields[0].Field != "external_id" {
+			t.Errorf("%s %s changes %+v, want only external_id", change.Entity, change.Name, change.Fields)
+		}
+	}
+
+	// the next run finds the rows by ID and has nothing to do
+	next := loadFixtures(t)
+	tagged := applied(c)
+	adopt(next, tagged)
+	if plan := Diff(next, tagged); len(plan.Changes) != 0 {
+		t.Errorf("second import would make %d changes", len(plan.Changes))
+	}
+}
+
+func TestDiffAdoptsOnlyWithinTheRestaurant(t *testing.T) {
+	c := loadFixtures(t)
+	existing := EmptySnapshot()
+
+	// another restaurant's food with the same name stays where it is
+	f := c.Foods[0]
+	f.ID, f.ExternalID, f.RestaurantID, f.CategoryID = uuid.New(), "", uuid.New(), nil
+	existing.Foods[f.ID] = f
+
+	adopt(c, existing)
+	for _, food := range c.Foods {
+		if food.ID == f.ID {
+			t.Fatalf("%s was matched to another restaurant's row", food.Name)
+		}
+	}
+}
@@ -0,0 +1,125 @@
+// Package menuimport maps DineOnCampus JSON exports onto campus locations,
+// restaurants, categories and food items, and upserts them idempotently.
+package menuimport
+
+import (
+	"bytes"
+	"campusDoordash/internal/money"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// The types below mirror the parts of the DineOnCampus API responses the
+// importer uses; everything else in the files is ignored.
+
+// locationsFile is the response of /locations/all_locations with buildings.
+type locationsFile struct {
+	Buildings []dcBuilding `json:"buildings"`
+}
+
+type dcBuilding struct {
+	ID        string       `json:"id"`
+	Name      string       `json:"name"`
+	Type      string       `json:"type"`
+	Locations []dcLocation `json:"locations"`
+}
+
+// dcLocation is what DineOnCampus calls a location: a dining venue inside a
+// building, i.e. one of our restaurants.
+type dcLocation struct {
+	ID         string `json:"id"`
+	BuildingID string `json:"building_id"`
+	Name       string `json:"name"`
+}
+
+// menuFile is the response of /location/{id}/periods, optionally with a
+// location_id added so the file name doesn't have to carry it.
+type menuFile struct {
+	LocationID string `json:"location_id"`
+	Menu       struct {
+		Periods dcPeriods `json:"periods"`
+	} `json:"menu"`
+	// Periods lists every period of the day; only the one in Menu has categories.
+	Periods []dcPeriod `json:"periods"`
+}
+
+type dcPeriod struct {
+	ID         string       `json:"id"`
+	Name       string       `json:"name"`
+	SortOrder  *int         `json:"sort_order"`
+	Categories []dcCategory `json:"categories"`
+}
+
+// dcPeriods accepts menu.periods as either one period or a list of them.
+type dcPeriods []dcPeriod
+
+func (p *dcPeriods) UnmarshalJSON(data []byte) error {
+	data = bytes.TrimSpace(data)
+	if len(data) > 0 && data[0] == '{' {
+		var one dcPeriod
+		if err := json.Unmarshal(data, &one); err != nil {
+			return err
+		}
+		*p = dcPeriods{one}
+		return nil
+	}
+	var many []dcPeriod
+	if err := json.Unmarshal(data, &many); err != nil {
+		return err
+	}
+	*p = many
+	return nil
+}
+
+type dcCategory struct {
+	ID        string   `json:"id"`
+	Name      string   `json:"name"`
+	SortOrder *int     `json:"sort_order"`
+	Items     []dcItem `json:"items"`
+}
+
+type dcItem struct {
+	ID        string  `json:"id"`
+	Name      string  `json:"name"`
+	Desc      string  `json:"desc"`
+	Price     dcPrice `json:"price"`
+	SortOrder *int    `json:"sort_order"`
+}
+
+// dcPrice reads prices written as 5.29, "5.29" or "$5.29". Items without a
+// price leave it nil.
+type dcPrice struct {
+	Amount *money.Money
+}
+
+func (p *dcPrice) UnmarshalJSON(data []byte) error {
+	s := strings.TrimSpace(strings.Trim(string(data), `"`))
+	s = strings.TrimPrefix(s, "$")
+	if s == "" || s == "null" {
+		return nil
+	}
+	m, err := money.Parse(s)
+	if err != nil {
+		return fmt.Errorf("invalid price %s", data)
+	}
+	p.Amount = &m
+	return nil
+}
+
+func decodeLocations(r io.Reader) (*locationsFile, error) {
+	var f locationsFile
+	if err := json.NewDecoder(r).Decode(&f); err != nil {
+		return nil, err
+	}
+	return &f, nil
+}
+
+func decodeMenu(r io.Reader) (*menuFile, error) {
+	var f menuFile
+	if err := json.NewDecoder(r).Decode(&f); err != nil {
+		return nil, err
+	}
+	return &f, nil
+}
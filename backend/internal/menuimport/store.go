@@ -0,0 +1,302 @@
+package menuimport
+
+import (
+	"campusDoordash/internal/restaurants"
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// LoadSnapshot reads the rows that already exist for c. Besides rows with
+// c's IDs or DineOnCampus IDs, that includes rows the data_retrieval scripts
+// created before the importer existed, which have no external_id: those with
+// the same name (within the same restaurant, for categories and food) are
+// adopted, and c is changed to use their IDs so the import updates them
+// instead of adding duplicates.
+func LoadSnapshot(ctx context.Context, conn *pgxpool.Pool, c *Catalog) (*Snapshot, error) {
+	s := EmptySnapshot()
+
+	list := func(n int, get func(int) uuid.UUID) []uuid.UUID {
+		out := make([]uuid.UUID, n)
+		for i := range out {
+			out[i] = get(i)
+		}
+		return out
+	}
+	strs := func(n int, get func(int) string) []string {
+		out := make([]string, n)
+		for i := range out {
+			out[i] = get(i)
+		}
+		return out
+	}
+	restaurantIDs := func() []uuid.UUID {
+		return list(len(c.Restaurants), func(i int) uuid.UUID { return c.Restaurants[i].ID })
+	}
+
+	err := collect(ctx, conn, `
+		SELECT location_id, COALESCE(external_id, ''), location_name, location_type
+		FROM locations
+		WHERE location_id = ANY($1) OR external_id = ANY($2)
+			OR (external_id IS NULL AND lower(location_name) = ANY($3))
+	`, []any{
+		list(len(c.Locations), func(i int) uuid.UUID { return c.Locations[i].ID }),
+		strs(len(c.Locations), func(i int) string { return c.Locations[i].ExternalID }),
+		strs(len(c.Locations), func(i int) string { return strings.ToLower(c.Locations[i].Name) }),
+	}, func(rows pgx.Rows) error {
+		var l Location
+		if err := rows.Scan(&l.ID, &l.ExternalID, &l.Name, &l.Type); err != nil {
+			return err
+		}
+		s.Locations[l.ID] = l
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to load locations: %v", err)
+	}
+	c.adoptLocations(s)
+
+	err = collect(ctx, conn, `
+		SELECT restaurant_id, COALESCE(external_id, ''), restaurant_name, location_id
+		FROM restaurants
+		WHERE restaurant_id = ANY($1) OR external_id = ANY($2)
+			OR (external_id IS NULL AND lower(restaurant_name) = ANY($3))
+	`, []any{
+		restaurantIDs(),
+		strs(len(c.Restaurants), func(i int) string { return c.Restaurants[i].ExternalID }),
+		strs(len(c.Restaurants), func(i int) string { return strings.ToLower(c.Restaurants[i].Name) }),
+	}, func(rows pgx.Rows) error {
+		var r Restaurant
+		if err := rows.Scan(&r.ID, &r.ExternalID, &r.Name, &r.LocationID); err != nil {
+			return err
+		}
+		s.Restaurants[r.ID] = r
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to load restaurants: %v", err)
+	}
+	c.adoptRestaurants(s)
+
+	err = collect(ctx, conn, `
+		SELECT category_id, COALESCE(external_id, ''), restaurant_id, category_name, display_order
+		FROM categories
+		WHERE category_id = ANY($1) OR external_id = ANY($2)
+			OR (external_id IS NULL AND restaurant_id = ANY($3))
+	`, []any{
+		list(len(c.Categories), func(i int) uuid.UUID { return c.Categories[i].ID }),
+		strs(len(c.Categories), func(i int) string { return c.Categories[i].ExternalID }),
+		restaurantIDs(),
+	}, func(rows pgx.Rows) error {
+		var cat Category
+		if err := rows.Scan(&cat.ID, &cat.ExternalID, &cat.RestaurantID, &cat.Name, &cat.DisplayOrder); err != nil {
+			return err
+		}
+		s.Categories[cat.ID] = cat
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to load categories: %v", err)
+	}
+	c.adoptCategories(s)
+
+	err = collect(ctx, conn, `
+		SELECT food_id, COALESCE(external_id, ''), restaurant_id, category_id, food_name, description, price, display_order
+		FROM food
+		WHERE food_id = ANY($1) OR external_id = ANY($2)
+			OR (external_id IS NULL AND restaurant_id = ANY($3))
+	`, []any{
+		list(len(c.Foods), func(i int) uuid.UUID { return c.Foods[i].ID }),
+		strs(len(c.Foods), func(i int) string { return c.Foods[i].ExternalID }),
+		restaurantIDs(),
+	}, func(rows pgx.Rows) error {
+		var f Food
+		if err := rows.Scan(&f.ID, &f.ExternalID, &f.RestaurantID, &f.CategoryID, &f.Name, &f.Description, &f.Price, &f.DisplayOrder); err != nil {
+			return err
+		}
+		s.Foods[f.ID] = f
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to load food: %v", err)
+	}
+	c.adoptFoods(s)
+
+	return s, nil
+}
+
+func collect(ctx context.Context, conn *pgxpool.Pool, query string, args []any, scan func(pgx.Rows) error) error {
+	rows, err := conn.Query(ctx, query, args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		if err := scan(rows); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
+// ImporterID is recorded in menu_changes as the editor of imports run
+// without naming an admin.
+var ImporterID = uuid.NewSHA1(namespace, []byte("menuimport"))
+
+// Apply writes every row p marks as changed in one transaction, so a failed
+// import leaves the database as it was. Changes to categories and food are
+// audited in menu_changes under editorID, like edits made by staff. Running
+// it again with the same files changes nothing.
+func Apply(ctx context.Context, conn *pgxpool.Pool, c *Catalog, p *Plan, editorID uuid.UUID) error {
+	tx, err := conn.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	changes := map[uuid.UUID]Change{}
+	for _, change := range p.Changes {
+		changes[change.ID] = change
+	}
+
+	for _, l := range c.Locations {
+		if !p.Changed(l.ID) {
+			continue
+		}
+		_, err := tx.Exec(ctx, `
+			INSERT INTO locations (location_id, external_id, location_name, location_type)
+			VALUES ($1, $2, $3, $4)
+			ON CONFLICT (location_id) DO UPDATE
+			SET external_id = EXCLUDED.external_id, location_name = EXCLUDED.location_name,
+				location_type = EXCLUDED.location_type
+		`, l.ID, l.ExternalID, l.Name, l.Type)
+		if err != nil {
+			return fmt.Errorf("failed to save location %s: %v", l.Name, err)
+		}
+	}
+
+	for _, r := range c.Restaurants {
+		if !p.Changed(r.ID) {
+			continue
+		}
+		_, err := tx.Exec(ctx, `
+			INSERT INTO restaurants (restaurant_id, external_id, restaurant_name, location_id)
+			VALUES ($1, $2, $3, $4)
+			ON CONFLICT (restaurant_id) DO UPDATE
+			SET external_id = EXCLUDED.external_id, restaurant_name = EXCLUDED.restaurant_name,
+				location_id = EXCLUDED.location_id
+		`, r.ID, r.ExternalID, r.Name, r.LocationID)
+		if err != nil {
+			return fmt.Errorf("failed to save restaurant %s: %v", r.Name, err)
+		}
+	}
+
+	for _, cat := range c.Categories {
+		if !p.Changed(cat.ID) {
+			continue
+		}
+		_, err := tx.Exec(ctx, `
+			INSERT INTO categories (category_id, external_id, restaurant_id, category_name, display_order)
+			VALUES ($1, $2, $3, $4, $5)
+			ON CONFLICT (category_id) DO UPDATE
+			SET external_id = EXCLUDED.external_id, category_name = EXCLUDED.category_name, updated_at = NOW()
+		`, cat.ID, cat.ExternalID, cat.RestaurantID, cat.Name, cat.DisplayOrder)
+		if err != nil {
+			return fmt.Errorf("failed to save category %s: %v", cat.Name, err)
+		}
+
+		action, audited := categoryChanges(changes[cat.ID], p.existing.Categories[cat.ID], cat)
+		if err := audit(ctx, tx, cat.RestaurantID, restaurants.EntityCategory, cat.ID, action, audited, editorID); err != nil {
+			return fmt.Errorf("failed to record change to category %s: %v", cat.Name, err)
+		}
+	}
+
+	for _, f := range c.Foods {
+		if !p.Changed(f.ID) {
+			continue
+		}
+		_, err := tx.Exec(ctx, `
+			INSERT INTO food (food_id, external_id, restaurant_id, category_id, food_name, description, price, availability, display_order)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, true, $8)
+			ON CONFLICT (food_id) DO UPDATE
+			SET external_id = EXCLUDED.external_id, category_id = EXCLUDED.category_id,
+				food_name = EXCLUDED.food_name, description = EXCLUDED.description,
+				price = COALESCE(EXCLUDED.price, food.price), updated_at = NOW()
+		`, f.ID, f.ExternalID, f.RestaurantID, f.CategoryID, f.Name, f.Description, f.Price, f.DisplayOrder)
+		if err != nil {
+			return fmt.Errorf("failed to save %s: %v", f.Name, err)
+		}
+
+		action, audited := foodChanges(changes[f.ID], p.existing.Foods[f.ID], f)
+		if err := audit(ctx, tx, f.RestaurantID, restaurants.EntityFood, f.ID, action, audited, editorID); err != nil {
+			return fmt.Errorf("failed to record change to %s: %v", f.Name, err)
+		}
+	}
+
+	return tx.Commit(ctx)
+}
+
+// audit records one menu change, unless nothing staff would see changed:
+// tagging a legacy row with its external_id isn't a menu edit.
+func audit(ctx context.Context, tx pgx.Tx, restaurantID uuid.UUID, entity restaurants.MenuEntity, entityID uuid.UUID, action restaurants.MenuAction, changes map[string]restaurants.FieldChange, editorID uuid.UUID) error {
+	if len(changes) == 0 {
+		return nil
+	}
+	return restaurants.RecordMenuChange(ctx, tx, restaurantID, entity, entityID, action, changes, editorID)
+}
+
+// categoryChanges is the audit entry for writing cat over old, using the
+// same fields as categories created and renamed by staff.
+func categoryChanges(change Change, old, cat Category) (restaurants.MenuAction, map[string]restaurants.FieldChange) {
+	if change.Kind == ChangeCreate {
+		return restaurants.ActionCreate, map[string]restaurants.FieldChange{
+			"name":          {To: cat.Name},
+			"display_order": {To: cat.DisplayOrder},
+		}
+	}
+
+	changes := map[string]restaurants.FieldChange{}
+	for _, field := range change.Fields {
+		if field.Field == "name" {
+			changes["name"] = restaurants.FieldChange{From: old.Name, To: cat.Name}
+		}
+	}
+	return restaurants.ActionUpdate, changes
+}
+
+// foodChanges is the audit entry for writing f over old, using the same
+// fields as food edited by staff. An update that only reprices f is a price
+// change.
+func foodChanges(change Change, old, f Food) (restaurants.MenuAction, map[string]restaurants.FieldChange) {
+	if change.Kind == ChangeCreate {
+		return restaurants.ActionCreate, map[string]restaurants.FieldChange{
+			"food_name":    {To: f.Name},
+			"category_id":  {To: f.CategoryID},
+			"price":        {To: f.Price},
+			"availability": {To: true},
+			"description":  {To: f.Description},
+		}
+	}
+
+	changes := map[string]restaurants.FieldChange{}
+	for _, field := range change.Fields {
+		switch field.Field {
+		case "name":
+			changes["food_name"] = restaurants.FieldChange{From: old.Name, To: f.Name}
+		case "description":
+			changes["description"] = restaurants.FieldChange{From: old.Description, To: f.Description}
+		case "category_id":
+			changes["category_id"] = restaurants.FieldChange{From: old.CategoryID, To: f.CategoryID}
+		case "price":
+			changes["price"] = restaurants.FieldChange{From: old.Price, To: f.Price}
+		}
+	}
+	if _, ok := changes["price"]; ok && len(changes) == 1 {
+		return restaurants.ActionPrice, changes
+	}
+	return restaurants.ActionUpdate, changes
+}
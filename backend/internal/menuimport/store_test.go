@@ -0,0 +1,103 @@
+package menuimport
+
+import (
+	"bytes"
+	"campusDoordash/internal/money"
+	"campusDoordash/internal/restaurants"
+	"context"
+	"os"
+	"testing"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// testDB connects to the Postgres at TEST_DB_STRING, which must already have
+// the app's schema and migrations. Tests that need it are skipped without one.
+// The fixture rows it imports are left behind; importing them again is a no-op.
+func testDB(t *testing.T) *pgxpool.Pool {
+	t.Helper()
+	connStr := os.Getenv("TEST_DB_STRING")
+	if connStr == "" {
+		t.Skip("TEST_DB_STRING is not set")
+	}
+	conn, err := pgxpool.New(context.Background(), connStr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(conn.Close)
+	return conn
+}
+
+func TestApplyTwiceChangesNothing(t *testing.T) {
+	conn := testDB(t)
+	ctx := context.Background()
+
+	c := loadFixtures(t)
+	existing, err := LoadSnapshot(ctx, conn, c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := Apply(ctx, conn, c, Diff(c, existing), ImporterID); err != nil {
+		t.Fatal(err)
+	}
+
+	again := loadFixtures(t)
+	existing, err = LoadSnapshot(ctx, conn, again)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if plan := Diff(again, existing); len(plan.Changes) != 0 {
+		var out bytes.Buffer
+		plan.Write(&out)
+		t.Fatalf("second import would change:\n%s", out.String())
+	}
+}
+
+func TestFoodChangesAuditLikeStaffEdits(t *testing.T) {
+	c := loadFixtures(t)
+	existing := applied(c)
+	var f Food
+	for _, food := range c.Foods {
+		if food.Name == "Classic Cheeseburger" {
+			f = food
+		}
+	}
+
+	old := f
+	oldPrice := money.FromCents(699)
+	old.Price = &oldPrice
+	existing.Foods[f.ID] = old
+	plan := Diff(c, existing)
+	action, changes := foodChanges(plan.Changes[0], old, f)
+	if action != restaurants.ActionPrice || len(changes) != 1 || changes["price"].From != &oldPrice {
+		t.Errorf("repricing audited as %s %+v", action, changes)
+	}
+
+	old.Name = "Old Name"
+	existing.Foods[f.ID] = old
+	plan = Diff(c, existing)
+	action, changes = foodChanges(plan.Changes[0], old, f)
+	if action != restaurants.ActionUpdate || changes["food_name"].From != "Old Name" || len(changes) != 2 {
+		t.Errorf("rename and repricing audited as %s %+v", action, changes)
+	}
+
+	// tagging a legacy row isn't a menu edit
+	old = f
+	old.ExternalID = ""
+	existing.Foods[f.ID] = old
+	plan = Diff(c, existing)
+	if _, changes := foodChanges(plan.Changes[0], old, f); len(changes) != 0 {
+		t.Errorf("tagging audited %+v", changes)
+	}
+
+	plan = Diff(c, EmptySnapshot())
+	for _, change := range plan.Changes {
+		if change.ID != f.ID {
+			continue
+		}
+		action, changes := foodChanges(change, Food{}, f)
+		if action != restaurants.ActionCreate || changes["food_name"].To != f.Name || changes["availability"].To != true {
+			t.Errorf("create audited as %s %+v", action, changes)
+		}
+	}
+}
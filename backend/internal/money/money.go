@@ -0,0 +1,271 @@
+// Package money holds the Money type used for every price, fee and total so
+// amounts are stored and charged as whole cents instead of float dollars.
+package money
+
+import (
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+const USD = "usd"
+
+var (
+	// ErrCurrencyMismatch is returned when adding or subtracting amounts in different currencies.
+	ErrCurrencyMismatch = errors.New("money: currencies do not match")
+	// ErrOutOfRange is returned for NaN, infinite and amounts too large to hold in cents.
+	ErrOutOfRange = errors.New("money: amount out of range")
+)
+
+// Money is an amount in the smallest unit of its currency (cents for USD).
+// The zero value is $0.00.
+type Money struct {
+	Cents    int64
+	Currency string
+}
+
+func FromCents(cents int64) Money {
+	return Money{Cents: cents, Currency: USD}
+}
+
+// FromDollars converts a dollar amount to Money, rounding to the nearest cent.
+// NaN, infinities and amounts too large for int64 cents return ErrOutOfRange.
+func FromDollars(dollars float64) (Money, error) {
+	if math.IsNaN(dollars) || math.IsInf(dollars, 0) {
+		return Money{}, fmt.Errorf("%w: %v", ErrOutOfRange, dollars)
+	}
+	cents, err := parseCents(strconv.FormatFloat(dollars, 'f', -1, 64))
+	if err != nil {
+		return Money{}, err
+	}
+	return FromCents(cents), nil
+}
+
+// Parse reads a decimal dollar string like "19.99", rounding to the nearest cent.
+func Parse(s string) (Money, error) {
+	cents, err := parseCents(s)
+	if err != nil {
+		return Money{}, err
+	}
+	return FromCents(cents), nil
+}
+
+// parseCents is the one place amounts get rounded: anything past the second
+// decimal place is rounded half away from zero, so 1.005 becomes 1.01 and -1.005 becomes -1.01.
+func parseCents(s string) (int64, error) {
+	s = strings.TrimSpace(s)
+	neg := strings.HasPrefix(s, "-")
+	s = strings.TrimLeft(s, "+-")
+
+	whole, frac, _ := strings.Cut(s, ".")
+	if whole == "" {
+		whole = "0"
+	}
+	for len(frac) < 3 {
+		frac += "0"
+	}
+
+	dollars, err := strconv.ParseInt(whole, 10, 64)
+	if errors.Is(err, strconv.ErrRange) || dollars > (math.MaxInt64-100)/100 {
+		return 0, fmt.Errorf("%w: %q", ErrOutOfRange, s)
+	}
+	if err != nil {
+		return 0, fmt.Errorf("money: invalid amount %q", s)
+	}
+	for _, r := range frac {
+		if r < '0' || r > '9' {
+			return 0, fmt.Errorf("money: invalid amount %q", s)
+		}
+	}
+
+	cents := dollars*100 + int64(frac[0]-'0')*10 + int64(frac[1]-'0')
+	if frac[2] >= '5' {
+		cents++
+	}
+
+	if neg {
+		cents = -cents
+	}
+	return cents, nil
+}
+
+// CurrencyCode returns the ISO currency code, defaulting to USD for the zero value.
+func (m Money) CurrencyCode() string {
+	if m.Currency == "" {
+		return USD
+	}
+	return m.Currency
+}
+
+func (m Money) match(o Money) error {
+	if m.CurrencyCode() != o.CurrencyCode() {
+		return fmt.Errorf("%w: %s and %s", ErrCurrencyMismatch, m.CurrencyCode(), o.CurrencyCode())
+	}
+	return nil
+}
+
+// Add returns m + o. Amounts in different currencies return
+// ErrCurrencyMismatch and sums that overflow return ErrOutOfRange.
+func (m Money) Add(o Money) (Money, error) {
+	if err := m.match(o); err != nil {
+		return Money{}, err
+	}
+	sum := m.Cents + o.Cents
+	if (o.Cents > 0 && sum < m.Cents) || (o.Cents < 0 && sum > m.Cents) {
+		return Money{}, ErrOutOfRange
+	}
+	return Money{Cents: sum, Currency: m.CurrencyCode()}, nil
+}
+
+// Sub returns m - o, with the same errors as Add.
+func (m Money) Sub(o Money) (Money, error) {
+	if o.Cents == math.MinInt64 {
+		return Money{}, ErrOutOfRange
+	}
+	return m.Add(Money{Cents: -o.Cents, Currency: o.Currency})
+}
+
+// Sum adds up amounts, starting from zero in the first one's currency.
+func Sum(amounts ...Money) (Money, error) {
+	if len(amounts) == 0 {
+		return FromCents(0), nil
+	}
+	total := Money{Currency: amounts[0].CurrencyCode()}
+	for _, a := range amounts {
+		var err error
+		if total, err = total.Add(a); err != nil {
+			return Money{}, err
+		}
+	}
+	return total, nil
+}
+
+// Mul multiplies by a whole quantity, e.g. a line price times the number
+// ordered. Products that overflow return ErrOutOfRange.
+func (m Money) Mul(n int) (Money, error) {
+	cents, ok := mulCents(m.Cents, int64(n))
+	if !ok {
+		return Money{}, fmt.Errorf("%w: %s times %d", ErrOutOfRange, m, n)
+	}
+	return Money{Cents: cents, Currency: m.CurrencyCode()}, nil
+}
+
+// Percent returns basisPoints/10000 of m (500 basis points is 5%), rounded
+// half away from zero to the nearest cent. Amounts too large to take a
+// percentage of return ErrOutOfRange.
+func (m Money) Percent(basisPoints int64) (Money, error) {
+	product, ok := mulCents(m.Cents, basisPoints)
+	if !ok {
+		return Money{}, fmt.Errorf("%w: %d basis points of %s", ErrOutOfRange, basisPoints, m)
+	}
+	cents := product / 10000
+	rem := product % 10000
+	if rem >= 5000 {
+		cents++
+	} else if rem <= -5000 {
+		cents--
+	}
+	return Money{Cents: cents, Currency: m.CurrencyCode()}, nil
+}
+
+// mulCents returns a*b and whether it fit in an int64.
+func mulCents(a, b int64) (int64, bool) {
+	if a == 0 || b == 0 {
+		return 0, true
+	}
+	product := a * b
+	if product/b != a || (a == -1 && b == math.MinInt64) || (b == -1 && a == math.MinInt64) {
+		return 0, false
+	}
+	return product, true
+}
+
+func (m Money) IsZero() bool {
+	return m.Cents == 0
+}
+
+func (m Money) IsNegative() bool {
+	return m.Cents < 0
+}
+
+// Dollars returns the amount as float dollars. Only use it for display or
+// for systems that insist on floats; never do arithmetic on the result.
+func (m Money) Dollars() float64 {
+	return float64(m.Cents) / 100
+}
+
+// String formats the amount as a plain decimal, e.g. "19.99".
+func (m Money) String() string {
+	sign := ""
+	cents := m.Cents
+	if cents < 0 {
+		sign = "-"
+		cents = -cents
+	}
+	return fmt.Sprintf("%s%d.%02d", sign, cents/100, cents%100)
+}
+
+// MarshalJSON writes the amount as a dollar number (19.99) so existing
+// clients that read prices as floats keep working.
+func (m Money) MarshalJSON() ([]byte, error) {
+	return []byte(m.String()), nil
+}
+
+// UnmarshalJSON accepts a dollar number or a quoted decimal string.
+func (m *Money) UnmarshalJSON(data []byte) error {
+	s := strings.Trim(string(data), `"`)
+	if s == "null" {
+		return nil
+	}
+
+	parsed, err := Parse(s)
+	if err != nil {
+		return err
+	}
+	*m = parsed
+	return nil
+}
+
+// Scan implements sql.Scanner for numeric and float columns holding dollars.
+func (m *Money) Scan(src any) error {
+	switch v := src.(type) {
+	case nil:
+		*m = Money{}
+		return nil
+	case float64:
+		parsed, err := FromDollars(v)
+		if err != nil {
+			return err
+		}
+		*m = parsed
+		return nil
+	case int64:
+		if v > math.MaxInt64/100 || v < math.MinInt64/100 {
+			return fmt.Errorf("%w: %d", ErrOutOfRange, v)
+		}
+		*m = FromCents(v * 100)
+		return nil
+	case []byte:
+		return m.scanString(string(v))
+	case string:
+		return m.scanString(v)
+	}
+	return fmt.Errorf("money: cannot scan %T", src)
+}
+
+func (m *Money) scanString(s string) error {
+	parsed, err := Parse(s)
+	if err != nil {
+		return err
+	}
+	*m = parsed
+	return nil
+}
+
+// Value implements driver.Valuer, storing the amount as dollars.
+func (m Money) Value() (driver.Value, error) {
+	return m.Dollars(), nil
+}
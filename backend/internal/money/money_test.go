@@ -0,0 +1,227 @@
+package money
+
+import (
+	"encoding/json"
+	"errors"
+	"math"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    int64
+		wantErr bool
+	}{
+		{"19.99", 1999, false},
+		{"0", 0, false},
+		{".5", 50, false},
+		{"3", 300, false},
+		{"1.005", 101, false},
+		{"1.004", 100, false},
+		{"-1.005", -101, false},
+		{"+2.50", 250, false},
+		{" 4.10 ", 410, false},
+		{"0.125", 13, false},
+		{"92233720368547758.07", 0, true},
+		{"1e3", 0, true},
+		{"abc", 0, true},
+		{"1.2x", 0, true},
+	}
+	for _, tt := range tests {
+		got, err := Parse(tt.in)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("Parse(%q) = %v, want an error", tt.in, got)
+			}
+			continue
+		}
+		if err != nil || got.Cents != tt.want {
+			t.Errorf("Parse(%q) = %d, %v, want %d", tt.in, got.Cents, err, tt.want)
+		}
+	}
+}
+
+func TestFromDollars(t *testing.T) {
+	tests := []struct {
+		in      float64
+		want    int64
+		wantErr error
+	}{
+		{19.99, 1999, nil},
+		{0.1 + 0.2, 30, nil},
+		{2.675, 268, nil},
+		{-0.005, -1, nil},
+		{math.NaN(), 0, ErrOutOfRange},
+		{math.Inf(1), 0, ErrOutOfRange},
+		{math.Inf(-1), 0, ErrOutOfRange},
+		{1e20, 0, ErrOutOfRange},
+	}
+	for _, tt := range tests {
+		got, err := FromDollars(tt.in)
+		if !errors.Is(err, tt.wantErr) {
+			t.Errorf("FromDollars(%v) error = %v, want %v", tt.in, err, tt.wantErr)
+			continue
+		}
+		if err == nil && got.Cents != tt.want {
+			t.Errorf("FromDollars(%v) = %d cents, want %d", tt.in, got.Cents, tt.want)
+		}
+	}
+}
+
+func TestAddSub(t *testing.T) {
+	tests := []struct {
+		name    string
+		a, b    Money
+		add     int64
+		sub     int64
+		wantErr error
+	}{
+		{"usd", FromCents(1999), FromCents(1), 2000, 1998, nil},
+		{"zero value is usd", Money{}, FromCents(250), 250, -250, nil},
+		{"mixed currencies", FromCents(100), Money{Cents: 100, Currency: "eur"}, 0, 0, ErrCurrencyMismatch},
+		{"overflow", FromCents(math.MaxInt64), FromCents(1), 0, 0, ErrOutOfRange},
+	}
+	for _, tt := range tests {
+		sum, err := tt.a.Add(tt.b)
+		if !errors.Is(err, tt.wantErr) {
+			t.Errorf("%s: Add error = %v, want %v", tt.name, err, tt.wantErr)
+			continue
+		}
+		if err != nil {
+			continue
+		}
+		diff, err := tt.a.Sub(tt.b)
+		if err != nil {
+			t.Errorf("%s: Sub error = %v", tt.name, err)
+			continue
+		}
+		if sum.Cents != tt.add || diff.Cents != tt.sub {
+			t.Errorf("%s: Add = %d, Sub = %d, want %d and %d", tt.name, sum.Cents, diff.Cents, tt.add, tt.sub)
+		}
+	}
+
+	if _, err := FromCents(math.MinInt64).Sub(FromCents(1)); !errors.Is(err, ErrOutOfRange) {
+		t.Errorf("Sub underflow error = %v, want ErrOutOfRange", err)
+	}
+}
+
+func TestSum(t *testing.T) {
+	got, err := Sum(FromCents(1250), FromCents(399), FromCents(200), FromCents(75))
+	if err != nil || got.Cents != 1924 {
+		t.Errorf("Sum = %d, %v, want 1924", got.Cents, err)
+	}
+	if got, err := Sum(); err != nil || got.Cents != 0 {
+		t.Errorf("Sum() = %d, %v, want 0", got.Cents, err)
+	}
+	if _, err := Sum(FromCents(1), Money{Cents: 1, Currency: "eur"}); !errors.Is(err, ErrCurrencyMismatch) {
+		t.Errorf("Sum of mixed currencies error = %v, want ErrCurrencyMismatch", err)
+	}
+}
+
+func TestPercent(t *testing.T) {
+	tests := []struct {
+		cents, bps, want int64
+	}{
+		{1000, 600, 60},
+		{1999, 600, 120},  // 119.94
+		{1250, 600, 75},   // exactly 75
+		{125, 1000, 13},   // 12.5 rounds up
+		{-125, 1000, -13}, // and away from zero when negative
+		{333, 3333, 111},  // 110.99
+		{1, 4999, 0},
+	}
+	for _, tt := range tests {
+		got, err := FromCents(tt.cents).Percent(tt.bps)
+		if err != nil || got.Cents != tt.want {
+			t.Errorf("%d cents at %d bps = %d, %v, want %d", tt.cents, tt.bps, got.Cents, err, tt.want)
+		}
+	}
+
+	for _, cents := range []int64{math.MaxInt64/600 + 1, math.MinInt64/600 - 1, math.MaxInt64} {
+		if got, err := FromCents(cents).Percent(600); !errors.Is(err, ErrOutOfRange) {
+			t.Errorf("%d cents at 600 bps = %d, %v, want ErrOutOfRange", cents, got.Cents, err)
+		}
+	}
+}
+
+func TestMul(t *testing.T) {
+	tests := []struct {
+		cents   int64
+		n       int
+		want    int64
+		wantErr error
+	}{
+		{1999, 3, 5997, nil},
+		{-250, 2, -500, nil},
+		{1999, 0, 0, nil},
+		{math.MaxInt64, 1, math.MaxInt64, nil},
+		// a quantity chosen to wrap the line total to a few cents
+		{749, int(math.MaxInt64/749) + 2, 0, ErrOutOfRange},
+		{math.MaxInt64/2 + 1, 2, 0, ErrOutOfRange},
+		{math.MinInt64, -1, 0, ErrOutOfRange},
+		{-1, math.MinInt64, 0, ErrOutOfRange},
+		{math.MinInt64 / 2, 3, 0, ErrOutOfRange},
+	}
+	for _, tt := range tests {
+		got, err := FromCents(tt.cents).Mul(tt.n)
+		if !errors.Is(err, tt.wantErr) {
+			t.Errorf("%d cents times %d error = %v, want %v", tt.cents, tt.n, err, tt.wantErr)
+			continue
+		}
+		if err == nil && got.Cents != tt.want {
+			t.Errorf("%d cents times %d = %d, want %d", tt.cents, tt.n, got.Cents, tt.want)
+		}
+	}
+}
+
+func TestScan(t *testing.T) {
+	tests := []struct {
+		src     any
+		want    int64
+		wantErr bool
+	}{
+		{nil, 0, false},
+		{19.99, 1999, false},
+		{int64(7), 700, false},
+		{[]byte("12.50"), 1250, false},
+		{"0.05", 5, false},
+		{math.NaN(), 0, true},
+		{math.Inf(1), 0, true},
+		{int64(math.MaxInt64), 0, true},
+		{true, 0, true},
+	}
+	for _, tt := range tests {
+		var m Money
+		err := m.Scan(tt.src)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("Scan(%v) = %v, want an error", tt.src, m)
+			}
+			continue
+		}
+		if err != nil || m.Cents != tt.want {
+			t.Errorf("Scan(%v) = %d, %v, want %d", tt.src, m.Cents, err, tt.want)
+		}
+	}
+}
+
+func TestJSON(t *testing.T) {
+	var v struct {
+		Price Money `json:"price"`
+		Fee   Money `json:"fee"`
+	}
+	if err := json.Unmarshal([]byte(`{"price": 5.29, "fee": "-0.50"}`), &v); err != nil {
+		t.Fatal(err)
+	}
+	if v.Price.Cents != 529 || v.Fee.Cents != -50 {
+		t.Fatalf("decoded %d and %d cents, want 529 and -50", v.Price.Cents, v.Fee.Cents)
+	}
+	out, err := json.Marshal(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(out) != `{"price":5.29,"fee":-0.50}` {
+		t.Errorf("encoded %s", out)
+	}
+}
@@ -0,0 +1,118 @@
+package orders
+
+import (
+	"context"
+	"errors"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// testDB connects to the Postgres at TEST_DB_STRING, which must already have
+// the app's schema and migrations. Tests that need it are skipped without one.
+// Rows they create use fresh IDs and are left behind, since order_events
+// can't be deleted.
+func testDB(t *testing.T) *pgxpool.Pool {
+	t.Helper()
+	connStr := os.Getenv("TEST_DB_STRING")
+	if connStr == "" {
+		t.Skip("TEST_DB_STRING is not set")
+	}
+	conn, err := pgxpool.New(context.Background(), connStr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(conn.Close)
+	return conn
+}
+
+// insertOrder creates an unclaimed order in status at a new restaurant.
+func insertOrder(t *testing.T, conn *pgxpool.Pool, status OrderStatus) uuid.UUID {
+	t.Helper()
+	ctx := context.Background()
+	restaurantID, orderID := uuid.New(), uuid.New()
+
+	_, err := conn.Exec(ctx, `INSERT INTO restaurants (restaurant_id, restaurant_name) VALUES ($1, $2)`,
+		restaurantID, "Accept Test Grill")
+	if err != nil {
+		t.Fatalf("insert restaurant: %v", err)
+	}
+
+	now := time.Now()
+	_, err = conn.Exec(ctx, `
+		INSERT INTO orders (
+			id, customer_id, restaurant_id, order_items,
+			subtotal, delivery_fee, dasher_fee, tax, platform_fee, tip, total,
+			status, delivery_address, created_at, updated_at
+		) VALUES ($1, $2, $3, '[]', 10, 1, 1, 0.60, 0.50, 0, 13.10, $4, 'Potomac Hall', $5, $5)
+	`, orderID, uuid.New(), restaurantID, status, now)
+	if err != nil {
+		t.Fatalf("insert order: %v", err)
+	}
+	return orderID
+}
+
+func TestAcceptOrderConcurrentClaims(t *testing.T) {
+	conn := testDB(t)
+	s := NewOrderService(conn, nil, nil, nil)
+	orderID := insertOrder(t, conn, StatusConfirmed)
+
+	const dashers = 20
+	var (
+		wg      sync.WaitGroup
+		start   = make(chan struct{})
+		winners = make(chan uuid.UUID, dashers)
+		errs    = make(chan error, dashers)
+	)
+	for i := 0; i < dashers; i++ {
+		wg.Add(1)
+		go func(dasherID uuid.UUID) {
+			defer wg.Done()
+			<-start
+			if _, err := s.AcceptOrder(context.Background(), orderID, dasherID); err != nil {
+				errs <- err
+				return
+			}
+			winners <- dasherID
+		}(uuid.New())
+	}
+	close(start)
+	wg.Wait()
+	close(winners)
+	close(errs)
+
+	if len(winners) != 1 {
+		t.Fatalf("%d dashers claimed the order, want exactly 1", len(winners))
+	}
+	for err := range errs {
+		if !errors.Is(err, ErrOrderAlreadyClaimed) {
+			t.Errorf("losing claim returned %v, want ErrOrderAlreadyClaimed", err)
+		}
+	}
+
+	winner := <-winners
+	order, err := s.GetOrderByID(context.Background(), orderID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if order.DasherID == nil || *order.DasherID != winner {
+		t.Errorf("dasher_id = %v, want %s", order.DasherID, winner)
+	}
+	if order.Status != StatusConfirmed {
+		t.Errorf("status = %s, want %s", order.Status, StatusConfirmed)
+	}
+}
+
+func TestAcceptOrderRejectsUnpaidOrders(t *testing.T) {
+	conn := testDB(t)
+	s := NewOrderService(conn, nil, nil, nil)
+	orderID := insertOrder(t, conn, StatusPending)
+
+	if _, err := s.AcceptOrder(context.Background(), orderID, uuid.New()); !errors.Is(err, ErrOrderNotClaimable) {
+		t.Fatalf("AcceptOrder on a pending order returned %v, want ErrOrderNotClaimable", err)
+	}
+}
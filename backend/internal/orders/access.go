@@ -0,0 +1,48 @@
+package orders
+
+import (
+	"campusDoordash/internal/auth"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// Viewer is the authenticated user asking to read orders.
+type Viewer struct {
+	UserID uuid.UUID
+	Roles  auth.Roles
+}
+
+// viewerFrom builds the Viewer for the request. ok is false if AuthMiddleware
+// did not store a valid user ID.
+func viewerFrom(c *gin.Context) (Viewer, bool) {
+	userID, err := uuid.Parse(c.GetString("user_id"))
+	if err != nil {
+		return Viewer{}, false
+	}
+	return Viewer{UserID: userID, Roles: auth.RolesFrom(c)}, true
+}
+
+// CanView reports whether v may read order: its customer, its assigned
+// dasher, staff of its restaurant, or an admin.
+func (v Viewer) CanView(order *Order) bool {
+	switch {
+	case order.CustomerID == v.UserID:
+		return true
+	case order.DasherID != nil && *order.DasherID == v.UserID:
+		return true
+	case v.Roles.StaffOf(order.RestaurantID):
+		return true
+	}
+	return v.Roles.Has(auth.RoleAdmin)
+}
+
+// CanViewCustomer reports whether v may list the orders placed by customerID.
+func (v Viewer) CanViewCustomer(customerID uuid.UUID) bool {
+	return customerID == v.UserID || v.Roles.Has(auth.RoleAdmin)
+}
+
+// CanViewRestaurant reports whether v may list every order at restaurantID.
+func (v Viewer) CanViewRestaurant(restaurantID uuid.UUID) bool {
+	return v.Roles.StaffOf(restaurantID) || v.Roles.Has(auth.RoleAdmin)
+}
@@ -0,0 +1,117 @@
+package orders
+
+import (
+	"campusDoordash/internal/money"
+	"campusDoordash/internal/payments"
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+)
+
+type RefundStatus string
+
+const (
+	RefundNone    RefundStatus = "none"
+	RefundPending RefundStatus = "pending"
+	RefundPartial RefundStatus = "partial"
+	RefundFull    RefundStatus = "full"
+)
+
+var ErrInvalidRefund = errors.New("refund amount must be positive and no more than the amount still paid")
+
+// CancelOrder cancels an order and gives the customer their money back.
+// refundAmount nil refunds everything still paid; a payment that was never
+// captured has its PaymentIntent cancelled instead. The order row stays
+// locked while Stripe is called so two cancels can't both refund.
+func (s *OrderService) CancelOrder(ctx context.Context, orderID uuid.UUID, actor Actor, reason string, refundAmount *money.Money) (*Order, error) {
+	tx, err := s.conn.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback(ctx)
+
+	var currentStatus OrderStatus
+	var total, refunded money.Money
+	var paymentIntentID *string
+
+	err = tx.QueryRow(ctx, `
+		SELECT status, total, refunded_amount, payment_intent_id
+		FROM orders
+		WHERE id = $1
+		FOR UPDATE
+	`, orderID).Scan(&currentStatus, &total, &refunded, &paymentIntentID)
+
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrOrderNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if err := ValidateTransition(currentStatus, StatusCancelled, actor.Role); err != nil {
+		return nil, err
+	}
+
+	if refundAmount != nil {
+		remaining, err := total.Sub(refunded)
+		if err != nil {
+			return nil, err
+		}
+		if refundAmount.IsNegative() || refundAmount.IsZero() || refundAmount.Cents > remaining.Cents {
+			return nil, ErrInvalidRefund
+		}
+	}
+
+	refundStatus := RefundNone
+	if paymentIntentID != nil {
+		reversal, err := payments.ReversePayment(*paymentIntentID, refundAmount, "cancel-"+orderID.String())
+		if err != nil {
+			return nil, fmt.Errorf("failed to return payment: %w", err)
+		}
+		if reversal.Refunded {
+			refundStatus = RefundPending
+		}
+	}
+
+	now := time.Now()
+	_, err = tx.Exec(ctx, `
+		UPDATE orders
+		SET status = $1, cancelled_at = $2, updated_at = $2, refund_status = $3
+		WHERE id = $4
+	`, StatusCancelled, now, refundStatus, orderID)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := recordEvent(ctx, tx, orderID, &currentStatus, StatusCancelled, actor, reason, now); err != nil {
+		return nil, fmt.Errorf("failed to record order event: %v", err)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return nil, err
+	}
+
+	return s.GetOrderByID(ctx, orderID)
+}
+
+// ApplyRefund records what Stripe reports as refunded for an order's payment.
+// refunded is cumulative and only ever grows, so replays and late deliveries
+// of older refund events are harmless.
+func (s *OrderService) ApplyRefund(ctx context.Context, paymentIntentID string, refunded money.Money, full bool) error {
+	status := RefundPartial
+	if full {
+		status = RefundFull
+	}
+
+	_, err := s.conn.Exec(ctx, `
+		UPDATE orders
+		SET refunded_amount = $1, refund_status = $2, updated_at = NOW()
+		WHERE payment_intent_id = $3 AND refunded_amount <= $1
+	`, refunded, status, paymentIntentID)
+
+	return err
+}
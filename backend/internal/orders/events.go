@@ -0,0 +1,83 @@
+package orders
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+)
+
+// Actor is whoever caused a status change. ID is nil for system actors like the Stripe webhook.
+type Actor struct {
+	ID   *uuid.UUID
+	Role Role
+}
+
+type OrderEvent struct {
+	ID         int64        `json:"id" db:"id"`
+	OrderID    uuid.UUID    `json:"order_id" db:"order_id"`
+	ActorID    *uuid.UUID   `json:"actor_id,omitempty" db:"actor_id"`
+	ActorRole  Role         `json:"actor_role" db:"actor_role"`
+	FromStatus *OrderStatus `json:"from_status,omitempty" db:"from_status"`
+	ToStatus   OrderStatus  `json:"to_status" db:"to_status"`
+	Reason     *string      `json:"reason,omitempty" db:"reason"`
+	CreatedAt  time.Time    `json:"created_at" db:"created_at"`
+}
+
+// recordEvent appends a status change to order_events. It must be called with
+// the same transaction that updates the order so the two never disagree.
+func recordEvent(ctx context.Context, tx pgx.Tx, orderID uuid.UUID, from *OrderStatus, to OrderStatus, actor Actor, reason string, at time.Time) error {
+	var reasonArg *string
+	if reason != "" {
+		reasonArg = &reason
+	}
+
+	_, err := tx.Exec(ctx, `
+		INSERT INTO order_events (order_id, actor_id, actor_role, from_status, to_status, reason, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+	`, orderID, actor.ID, actor.Role, from, to, reasonArg, at)
+
+	return err
+}
+
+// GetOrderTimeline returns every recorded status change for an order, oldest first.
+func (s *OrderService) GetOrderTimeline(ctx context.Context, orderID uuid.UUID) ([]OrderEvent, error) {
+	query := `
+		SELECT id, order_id, actor_id, actor_role, from_status, to_status, reason, created_at
+		FROM order_events
+		WHERE order_id = $1
+		ORDER BY created_at ASC, id ASC
+	`
+
+	rows, err := s.conn.Query(ctx, query, orderID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var events []OrderEvent
+	for rows.Next() {
+		var e OrderEvent
+		err := rows.Scan(
+			&e.ID,
+			&e.OrderID,
+			&e.ActorID,
+			&e.ActorRole,
+			&e.FromStatus,
+			&e.ToStatus,
+			&e.Reason,
+			&e.CreatedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+		events = append(events, e)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return events, nil
+}
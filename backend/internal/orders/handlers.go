@@ -1,6 +1,13 @@
 package orders
 
 import (
+	"campusDoordash/internal/auth"
+	"campusDoordash/internal/locations"
+	"campusDoordash/internal/money"
+	"campusDoordash/internal/payments"
+	"campusDoordash/internal/restaurants"
+	"errors"
+	"io"
 	"log"
 	"net/http"
 
@@ -17,31 +24,53 @@ func NewOrderHandlers(service *OrderService) *OrderHandlers{
 }
 
 func (h * OrderHandlers) CreateOrderHandler (c * gin.Context){	
-	userID,exists := c.Get("user_id")
-	
-	if !exists{
-		c.JSON(http.StatusBadRequest, gin.H{"error":"invalid customer id"})
-		return
-	}
-	
-	authenticatedUserID, ok := userID.(uuid.UUID)
-	if !ok{
+	authenticatedUserID, err := uuid.Parse(c.GetString("user_id"))
+	if err != nil{
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "invalid user ID format"}) 
 		return
 	}
 	var req CreateOrderRequest	
-	if err := c.ShouldBindJSON(&req); err != nil{ c.JSON(http.StatusBadRequest, gin.H{"error":"invalid request body"})
+	err = c.ShouldBindJSON(&req)
+	if err != nil{ c.JSON(http.StatusBadRequest, gin.H{"error":"invalid request body"})
 		return
 	}
+	// orders are always placed for the signed in user, whatever the body says
+	req.CustomerID = authenticatedUserID
 	log.Printf("Incoming order: %+v\n", req)
 	if len(req.OrderItems) == 0{
 		c.JSON(http.StatusBadRequest, gin.H{"error": "order must contain at least 1 item"})
 		return
 	}
-	log.Println(req.CustomerID)	
-	log.Println(authenticatedUserID)
-	order, clientSecret, err := h.service.CreateOrder(c.Request.Context(), req)
-
+	var order *Order
+	var clientSecret string
+	if key := c.GetHeader("Idempotency-Key"); key != ""{
+		if len(key) > 255{
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Idempotency-Key must be at most 255 characters"})
+			return
+		}
+
+		var replayed bool
+		order, clientSecret, replayed, err = h.service.CreateOrderIdempotent(c.Request.Context(), key, req)
+		if replayed{
+			c.Header("Idempotent-Replayed", "true")
+		}
+	}else{
+		order, clientSecret, err = h.service.CreateOrder(c.Request.Context(), req)
+	}
+
+	if errors.Is(err, ErrInvalidOrderItem) || errors.Is(err, ErrInvalidTip) || errors.Is(err, ErrIdempotencyKeyReused) || locations.IsDeliveryError(err){
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error()})
+		return
+	}
+	if errors.Is(err, ErrRequestInProgress){
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	}
+	var closed *restaurants.ClosedError
+	if errors.As(err, &closed){
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error(), "next_open_at": closed.NextOpenAt})
+		return
+	}
 	if err != nil{
 		log.Printf("failed to create order: %v", err)	
 		c.JSON(http.StatusInternalServerError, gin.H{
@@ -51,7 +80,8 @@ func (h * OrderHandlers) CreateOrderHandler (c * gin.Context){
 	}
 
 	c.JSON(http.StatusCreated, gin.H{"order": order, 
-	"client_secret": clientSecret})
+	"client_secret": clientSecret,
+	"price_drift": priceDrift(req.OrderItems, order.OrderItems)})
 }	
 
 func (h * OrderHandlers) GetOrderByIDHandler(c * gin.Context){
@@ -70,6 +100,11 @@ func (h * OrderHandlers) GetOrderByIDHandler(c * gin.Context){
 		})
 		return
 	}
+
+	if viewer, ok := viewerFrom(c); !ok || !viewer.CanView(order){
+		c.JSON(http.StatusForbidden, gin.H{"error": "you do not have access to this order"})
+		return
+	}
 	c.JSON(http.StatusOK, order)
 }
 
@@ -82,6 +117,11 @@ func(h * OrderHandlers) GetCustomerOrdersHandler(c * gin.Context){
 			})
 		return
 	}
+
+	if viewer, ok := viewerFrom(c); !ok || !viewer.CanViewCustomer(customerID){
+		c.JSON(http.StatusForbidden, gin.H{"error": "you can only view your own orders"})
+		return
+	}
 		
 	orders, err := h.service.GetOrdersByCustomerID(c.Request.Context(), customerID)
 
@@ -111,6 +151,11 @@ func(h * OrderHandlers) GetRestaurantOrdersHandlers(c * gin.Context){
 		return 
 	}
 
+	if viewer, ok := viewerFrom(c); !ok || !viewer.CanViewRestaurant(restaurantID){
+		c.JSON(http.StatusForbidden, gin.H{"error": "you do not work at this restaurant"})
+		return
+	}
+
 	orders,err := h.service.GetOrderByRestaurantID(c.Request.Context(), restaurantID)
 
 	if err != nil{
@@ -129,6 +174,97 @@ func(h * OrderHandlers) GetRestaurantOrdersHandlers(c * gin.Context){
 
 }
 
+func(h * OrderHandlers) GetOrderTimelineHandler(c * gin.Context){
+	orderID, err := uuid.Parse(c.Param("id"))
+	if err != nil{
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid order id"})
+		return
+	}
+
+	order, err := h.service.GetOrderByID(c.Request.Context(), orderID)
+	if err != nil{
+		c.JSON(http.StatusNotFound, gin.H{"error": "order not found"})
+		return
+	}
+
+	if viewer, ok := viewerFrom(c); !ok || !viewer.CanView(order){
+		c.JSON(http.StatusForbidden, gin.H{"error": "you do not have access to this order"})
+		return
+	}
+
+	timeline, err := h.service.GetOrderTimeline(c.Request.Context(), orderID)
+	if err != nil{
+		log.Printf("failed to fetch order timeline %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch order timeline"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"order_id": orderID,
+		"timeline": timeline,
+		"count": len(timeline),
+	})
+}
+
+func(h * OrderHandlers) CancelOrderHandler(c * gin.Context){
+	orderID, err := uuid.Parse(c.Param("id"))
+	if err != nil{
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid order id"})
+		return
+	}
+
+	var req struct{
+		Reason 			string 			`json:"reason"`
+		RefundAmount 	*money.Money 	`json:"refund_amount"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil && !errors.Is(err, io.EOF){
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request body"})
+		return
+	}
+
+	order, err := h.service.GetOrderByID(c.Request.Context(), orderID)
+	if err != nil{
+		c.JSON(http.StatusNotFound, gin.H{"error": "order not found"})
+		return
+	}
+
+	actor, err := callerActor(c, order, StatusCancelled)
+	if errors.Is(err, ErrInvalidTransition){
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil{
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+		return
+	}
+	if actor.Role == RoleCustomer && req.RefundAmount != nil{
+		c.JSON(http.StatusForbidden, gin.H{"error": "customers always receive a full refund"})
+		return
+	}
+
+	cancelled, err := h.service.CancelOrder(c.Request.Context(), orderID, actor, req.Reason, req.RefundAmount)
+	switch{
+	case errors.Is(err, ErrOrderNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	case errors.Is(err, ErrInvalidRefund):
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error()})
+		return
+	case errors.Is(err, ErrInvalidTransition), errors.Is(err, payments.ErrPaymentProcessing):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	case err != nil:
+		log.Printf("failed to cancel order: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to cancel order"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "order cancelled",
+		"order": cancelled,
+	})
+}
+
 func(h * OrderHandlers) UpdateOrderStatusHandler(c * gin.Context){
 	orderID, err := uuid.Parse(c.Param("id"))
 	
@@ -142,6 +278,7 @@ func(h * OrderHandlers) UpdateOrderStatusHandler(c * gin.Context){
 	
 	var req struct{
 		Status OrderStatus `json:"status" binding:"required"`
+		Reason string `json:"reason"`
 	}
 
 	if err := c.ShouldBindJSON(&req); err != nil{
@@ -153,24 +290,38 @@ func(h * OrderHandlers) UpdateOrderStatusHandler(c * gin.Context){
 		return 
 	}
 
-	//valid statuses
-	validstatuses := map[OrderStatus]bool{
-		StatusPending: 		true, 
-		StatusConfirmed: 	true, 
-		StatusPreparing: 	true,
-		StatusReady:		true, 
-		StatusPickedUp:		true, 
-		StatusDelivered:	true,
-		StatusCancelled: 	true,
+	if !req.Status.IsValid(){
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid status"})
+		return
 	}
 
-	if !validstatuses[req.Status]{
-		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid status"})
+	if req.Status == StatusCancelled{
+		c.JSON(http.StatusBadRequest, gin.H{"error": "use POST /api/orders/:id/cancel to cancel an order"})
+		return
+	}
+
+	order, err := h.service.GetOrderByID(c.Request.Context(), orderID)
+	if err != nil{
+		c.JSON(http.StatusNotFound, gin.H{"error": "order not found"})
+		return
+	}
+
+	actor, err := callerActor(c, order, req.Status)
+	if errors.Is(err, ErrInvalidTransition){
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
 		return
 	}
-	
-	err = h.service.UpdateOrderStatus(c.Request.Context(), orderID, req.Status)
 	if err != nil{
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+		return
+	}
+
+	err = h.service.UpdateOrderStatus(c.Request.Context(), orderID, req.Status, actor, req.Reason)
+	if err != nil{
+		if errors.Is(err, ErrInvalidTransition){
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+			return
+		}
 		log.Printf("failed to update order status: %v", err)
 		c.JSON(	
 			http.StatusInternalServerError, 
@@ -210,15 +361,6 @@ func (h * OrderHandlers) AssignDasherHandler(c *gin.Context){
 }
 
 func (h * OrderHandlers) GetAvailableOrdersHandler(c * gin.Context){
-	isDasher := c.GetBool("is_dasher")
-
-	if !isDasher{
-		c.JSON(http.StatusForbidden, gin.H{
-			"error":"access restricted to dashers",
-		})
-		return
-	}
-
 	orders, err := h.service.GetAvailableOrders(c.Request.Context())
 
 	if err != nil{
@@ -235,15 +377,6 @@ func (h * OrderHandlers) GetAvailableOrdersHandler(c * gin.Context){
 }
 
 func (h * OrderHandlers) AcceptOrderHandler(c * gin.Context){
-	isDasher := c.GetBool("is_dasher")
-
-	if !isDasher{
-		c.JSON(http.StatusForbidden, gin.H{
-			"error": "access restricted to dashers",
-		})
-		return
-	}
-
 	dasherID, err := uuid.Parse(c.GetString("user_id"))
 	if err != nil{
 
@@ -265,8 +398,16 @@ func (h * OrderHandlers) AcceptOrderHandler(c * gin.Context){
 		return
 	}	
 
-	err = h.service.AcceptOrder(c.Request.Context(), orderID, dasherID)
+	order, err := h.service.AcceptOrder(c.Request.Context(), orderID, dasherID)
 
+	if errors.Is(err, ErrOrderNotFound){
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	if errors.Is(err, ErrOrderAlreadyClaimed) || errors.Is(err, ErrOrderNotClaimable){
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	}
 	if err != nil{
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
@@ -276,20 +417,11 @@ func (h * OrderHandlers) AcceptOrderHandler(c * gin.Context){
 		"message":    "order accepted successfully",
 		"order_id":   orderID,
 		"dasher_id":  dasherID,
-		"new_status": StatusConfirmed,
+		"status":     order.Status,
 	})
 }
 
 func (h * OrderHandlers) GetDasherOrdersHandler(c * gin.Context){
-	isDasher := c.GetBool("is_dasher")
-	if !isDasher{
-		c.JSON(http.StatusForbidden, gin.H{
-			"error":"access restricted to dashers",
-		})
-
-		return 
-	}
-
 	dasherID, err := uuid.Parse(c.GetString("user_id"))
 	if err != nil{
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "invalid dasher id"})
@@ -308,6 +440,67 @@ func (h * OrderHandlers) GetDasherOrdersHandler(c * gin.Context){
 	})
 }
 
+func (h * OrderHandlers) GetDasherEarningsHandler(c * gin.Context){
+	dasherID, err := uuid.Parse(c.GetString("user_id"))
+	if err != nil{
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "invalid dasher id"})
+		return
+	}
+
+	earnings, err := h.service.GetDasherEarnings(c.Request.Context(), dasherID)
+	if err != nil{
+		log.Printf("failed to fetch dasher earnings %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch dasher earnings"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"earnings": earnings})
+}
+
+func (h * OrderHandlers) AddTipHandler(c * gin.Context){
+	orderID, err := uuid.Parse(c.Param("id"))
+	if err != nil{
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid order id"})
+		return
+	}
+
+	customerID, err := uuid.Parse(c.GetString("user_id"))
+	if err != nil{
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "invalid user id"})
+		return
+	}
+
+	var req struct{
+		Tip money.Money `json:"tip" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil{
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request body"})
+		return
+	}
+
+	charge, clientSecret, err := h.service.AddTip(c.Request.Context(), orderID, customerID, req.Tip)
+	switch{
+	case errors.Is(err, ErrOrderNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	case errors.Is(err, ErrInvalidTip):
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error()})
+		return
+	case errors.Is(err, ErrTipNotAllowed), errors.Is(err, ErrTipWindowClosed), errors.Is(err, payments.ErrPaymentProcessing):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	case err != nil:
+		log.Printf("failed to add tip: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to add tip"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"tip_charge": charge,
+		"client_secret": clientSecret,
+	})
+}
+
 func (h * OrderHandlers) GetHistory(c * gin.Context){
 	
 	userID, err := uuid.Parse(c.GetString("user_id"))
@@ -331,12 +524,6 @@ func (h * OrderHandlers) GetHistory(c * gin.Context){
 }
 
 func (h * OrderHandlers) CompleteOrderHandler(c * gin.Context){
-	isDasher := c.GetBool("is_dasher")
-	if !isDasher {
-		c.JSON(http.StatusForbidden, gin.H{"error": "access restricted to dashers"})
-		return
-	}
-	
 	orderID, err := uuid.Parse(c.Param("id"))
 
 	if err != nil{
@@ -352,6 +539,10 @@ func (h * OrderHandlers) CompleteOrderHandler(c * gin.Context){
 	}
 
 	success, err := h.service.CompleteOrder(c.Request.Context(), orderID, dasherID)
+	if errors.Is(err, ErrInvalidTransition){
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	}
 	if err != nil{
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
@@ -367,3 +558,47 @@ func (h * OrderHandlers) CompleteOrderHandler(c * gin.Context){
 	}
 }
 
+
+
+// errNoOrderAccess is returned by callerActor when the user has no
+// connection to the order at all.
+var errNoOrderAccess = errors.New("you do not have access to this order")
+
+// callerActor works out which role the authenticated user is acting as for
+// order when moving it to status to. A user can hold several roles, so the
+// first one related to this order that is allowed to make the change wins.
+// Dashers only act on orders they have claimed through AcceptOrder. The error
+// is errNoOrderAccess (403) when the user has no role on the order, or a
+// *TransitionError (409) when none of their roles may make the change from
+// the order's current status.
+func callerActor(c * gin.Context, order *Order, to OrderStatus) (actor Actor, err error){
+	if userID, err := uuid.Parse(c.GetString("user_id")); err == nil{
+		actor.ID = &userID
+	}
+	roles := auth.RolesFrom(c)
+
+	var candidates []Role
+	if actor.ID != nil && *actor.ID == order.CustomerID{
+		candidates = append(candidates, RoleCustomer)
+	}
+	if roles.Has(auth.RoleDasher) && actor.ID != nil && order.DasherID != nil && *order.DasherID == *actor.ID{
+		candidates = append(candidates, RoleDasher)
+	}
+	if roles.StaffOf(order.RestaurantID){
+		candidates = append(candidates, RoleRestaurant)
+	}
+	if roles.Has(auth.RoleAdmin){
+		candidates = append(candidates, RoleAdmin)
+	}
+
+	if len(candidates) == 0{
+		return actor, errNoOrderAccess
+	}
+	for _, role := range candidates{
+		if CanTransition(order.Status, to, role){
+			actor.Role = role
+			return actor, nil
+		}
+	}
+	return actor, &TransitionError{From: order.Status, To: to, Role: candidates[0]}
+}
@@ -0,0 +1,166 @@
+package orders
+
+import (
+	"campusDoordash/internal/payments"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+)
+
+var (
+	ErrIdempotencyKeyReused = errors.New("idempotency key was already used with a different request body")
+	ErrRequestInProgress    = errors.New("a request with this idempotency key is still being processed")
+)
+
+const (
+	// idempotencyTTL is how long a key is remembered before it can be reused for a new order.
+	idempotencyTTL = 24 * time.Hour
+	// idempotencyLock is how long a request holds its key before a retry may take over.
+	idempotencyLock = 30 * time.Second
+)
+
+// CreateOrderIdempotent creates an order at most once per customer and key.
+// Repeating a request with the same key and body returns the stored order
+// with replayed set, and its client secret fetched again from Stripe; the
+// secret itself is never stored. A different body fails with
+// ErrIdempotencyKeyReused. The key is also forwarded to Stripe, so retries
+// after a failure reuse the same PaymentIntent.
+func (s *OrderService) CreateOrderIdempotent(ctx context.Context, key string, req CreateOrderRequest) (order *Order, clientSecret string, replayed bool, err error) {
+	body, err := json.Marshal(req)
+	if err != nil {
+		return nil, "", false, err
+	}
+	sum := sha256.Sum256(body)
+	requestHash := hex.EncodeToString(sum[:])
+
+	orderID, done, err := s.claimIdempotencyKey(ctx, req.CustomerID, key, requestHash)
+	if err != nil {
+		return nil, "", false, err
+	}
+
+	if done {
+		order, secret, err := s.resumeOrder(ctx, orderID)
+		if err != nil {
+			return nil, "", false, err
+		}
+		return order, secret, true, nil
+	}
+
+	order, clientSecret, err = s.resumeOrder(ctx, orderID)
+	if errors.Is(err, ErrOrderNotFound) {
+		stripeKey := fmt.Sprintf("order-%s-%s", req.CustomerID, key)
+		order, clientSecret, err = s.createOrder(ctx, orderID, req, stripeKey)
+	}
+	if err != nil {
+		// release the key so a retry doesn't have to wait out the lock
+		if _, unlockErr := s.conn.Exec(ctx, `
+			UPDATE order_idempotency_keys SET locked_at = 'epoch'
+			WHERE customer_id = $1 AND key = $2
+		`, req.CustomerID, key); unlockErr != nil {
+			log.Printf("failed to release idempotency key for customer %s: %v", req.CustomerID, unlockErr)
+		}
+		return nil, "", false, err
+	}
+
+	_, err = s.conn.Exec(ctx, `
+		UPDATE order_idempotency_keys
+		SET completed_at = NOW()
+		WHERE customer_id = $1 AND key = $2
+	`, req.CustomerID, key)
+	if err != nil {
+		return nil, "", false, fmt.Errorf("failed to save idempotent response: %v", err)
+	}
+
+	return order, clientSecret, false, nil
+}
+
+// claimIdempotencyKey stores key for customerID, or loads it if it was
+// already seen. It returns the order ID to use, with done set if the first
+// request finished.
+func (s *OrderService) claimIdempotencyKey(ctx context.Context, customerID uuid.UUID, key, requestHash string) (orderID uuid.UUID, done bool, err error) {
+	tx, err := s.conn.Begin(ctx)
+	if err != nil {
+		return uuid.Nil, false, err
+	}
+	defer tx.Rollback(ctx)
+
+	// fresh is set when the key is new or had expired and was taken over
+	var fresh bool
+	err = tx.QueryRow(ctx, `
+		INSERT INTO order_idempotency_keys (customer_id, key, request_hash, order_id)
+		VALUES ($1, $2, $3, $4)
+		ON CONFLICT (customer_id, key) DO UPDATE
+		SET request_hash = EXCLUDED.request_hash, order_id = EXCLUDED.order_id,
+			created_at = NOW(), locked_at = NOW(), completed_at = NULL
+		WHERE order_idempotency_keys.created_at < $5
+		RETURNING true
+	`, customerID, key, requestHash, uuid.New(), time.Now().Add(-idempotencyTTL)).Scan(&fresh)
+	if err != nil && !errors.Is(err, pgx.ErrNoRows) {
+		return uuid.Nil, false, err
+	}
+
+	var storedHash string
+	var lockedAt time.Time
+	var completedAt *time.Time
+	err = tx.QueryRow(ctx, `
+		SELECT request_hash, order_id, locked_at, completed_at
+		FROM order_idempotency_keys
+		WHERE customer_id = $1 AND key = $2
+		FOR UPDATE
+	`, customerID, key).Scan(&storedHash, &orderID, &lockedAt, &completedAt)
+	if err != nil {
+		return uuid.Nil, false, err
+	}
+
+	if storedHash != requestHash {
+		return uuid.Nil, false, ErrIdempotencyKeyReused
+	}
+	if completedAt != nil {
+		return orderID, true, tx.Commit(ctx)
+	}
+
+	// an earlier request still holds the key unless its lock has run out
+	if !fresh {
+		if time.Since(lockedAt) < idempotencyLock {
+			return uuid.Nil, false, ErrRequestInProgress
+		}
+		if _, err := tx.Exec(ctx, `
+			UPDATE order_idempotency_keys SET locked_at = NOW()
+			WHERE customer_id = $1 AND key = $2
+		`, customerID, key); err != nil {
+			return uuid.Nil, false, err
+		}
+	}
+
+	return orderID, false, tx.Commit(ctx)
+}
+
+// resumeOrder loads an order an earlier request with the same key stored,
+// with its PaymentIntent's client secret. It returns ErrOrderNotFound if
+// there is none.
+func (s *OrderService) resumeOrder(ctx context.Context, orderID uuid.UUID) (*Order, string, error) {
+	order, err := s.GetOrderByID(ctx, orderID)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, "", ErrOrderNotFound
+	}
+	if err != nil {
+		return nil, "", err
+	}
+	if order.PaymentIntentID == nil {
+		return nil, "", fmt.Errorf("order %s has no payment intent", orderID)
+	}
+
+	secret, err := payments.ClientSecret(*order.PaymentIntentID)
+	if err != nil {
+		return nil, "", err
+	}
+	return order, secret, nil
+}
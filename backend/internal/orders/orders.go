@@ -2,14 +2,19 @@
 package orders
 
 import (
+	"campusDoordash/internal/locations"
+	"campusDoordash/internal/money"
 	"campusDoordash/internal/payments"
+	"campusDoordash/internal/restaurants"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
 	"time"
 
 	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgxpool"
 )
 
@@ -27,11 +32,28 @@ const(
 
 )
 
+var(
+	ErrOrderNotFound 		= errors.New("order not found")
+	ErrOrderAlreadyClaimed 	= errors.New("order already claimed by another dasher")
+	ErrOrderNotClaimable 	= errors.New("order is not waiting for a dasher")
+)
+
 type OrderItem struct{
 	FoodID 		uuid.UUID 		`json:"food_id"`
 	Quantity 	int				`json:"quantity"`
-	Price 		float64			`json:"price"`
+	// Price is the price of one item including its modifiers
+	Price 		money.Money		`json:"price"`
 	FoodName 	string			`json:"food_name,omitempty"`
+	Modifiers 	[]OrderModifier `json:"modifiers,omitempty"`
+}
+
+// OrderModifier is an option chosen for an item, e.g. "Large" in "Size".
+// Clients only need to send the option ID; the rest is filled in when the order is priced.
+type OrderModifier struct{
+	ModifierOptionID 	uuid.UUID 		`json:"modifier_option_id"`
+	GroupName 			string 			`json:"group_name,omitempty"`
+	Name 				string 			`json:"name,omitempty"`
+	PriceDelta 			money.Money 	`json:"price_delta"`
 }
 
 type Order struct{
@@ -41,12 +63,19 @@ type Order struct{
 	RestaurantID         	uuid.UUID    				`json:"restaurant_id" db:"restaurant_id"`
 	DasherID             	*uuid.UUID   				`json:"dasher_id,omitempty" db:"dasher_id"`
 	OrderItems 				[]OrderItem 				`json:"order_items" db:"order_items"`	
-	Subtotal             	float64      				`json:"subtotal" db:"subtotal"`
-	DeliveryFee          	float64      				`json:"delivery_fee" db:"delivery_fee"`
-	DasherFee            	float64      				`json:"dasher_fee" db:"dasher_fee"`
-	Total                	float64      				`json:"total" db:"total"`
+	Subtotal             	money.Money  				`json:"subtotal" db:"subtotal"`
+	DeliveryFee          	money.Money  				`json:"delivery_fee" db:"delivery_fee"`
+	DasherFee            	money.Money  				`json:"dasher_fee" db:"dasher_fee"`
+	Tax                  	money.Money  				`json:"tax" db:"tax"`
+	PlatformFee          	money.Money  				`json:"platform_fee" db:"platform_fee"`
+	Tip                  	money.Money  				`json:"tip" db:"tip"`
+	Total                	money.Money  				`json:"total" db:"total"`
 	Status					OrderStatus  				`json:"status" db:"status"`
+	// DeliveryAddress is the rendered label of the delivery location, e.g. "Potomac Hall, Front desk, Room 214"
 	DeliveryAddress 		string						`json:"delivery_address" db:"delivery_address"`
+	DeliveryLocationID 		*uuid.UUID 					`json:"delivery_location_id,omitempty" db:"delivery_location_id"`
+	DeliveryDropOffPointID 	*uuid.UUID 					`json:"delivery_drop_off_point_id,omitempty" db:"delivery_drop_off_point_id"`
+	DeliveryRoom 			*string 					`json:"delivery_room,omitempty" db:"delivery_room"`
 	DeliveryInstructions	*string 					`json:"delivery_instructions,omitempty" db:"delivery_instructions"`
 	PaymentIntentID      	*string      				`json:"payment_intent_id,omitempty" db:"payment_intent_id"`
 	UpdatedAt            	time.Time    				`json:"updated_at" db:"updated_at"`
@@ -54,39 +83,118 @@ type Order struct{
 	ReadyAt              	*time.Time   				`json:"ready_at,omitempty" db:"ready_at"`
 	PickedUpAt           	*time.Time   				`json:"picked_up_at,omitempty" db:"picked_at"`
 	DeliveredAt          	*time.Time   				`json:"delivered_at,omitempty" db:"delivered_at"`
+	CancelledAt          	*time.Time   				`json:"cancelled_at,omitempty" db:"cancelled_at"`
+	RefundStatus         	RefundStatus 				`json:"refund_status" db:"refund_status"`
+	RefundedAmount       	money.Money  				`json:"refunded_amount" db:"refunded_amount"`
+	PaymentStatus        	payments.PaymentStatus 		`json:"payment_status" db:"payment_status"`
+}
+
+// orderColumns is the column list every order query selects, in the order scanOrder reads them.
+const orderColumns = `id, created_at, customer_id, restaurant_id, dasher_id,
+			order_items, subtotal, delivery_fee, dasher_fee, tax, platform_fee, tip, total, 
+			status, delivery_address, delivery_instructions, 
+			delivery_location_id, delivery_drop_off_point_id, delivery_room,
+			payment_intent_id, updated_at, confirmed_at, ready_at, 
+			picked_at, delivered_at, cancelled_at, refund_status, refunded_amount, payment_status`
+
+func scanOrder(row pgx.Row) (Order, error){
+	var order Order
+	err := row.Scan(
+		&order.ID,
+		&order.CreatedAt,
+		&order.CustomerID,
+		&order.RestaurantID,
+		&order.DasherID,
+		&order.OrderItems,
+		&order.Subtotal,
+		&order.DeliveryFee,
+		&order.DasherFee,
+		&order.Tax,
+		&order.PlatformFee,
+		&order.Tip,
+		&order.Total,
+		&order.Status,
+		&order.DeliveryAddress,
+		&order.DeliveryInstructions,
+		&order.DeliveryLocationID,
+		&order.DeliveryDropOffPointID,
+		&order.DeliveryRoom,
+		&order.PaymentIntentID,
+		&order.UpdatedAt,
+		&order.ConfirmedAt,
+		&order.ReadyAt,
+		&order.PickedUpAt,
+		&order.DeliveredAt,
+		&order.CancelledAt,
+		&order.RefundStatus,
+		&order.RefundedAmount,
+		&order.PaymentStatus,
+	)
+	return order, err
 }
 
 type CreateOrderRequest struct{
-	CustomerID 				uuid.UUID 		`json:"customer_id" binding:"required"`
+	CustomerID 				uuid.UUID 		`json:"-"` // set from the authenticated user, never the body
 	RestaurantID 			uuid.UUID 		`json:"restaurant_id" binding:"required"`
 	OrderItems				[]OrderItem 	`json:"order_items" binding:"required"`
-	DeliveryAddress 		string 			`json:"delivery_address" binding:"required"`
+	// where to deliver: a saved address, or a campus location with an optional drop-off point and room
+	locations.DeliveryRequest
 	DeliveryInstructions 	*string			`json:"delivery_instructions,omitempty"`	
+	Tip 					money.Money 	`json:"tip"`
 }
 
 type OrderService struct{
 	conn * pgxpool.Pool
+	restaurants * restaurants.RestaurantService
+	locations * locations.LocationService
+	pricing * payments.PricingEngine
 }
 
-func NewOrderService(conn *pgxpool.Pool) *OrderService{
-	return &OrderService{conn}	
+func NewOrderService(conn *pgxpool.Pool, restaurants *restaurants.RestaurantService, locations *locations.LocationService, pricing *payments.PricingEngine) *OrderService{
+	return &OrderService{conn, restaurants, locations, pricing}	
 }
 
 func (s * OrderService) CreateOrder(ctx context.Context, req CreateOrderRequest)(*Order, string, error){
-	orderID := uuid.New()
-	subtotal := calculateSubtotal(req.OrderItems)
-	deliveryFee := 3.99
-	dasherFee := 2.00
-	total :=  subtotal + deliveryFee + dasherFee
-	payment := payments.CalculatePayment(total, orderID.String())
-	intent, err := payments.CreatePaymentIntent(payment)
+	return s.createOrder(ctx, uuid.New(), req, "")
+}
+
+// createOrder prices and stores an order with the given ID. stripeKey, when
+// set, is sent to Stripe as the PaymentIntent's idempotency key.
+func (s * OrderService) createOrder(ctx context.Context, orderID uuid.UUID, req CreateOrderRequest, stripeKey string)(*Order, string, error){
+	items, err := s.priceItems(ctx, req.RestaurantID, req.OrderItems)
+	if err != nil{
+		return nil, "empty secret", err
+	}
+	if req.Tip.IsNegative(){
+		return nil, "empty secret", ErrInvalidTip
+	}
+	if err := s.restaurants.CheckOpen(ctx, req.RestaurantID); err != nil{
+		return nil, "empty secret", err
+	}
+	delivery, err := s.locations.ResolveDelivery(ctx, req.CustomerID, req.DeliveryRequest)
+	if err != nil{
+		return nil, "empty secret", err
+	}
+	instructions := req.DeliveryInstructions
+	if instructions == nil{
+		instructions = delivery.Instructions
+	}
+	subtotal, err := calculateSubtotal(items)
+	if err != nil{
+		return nil, "empty secret", fmt.Errorf("%w: %v", ErrInvalidOrderItem, err)
+	}
+	payment, err := s.pricing.Quote(orderID.String(), subtotal, req.Tip)
+	if err != nil{
+		return nil, "empty secret", fmt.Errorf("%w: order total is out of range", ErrInvalidOrderItem)
+	}
+	intent, err := payments.CreatePaymentIntent(payment, stripeKey)
 
 	if err != nil{
 		return nil, "empty secret",fmt.Errorf("failed to create payment intent %v", err)	
 	}
 	paymentIntentID := intent.ID 
 	log.Println(paymentIntentID)	
-	orderItemsJSON, err := json.Marshal(req.OrderItems)
+	orderItemsJSON, err := json.Marshal(items)
 	
 	
 	if err != nil{
@@ -95,64 +203,61 @@ func (s * OrderService) CreateOrder(ctx context.Context, req CreateOrderRequest)
 	query := `
 		INSERT INTO public.orders(
 			id, customer_id, restaurant_id, order_items, 
-			subtotal, delivery_fee, dasher_fee, total, 
+			subtotal, delivery_fee, dasher_fee, tax, platform_fee, tip, total, 
 			status, delivery_address, delivery_instructions,
+			delivery_location_id, delivery_drop_off_point_id, delivery_room,
 			payment_intent_id, created_at, updated_at
 		) VALUES (
-			$1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14
+			$1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17, $18, $19, $20
 		) 
 
-		RETURNING id, created_at, customer_id, restaurant_id, dasher_id,
-			order_items, subtotal, delivery_fee, dasher_fee, total, 
-			status, delivery_address, delivery_instructions, 
-			payment_intent_id, updated_at, confirmed_at, ready_at, 
-			picked_at, delivered_at
+		RETURNING ` + orderColumns + `
 	`
 
 	
 	now := time.Now()
+
+	tx, err := s.conn.Begin(ctx)
+	if err != nil{
+		return nil, "empty secret", err
+	}
+	defer tx.Rollback(ctx)
 	
-	var order Order 
-	err = s.conn.QueryRow(ctx, query,
+	order, err := scanOrder(tx.QueryRow(ctx, query,
 		orderID, 
 		req.CustomerID, 
 		req.RestaurantID, 
 		orderItemsJSON, 
-		subtotal, 
-		deliveryFee, 
-		dasherFee, 
-		total, 
+		payment.FoodTotal, 
+		payment.DeliveryFee, 
+		payment.DasherFee, 
+		payment.Tax,
+		payment.PlatformFee,
+		payment.Tip,
+		payment.CustomerTotal, 
 		StatusPending, 
-		req.DeliveryAddress, 
-		req.DeliveryInstructions, 
+		delivery.Label, 
+		instructions, 
+		delivery.LocationID,
+		delivery.DropOffPointID,
+		delivery.Room,
 		paymentIntentID,
 		now, 
 		now, 
-	).Scan(
-		&order.ID, 
-		&order.CreatedAt, 
-		&order.CustomerID, 
-		&order.RestaurantID,
-		&order.DasherID,
-		&order.OrderItems,
-		&order.Subtotal,
-		&order.DeliveryFee,
-		&order.DasherFee,
-		&order.Total,
-		&order.Status,
-		&order.DeliveryAddress,
-		&order.DeliveryInstructions,
-		&order.PaymentIntentID,
-		&order.UpdatedAt,
-		&order.ConfirmedAt,
-		&order.ReadyAt,
-		&order.PickedUpAt,
-		&order.DeliveredAt,	
-	)
+	))
 
 	if err != nil{
 		return nil, "empty secret", err
 	}
+
+	customer := Actor{ID: &req.CustomerID, Role: RoleCustomer}
+	if err := recordEvent(ctx, tx, order.ID, nil, StatusPending, customer, "order placed", now); err != nil{
+		return nil, "empty secret", fmt.Errorf("failed to record order event: %v", err)
+	}
+
+	if err := tx.Commit(ctx); err != nil{
+		return nil, "empty secret", err
+	}
 	
 	fmt.Println("Payment Intent created:", paymentIntentID)
 	order.PaymentIntentID = &paymentIntentID
@@ -162,36 +267,11 @@ func (s * OrderService) CreateOrder(ctx context.Context, req CreateOrderRequest)
 
 func (s * OrderService) GetOrderByID(ctx context.Context, orderID uuid.UUID) (*Order, error){
 	query := `
-		SELECT id, created_at, customer_id, restaurant_id, dasher_id,
-			order_items, subtotal, delivery_fee, dasher_fee, total, 
-			status, delivery_address, delivery_instructions,
-			payment_intent_id, updated_at, confirmed_at, ready_at, 
-			picked_at, delivered_at
+		SELECT ` + orderColumns + `
 		FROM orders 
 		WHERE id = $1
 	`
-	var order Order
-	err := s.conn.QueryRow(ctx, query, orderID).Scan(
-		&order.ID,
-		&order.CreatedAt,
-		&order.CustomerID,
-		&order.RestaurantID,
-		&order.DasherID,
-		&order.OrderItems,
-		&order.Subtotal,
-		&order.DeliveryFee,
-		&order.DasherFee,
-		&order.Total,
-		&order.Status,
-		&order.DeliveryAddress,
-		&order.DeliveryInstructions,
-		&order.PaymentIntentID,
-		&order.UpdatedAt,
-		&order.ConfirmedAt,
-		&order.ReadyAt,
-		&order.PickedUpAt,
-		&order.DeliveredAt,		
-	)
+	order, err := scanOrder(s.conn.QueryRow(ctx, query, orderID))
 
 	if err != nil{
 		return nil , err
@@ -201,11 +281,7 @@ func (s * OrderService) GetOrderByID(ctx context.Context, orderID uuid.UUID) (*O
 
 func (s * OrderService) GetOrdersByCustomerID(ctx context.Context, customerID uuid.UUID)([]Order, error){
 	query := `
-		SELECT id, created_at, customer_id, restaurant_id, dasher_id, 
-			order_items, subtotal, delivery_fee, dasher_fee, total, 
-			status, delivery_address, delivery_instructions, 
-			payment_intent_id, updated_at, confirmed_at, ready_at,
-			picked_at, delivered_at 
+		SELECT ` + orderColumns + `
 		FROM orders 
 		WHERE customer_id = $1
 		ORDER BY created_at DESC
@@ -218,28 +294,7 @@ func (s * OrderService) GetOrdersByCustomerID(ctx context.Context, customerID uu
 
 	var orders []Order
 	for rows.Next(){
-		var order Order
-		err := rows.Scan(
-			&order.ID,
-			&order.CreatedAt,
-			&order.CustomerID,
-			&order.RestaurantID,
-			&order.DasherID,
-			&order.OrderItems,
-			&order.Subtotal,
-			&order.DeliveryFee,
-			&order.DasherFee,
-			&order.Total,
-			&order.Status,
-			&order.DeliveryAddress,
-			&order.DeliveryInstructions,
-			&order.PaymentIntentID,
-			&order.UpdatedAt,
-			&order.ConfirmedAt,
-			&order.ReadyAt,
-			&order.PickedUpAt,
-			&order.DeliveredAt,
-		)
+		order, err := scanOrder(rows)
 
 		if err != nil{
 			return nil, err
@@ -255,10 +310,7 @@ func (s * OrderService) GetOrdersByCustomerID(ctx context.Context, customerID uu
 }
 func (s * OrderService) GetOrderByRestaurantID(ctx context.Context, restaurantID uuid.UUID)([]Order, error){
 	query := `
-		SELECT id, created_at, customer_id, restaurant_id, dasher_id, 
-			order_items, subtotal, delivery_fee, dasher_fee, total, 
-			status, delivery_address, delivery_instructions, payment_intent_id,
-			updated_at, confirmed_at, ready_at, picked_at, delivered_at
+		SELECT ` + orderColumns + `
 		FROM orders 
 		WHERE restaurant_id = $1
 		ORDER BY created_at DESC
@@ -273,28 +325,7 @@ func (s * OrderService) GetOrderByRestaurantID(ctx context.Context, restaurantID
 	var orders []Order
 
 	for rows.Next(){
-		var order Order
-		err := rows.Scan(
-			&order.ID,
-			&order.CreatedAt,
-			&order.CustomerID,
-			&order.RestaurantID,
-			&order.DasherID,
-			&order.OrderItems,
-			&order.Subtotal,
-			&order.DeliveryFee,
-			&order.DasherFee,
-			&order.Total,
-			&order.Status,
-			&order.DeliveryAddress,
-			&order.DeliveryInstructions,
-			&order.PaymentIntentID,
-			&order.UpdatedAt,
-			&order.ConfirmedAt,
-			&order.ReadyAt,
-			&order.PickedUpAt,
-			&order.DeliveredAt,
-		)
+		order, err := scanOrder(rows)
 
 		if err != nil{
 			return nil, err
@@ -308,7 +339,36 @@ func (s * OrderService) GetOrderByRestaurantID(ctx context.Context, restaurantID
 	return orders , nil
 }
 
-func (s * OrderService) UpdateOrderStatus(ctx context.Context, orderID uuid.UUID, status OrderStatus) error{
+func (s * OrderService) UpdateOrderStatus(ctx context.Context, orderID uuid.UUID, status OrderStatus, actor Actor, reason string) error{
+	tx, err := s.conn.Begin(ctx)
+	if err != nil{
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	if err := updateStatus(ctx, tx, orderID, status, actor, reason); err != nil{
+		return err
+	}
+
+	return tx.Commit(ctx)
+}
+
+// updateStatus locks the order, checks the transition against the state
+// machine, applies it and records the event, all inside tx.
+func updateStatus(ctx context.Context, tx pgx.Tx, orderID uuid.UUID, status OrderStatus, actor Actor, reason string) error{
+	var currentStatus OrderStatus
+	err := tx.QueryRow(ctx, "SELECT status FROM orders WHERE id = $1 FOR UPDATE", orderID).Scan(&currentStatus)
+	if errors.Is(err, pgx.ErrNoRows){
+		return ErrOrderNotFound
+	}
+	if err != nil{
+		return err
+	}
+
+	if err := ValidateTransition(currentStatus, status, actor.Role); err != nil{
+		return err
+	}
+
 	now := time.Now()
 	
  	timestampColumn := map[OrderStatus]string{
@@ -316,31 +376,31 @@ func (s * OrderService) UpdateOrderStatus(ctx context.Context, orderID uuid.UUID
 		StatusReady: "ready_at", 
 		StatusPickedUp: "picked_at",
 		StatusDelivered: "delivered_at",
+		StatusCancelled: "cancelled_at",
 	}	
 	
+	query := `
+		UPDATE orders 
+		SET status = $1, updated_at = $2
+		WHERE id = $3
+	`
 	if col, ok := timestampColumn[status]; ok{
-		query := fmt.Sprintf(`	
+		query = fmt.Sprintf(`	
 			UPDATE orders 
-			SET status = $1, %s = $2, updated_at = $3
-			WHERE id = $4 
+			SET status = $1, updated_at = $2, %s = $2
+			WHERE id = $3 
 		`, col)	
+	}
 
-		_, err := s.conn.Exec(ctx, query, status, now, now, orderID)
-
-		if err != nil{
-			return err
-		}
+	if _, err := tx.Exec(ctx, query, status, now, orderID); err != nil{
+		return err
 	}
 
-	query := `
-		UPDATE orders 
-		SET status = $1, updated_at = $2
-		WHERE id = $3
-	`
-	_,err := s.conn.Exec(ctx, query, status, now, orderID)
+	if err := recordEvent(ctx, tx, orderID, &currentStatus, status, actor, reason, now); err != nil{
+		return fmt.Errorf("failed to record order event: %v", err)
+	}
 
-	
-	return err
+	return nil
 }
 
 func (s * OrderService) AssignDasher(ctx context.Context, orderID uuid.UUID, dasherID uuid.UUID) error{	
@@ -354,33 +414,33 @@ func (s * OrderService) AssignDasher(ctx context.Context, orderID uuid.UUID, das
 	return err
 }
 
-func calculateSubtotal(items [] OrderItem) float64{
-	var subtotal float64
+func calculateSubtotal(items [] OrderItem) (money.Money, error){
+	subtotal := money.FromCents(0)
 
 	for _,item := range items{
-		if item .Price == 0{
-			item.Price = 1.0	
+		line, err := item.Price.Mul(item.Quantity)
+		if err != nil{
+			return money.Money{}, err
+		}
+		subtotal, err = subtotal.Add(line)
+		if err != nil{
+			return money.Money{}, err
 		}
-		subtotal += item.Price * float64(item.Quantity)
 	}
 
-	return subtotal
+	return subtotal, nil
 }
 
 func (s *OrderService) GetAvailableOrders(ctx context.Context) ([]Order, error){
 	
 	query := `
-		SELECT id, created_at, customer_id, restaurant_id, dasher_id, 
-				order_items, subtotal, delivery_fee, dasher_fee, total, 
-				status, delivery_address, delivery_instructions, 
-				payment_intent_id, updated_at, confirmed_at, ready_at, 
-				picked_at, delivered_at
+		SELECT ` + orderColumns + `
 			FROM orders 
-			WHERE status = $1 AND dasher_id IS NULL
+			WHERE status = ANY($1) AND dasher_id IS NULL
 			ORDER BY created_at ASC
 	`
 
-	rows, err := s.conn.Query(ctx, query, StatusPending)
+	rows, err := s.conn.Query(ctx, query, claimable)
 
 	if err != nil{
 		return nil, err
@@ -388,28 +448,7 @@ func (s *OrderService) GetAvailableOrders(ctx context.Context) ([]Order, error){
 	defer rows.Close()
 	var orders []Order
 	for rows.Next(){
-		var o Order
-		err := rows.Scan(
-			&o.ID,
-			&o.CreatedAt,
-			&o.CustomerID,
-			&o.RestaurantID,
-			&o.DasherID,
-			&o.OrderItems,
-			&o.Subtotal,
-			&o.DeliveryFee,
-			&o.DasherFee,
-			&o.Total,
-			&o.Status,
-			&o.DeliveryAddress,
-			&o.DeliveryInstructions,
-			&o.PaymentIntentID,
-			&o.UpdatedAt,
-			&o.ConfirmedAt,
-			&o.ReadyAt,
-			&o.PickedUpAt,
-			&o.DeliveredAt,
-		)
+		o, err := scanOrder(rows)
 
 		if err != nil{
 			return nil, err
@@ -424,42 +463,66 @@ func (s *OrderService) GetAvailableOrders(ctx context.Context) ([]Order, error){
 	return orders, nil
 }
 
-func(s * OrderService) AcceptOrder(ctx context.Context, orderID, dasherID uuid.UUID) error{
-	var currentStatus OrderStatus
+// AcceptOrder claims a paid order for dasherID without changing its status.
+// The claim is a single conditional UPDATE, so when several dashers accept at
+// once exactly one wins and the rest get ErrOrderAlreadyClaimed.
+func(s * OrderService) AcceptOrder(ctx context.Context, orderID, dasherID uuid.UUID) (*Order, error){
+	tx, err := s.conn.Begin(ctx)
+	if err != nil{
+		return nil, err
+	}
+	defer tx.Rollback(ctx)
 
-	err := s.conn.QueryRow(ctx, "SELECT status FROM orders WHERE id = $1", orderID).Scan(&currentStatus)
-	
+	query := `
+		UPDATE orders 
+		SET dasher_id = $1, updated_at = $2
+		WHERE id = $3 AND status = ANY($4) AND dasher_id IS NULL
+		RETURNING ` + orderColumns
+
+	now := time.Now()
+	order, err := scanOrder(tx.QueryRow(ctx, query, dasherID, now, orderID, claimable))
+	if errors.Is(err, pgx.ErrNoRows){
+		return nil, s.claimFailure(ctx, orderID)
+	}
 	if err != nil{
-		return fmt.Errorf("order not found: %w", err)
+		return nil, fmt.Errorf("failed to accept order:%v", err)
 	}
 
-	if currentStatus != StatusPending{
-		return fmt.Errorf("order is not avaialable for pickup")	
+	// the status doesn't change, but the claim still belongs on the timeline
+	dasher := Actor{ID: &dasherID, Role: RoleDasher}
+	if err := recordEvent(ctx, tx, orderID, &order.Status, order.Status, dasher, "accepted by dasher", now); err != nil{
+		return nil, fmt.Errorf("failed to record order event: %v", err)
 	}
 
-	query := `
-		UPDATE orders 
-		SET dasher_id = $1, status = $2, updated_at = $3
-		WHERE id = $4
-	`
+	if err := tx.Commit(ctx); err != nil{
+		return nil, err
+	}
+	return &order, nil
+}
 
-	_, err = s.conn.Exec(ctx, query, dasherID, StatusConfirmed, time.Now(), orderID)
-	
+// claimFailure explains why AcceptOrder's conditional update matched no rows.
+func (s * OrderService) claimFailure(ctx context.Context, orderID uuid.UUID) error{
+	var currentStatus OrderStatus
+	var dasherID *uuid.UUID
+
+	err := s.conn.QueryRow(ctx, "SELECT status, dasher_id FROM orders WHERE id = $1", orderID).Scan(&currentStatus, &dasherID)
+	if errors.Is(err, pgx.ErrNoRows){
+		return ErrOrderNotFound
+	}
 	if err != nil{
-		return fmt.Errorf("failed to accept order:%v", err)
+		return err
 	}
 
-	return nil
+	if dasherID != nil{
+		return ErrOrderAlreadyClaimed
+	}
+	return fmt.Errorf("%w: order is %s", ErrOrderNotClaimable, currentStatus)
 }
 
 func (s * OrderService) CheckUserHistory(ctx context.Context,customerID uuid.UUID) ([]Order, error){
 		
 	query := `
-		SELECT id, created_at, customer_id, restaurant_id, dasher_id, 
-			order_items, subtotal, delivery_fee, dasher_fee, total, 
-			status, delivery_address, delivery_instructions, 
-			payment_intent_id, updated_at, confirmed_at, ready_at, 
-			picked_at, delivered_at
+		SELECT ` + orderColumns + `
 		FROM orders 
 		WHERE customer_id = $1
 		ORDER BY created_at DESC
@@ -476,28 +539,7 @@ func (s * OrderService) CheckUserHistory(ctx context.Context,customerID uuid.UUI
 
 	var orders []Order
 	for rows.Next(){
-		var o Order
-		err := rows.Scan(
-			&o.ID,
-			&o.CreatedAt,
-			&o.CustomerID,
-			&o.RestaurantID,
-			&o.DasherID,
-			&o.OrderItems,
-			&o.Subtotal,
-			&o.DeliveryFee,
-			&o.DasherFee,
-			&o.Total,
-			&o.Status,
-			&o.DeliveryAddress,
-			&o.DeliveryInstructions,
-			&o.PaymentIntentID,
-			&o.UpdatedAt,
-			&o.ConfirmedAt,
-			&o.ReadyAt,
-			&o.PickedUpAt,
-			&o.DeliveredAt,
-		)
+		o, err := scanOrder(rows)
 
 		if err != nil{
 			return nil, err
@@ -515,11 +557,7 @@ func (s * OrderService) CheckUserHistory(ctx context.Context,customerID uuid.UUI
 
 func (s * OrderService) GetOrdersByDasherID(ctx context.Context, dasherID uuid.UUID) ([]Order, error){
 	query := `
-		SELECT id, created_at, customer_id, restaurant_id, dasher_id, 
-			order_items, subtotal, delivery_fee, dasher_fee, total, 
-			status, delivery_address, delivery_instructions, 
-			payment_intent_id, updated_at, confirmed_at, ready_at, 
-			picked_at, delivered_at
+		SELECT ` + orderColumns + `
 		FROM orders 
 		WHERE dasher_id = $1
 		ORDER BY created_at DESC
@@ -535,28 +573,7 @@ func (s * OrderService) GetOrdersByDasherID(ctx context.Context, dasherID uuid.U
 
 	var orders []Order
 	for rows.Next(){
-		var o Order
-		err := rows.Scan(
-			&o.ID,
-			&o.CreatedAt,
-			&o.CustomerID,
-			&o.RestaurantID,
-			&o.DasherID,
-			&o.OrderItems,
-			&o.Subtotal,
-			&o.DeliveryFee,
-			&o.DasherFee,
-			&o.Total,
-			&o.Status,
-			&o.DeliveryAddress,
-			&o.DeliveryInstructions,
-			&o.PaymentIntentID,
-			&o.UpdatedAt,
-			&o.ConfirmedAt,
-			&o.ReadyAt,
-			&o.PickedUpAt,
-			&o.DeliveredAt,
-		)
+		o, err := scanOrder(rows)
 
 		if err != nil{
 			return nil, err
@@ -573,33 +590,45 @@ func (s * OrderService) GetOrdersByDasherID(ctx context.Context, dasherID uuid.U
 }
 
 func (s * OrderService) CompleteOrder(ctx context.Context, orderID ,dasherID uuid.UUID) (bool, error){
+	tx, err := s.conn.Begin(ctx)
+	if err != nil{
+		return false, err
+	}
+	defer tx.Rollback(ctx)
 
 	var currentStatus OrderStatus
 
-	err := s.conn.QueryRow(ctx, "SELECT status FROM orders WHERE id = $1 AND dasher_id = $2", orderID, dasherID).Scan(&currentStatus)
+	err = tx.QueryRow(ctx, "SELECT status FROM orders WHERE id = $1 AND dasher_id = $2 FOR UPDATE", orderID, dasherID).Scan(&currentStatus)
 
 	
 	if err != nil{
 		return false,fmt.Errorf("order not found or incorrect dasher: %w", err)
 	}
 
-	if currentStatus != StatusConfirmed{
-		return false, fmt.Errorf("order has not been confirmed to be delivered")
+	if err := ValidateTransition(currentStatus, StatusDelivered, RoleDasher); err != nil{
+		return false, err
 	}
 
-	//at this point update order status and delete order from db
-	
+	now := time.Now()
 	query := `
 		UPDATE orders
-		SET status = $1, updated_at = $2, delivered_at = $3
-		WHERE id = $4 AND dasher_id = $5
+		SET status = $1, updated_at = $2, delivered_at = $2
+		WHERE id = $3 AND dasher_id = $4
 	`
-	_, err = s.conn.Exec(ctx, query, StatusDelivered, time.Now(), time.Now(), orderID, dasherID)
+	_, err = tx.Exec(ctx, query, StatusDelivered, now, orderID, dasherID)
 	
 	if err != nil{
 		return false,fmt.Errorf("failed to update and complete order:%v", err)
 	}
 
+	dasher := Actor{ID: &dasherID, Role: RoleDasher}
+	if err := recordEvent(ctx, tx, orderID, &currentStatus, StatusDelivered, dasher, "delivered", now); err != nil{
+		return false, fmt.Errorf("failed to record order event: %v", err)
+	}
+
+	if err := tx.Commit(ctx); err != nil{
+		return false, err
+	}
+
 	return true,nil		
 }
-
@@ -0,0 +1,171 @@
+package orders
+
+import (
+	"campusDoordash/internal/money"
+	"campusDoordash/internal/restaurants"
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/google/uuid"
+)
+
+// ErrInvalidOrderItem is wrapped by every pricing error caused by the
+// request itself rather than by the database.
+var ErrInvalidOrderItem = errors.New("invalid order item")
+
+// maxItemQuantity is the most of one item an order can hold. It keeps line
+// totals far from overflowing, and no dasher can carry more anyway.
+const maxItemQuantity = 50
+
+// PriceDrift describes an item whose price the client sent differs from the
+// price the order was charged at.
+type PriceDrift struct {
+	FoodID      uuid.UUID   `json:"food_id"`
+	ClientPrice money.Money `json:"client_price"`
+	Price       money.Money `json:"price"`
+}
+
+// priceItems looks every requested item and modifier up and returns the
+// lines with server-side names and prices. Client supplied prices are ignored.
+func (s *OrderService) priceItems(ctx context.Context, restaurantID uuid.UUID, items []OrderItem) ([]OrderItem, error) {
+	foodIDs := make([]uuid.UUID, 0, len(items))
+	for _, item := range items {
+		foodIDs = append(foodIDs, item.FoodID)
+	}
+
+	foods, err := s.restaurants.GetFoodItemBYIDs(ctx, foodIDs)
+	if err != nil {
+		return nil, fmt.Errorf("failed to look up food items: %v", err)
+	}
+
+	groups, err := s.restaurants.GetModifierGroups(ctx, foodIDs)
+	if err != nil {
+		return nil, fmt.Errorf("failed to look up modifiers: %v", err)
+	}
+
+	byID := make(map[uuid.UUID]int, len(foods))
+	for i, food := range foods {
+		byID[food.FoodID] = i
+	}
+
+	priced := make([]OrderItem, 0, len(items))
+	for _, item := range items {
+		if err := checkQuantity(item); err != nil {
+			return nil, err
+		}
+
+		i, ok := byID[item.FoodID]
+		if !ok {
+			return nil, fmt.Errorf("%w: food %s does not exist", ErrInvalidOrderItem, item.FoodID)
+		}
+		food := foods[i]
+
+		if food.RestaurantID == nil || *food.RestaurantID != restaurantID {
+			return nil, fmt.Errorf("%w: %s is not sold by this restaurant", ErrInvalidOrderItem, food.FoodName)
+		}
+		if !food.Availability {
+			return nil, fmt.Errorf("%w: %s is unavailable", ErrInvalidOrderItem, food.FoodName)
+		}
+		if food.Price == nil {
+			return nil, fmt.Errorf("%w: %s has no price", ErrInvalidOrderItem, food.FoodName)
+		}
+
+		modifiers, err := priceModifiers(food.FoodName, groups[food.FoodID], item.Modifiers)
+		if err != nil {
+			return nil, err
+		}
+		price := *food.Price
+		for _, m := range modifiers {
+			if price, err = price.Add(m.PriceDelta); err != nil {
+				return nil, fmt.Errorf("%w: %s: %v", ErrInvalidOrderItem, food.FoodName, err)
+			}
+		}
+		if price.IsNegative() {
+			return nil, fmt.Errorf("%w: %s costs less than nothing with these options", ErrInvalidOrderItem, food.FoodName)
+		}
+
+		priced = append(priced, OrderItem{
+			FoodID:    food.FoodID,
+			Quantity:  item.Quantity,
+			Price:     price,
+			FoodName:  food.FoodName,
+			Modifiers: modifiers,
+		})
+	}
+
+	return priced, nil
+}
+
+// checkQuantity rejects quantities below 1 or above maxItemQuantity.
+func checkQuantity(item OrderItem) error {
+	if item.Quantity <= 0 {
+		return fmt.Errorf("%w: quantity for %s must be at least 1", ErrInvalidOrderItem, item.FoodID)
+	}
+	if item.Quantity > maxItemQuantity {
+		return fmt.Errorf("%w: quantity for %s can't be more than %d", ErrInvalidOrderItem, item.FoodID, maxItemQuantity)
+	}
+	return nil
+}
+
+// priceModifiers checks the options chosen for one item against its modifier
+// groups and returns them with server-side names and price deltas, in the
+// groups' display order.
+func priceModifiers(foodName string, groups []restaurants.ModifierGroup, chosen []OrderModifier) ([]OrderModifier, error) {
+	selected := make(map[uuid.UUID]bool, len(chosen))
+	for _, m := range chosen {
+		if selected[m.ModifierOptionID] {
+			return nil, fmt.Errorf("%w: option %s was chosen twice for %s", ErrInvalidOrderItem, m.ModifierOptionID, foodName)
+		}
+		selected[m.ModifierOptionID] = true
+	}
+
+	var priced []OrderModifier
+	for _, group := range groups {
+		count := 0
+		for _, option := range group.Options {
+			if !selected[option.ModifierOptionID] {
+				continue
+			}
+			if !option.Available {
+				return nil, fmt.Errorf("%w: %s is unavailable for %s", ErrInvalidOrderItem, option.Name, foodName)
+			}
+			delete(selected, option.ModifierOptionID)
+			count++
+			priced = append(priced, OrderModifier{
+				ModifierOptionID: option.ModifierOptionID,
+				GroupName:        group.Name,
+				Name:             option.Name,
+				PriceDelta:       option.PriceDelta,
+			})
+		}
+
+		if count < group.MinSelections {
+			return nil, fmt.Errorf("%w: choose at least %d %s for %s", ErrInvalidOrderItem, group.MinSelections, group.Name, foodName)
+		}
+		if count > group.MaxSelections {
+			return nil, fmt.Errorf("%w: choose at most %d %s for %s", ErrInvalidOrderItem, group.MaxSelections, group.Name, foodName)
+		}
+	}
+
+	for optionID := range selected {
+		return nil, fmt.Errorf("%w: %s is not an option for %s", ErrInvalidOrderItem, optionID, foodName)
+	}
+	return priced, nil
+}
+
+// priceDrift lists the requested items whose client price differs from the
+// price charged. Items sent without a price are skipped. requested and priced are in the same order.
+func priceDrift(requested, priced []OrderItem) []PriceDrift {
+	drift := []PriceDrift{}
+	for i, item := range priced {
+		if !requested[i].Price.IsZero() && requested[i].Price.Cents != item.Price.Cents {
+			drift = append(drift, PriceDrift{
+				FoodID:      item.FoodID,
+				ClientPrice: requested[i].Price,
+				Price:       item.Price,
+			})
+		}
+	}
+	return drift
+}
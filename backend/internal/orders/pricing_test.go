@@ -0,0 +1,50 @@
+package orders
+
+import (
+	"campusDoordash/internal/money"
+	"errors"
+	"math"
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+func TestCheckQuantity(t *testing.T) {
+	tests := []struct {
+		quantity int
+		ok       bool
+	}{
+		{1, true},
+		{maxItemQuantity, true},
+		{0, false},
+		{-1, false},
+		{maxItemQuantity + 1, false},
+		// enough to wrap the line total around to a few cents
+		{math.MaxInt64/749 + 2, false},
+	}
+	for _, tt := range tests {
+		err := checkQuantity(OrderItem{FoodID: uuid.New(), Quantity: tt.quantity})
+		if tt.ok && err != nil {
+			t.Errorf("quantity %d: %v", tt.quantity, err)
+		}
+		if !tt.ok && !errors.Is(err, ErrInvalidOrderItem) {
+			t.Errorf("quantity %d error = %v, want ErrInvalidOrderItem", tt.quantity, err)
+		}
+	}
+}
+
+func TestCalculateSubtotal(t *testing.T) {
+	items := []OrderItem{
+		{Price: money.FromCents(749), Quantity: 2},
+		{Price: money.FromCents(299), Quantity: 1},
+	}
+	if got, err := calculateSubtotal(items); err != nil || got.Cents != 1797 {
+		t.Errorf("subtotal = %d, %v, want 1797", got.Cents, err)
+	}
+
+	// a line total that would wrap is an error, not a cheap order
+	items = append(items, OrderItem{Price: money.FromCents(749), Quantity: math.MaxInt64/749 + 2})
+	if got, err := calculateSubtotal(items); !errors.Is(err, money.ErrOutOfRange) {
+		t.Errorf("overflowing subtotal = %d, %v, want ErrOutOfRange", got.Cents, err)
+	}
+}
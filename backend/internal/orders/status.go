@@ -0,0 +1,100 @@
+package orders
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Role identifies who is asking for an order to change status.
+type Role string
+
+const (
+	RoleCustomer   Role = "customer"
+	RoleDasher     Role = "dasher"
+	RoleRestaurant Role = "restaurant"
+	RoleWebhook    Role = "webhook"
+	RoleAdmin      Role = "admin"
+)
+
+// ErrInvalidTransition is returned (wrapped in a *TransitionError) whenever a
+// status change is not allowed from the order's current status or by the caller's role.
+var ErrInvalidTransition = errors.New("invalid order status transition")
+
+type TransitionError struct {
+	From OrderStatus
+	To   OrderStatus
+	Role Role
+}
+
+func (e *TransitionError) Error() string {
+	return fmt.Sprintf("%s cannot move order from %s to %s", e.Role, e.From, e.To)
+}
+
+func (e *TransitionError) Unwrap() error {
+	return ErrInvalidTransition
+}
+
+// transitions lists every allowed status change and the roles that may make it.
+// Anything not in this table is rejected. Only the payment webhook confirms an
+// order; dashers claim confirmed orders separately (see claimable) without
+// changing their status. Customers can cancel until a dasher picks the order
+// up; restaurants and admins can cancel until it is delivered.
+var transitions = map[OrderStatus]map[OrderStatus][]Role{
+	StatusPending: {
+		StatusConfirmed: {RoleWebhook},
+		StatusCancelled: {RoleCustomer, RoleRestaurant, RoleAdmin, RoleWebhook},
+	},
+	StatusConfirmed: {
+		StatusPreparing: {RoleRestaurant},
+		StatusPickedUp:  {RoleDasher},
+		// dashers can still complete straight from confirmed while restaurants
+		// don't report preparing/ready through the app
+		StatusDelivered: {RoleDasher},
+		StatusCancelled: {RoleCustomer, RoleRestaurant, RoleAdmin},
+	},
+	StatusPreparing: {
+		StatusReady:     {RoleRestaurant},
+		StatusCancelled: {RoleCustomer, RoleRestaurant, RoleAdmin},
+	},
+	StatusReady: {
+		StatusPickedUp:  {RoleDasher},
+		StatusDelivered: {RoleDasher},
+		StatusCancelled: {RoleCustomer, RoleRestaurant, RoleAdmin},
+	},
+	StatusPickedUp: {
+		StatusDelivered: {RoleDasher},
+		StatusCancelled: {RoleRestaurant, RoleAdmin},
+	},
+}
+
+// claimable lists the statuses in which an order without a dasher can be
+// claimed: it has been paid for and nobody has picked it up yet.
+var claimable = []OrderStatus{StatusConfirmed, StatusPreparing, StatusReady}
+
+// IsValid reports whether s is one of the known order statuses.
+func (s OrderStatus) IsValid() bool {
+	switch s {
+	case StatusPending, StatusConfirmed, StatusPreparing, StatusReady,
+		StatusPickedUp, StatusDelivered, StatusCancelled:
+		return true
+	}
+	return false
+}
+
+// CanTransition reports whether role may move an order from one status to another.
+func CanTransition(from, to OrderStatus, role Role) bool {
+	for _, r := range transitions[from][to] {
+		if r == role {
+			return true
+		}
+	}
+	return false
+}
+
+// ValidateTransition returns a *TransitionError if the change is not allowed.
+func ValidateTransition(from, to OrderStatus, role Role) error {
+	if !CanTransition(from, to, role) {
+		return &TransitionError{From: from, To: to, Role: role}
+	}
+	return nil
+}
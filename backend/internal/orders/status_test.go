@@ -0,0 +1,92 @@
+package orders
+
+import (
+	"errors"
+	"testing"
+)
+
+var (
+	allStatuses = []OrderStatus{StatusPending, StatusConfirmed, StatusPreparing, StatusReady,
+		StatusPickedUp, StatusDelivered, StatusCancelled}
+	allRoles = []Role{RoleCustomer, RoleDasher, RoleRestaurant, RoleWebhook, RoleAdmin}
+)
+
+type transition struct {
+	from, to OrderStatus
+	role     Role
+}
+
+// allowed is every status change the app permits, written out by hand so a
+// change to the transitions table has to be made here too.
+var allowed = map[transition]bool{
+	{StatusPending, StatusConfirmed, RoleWebhook}:    true,
+	{StatusPending, StatusCancelled, RoleCustomer}:   true,
+	{StatusPending, StatusCancelled, RoleRestaurant}: true,
+	{StatusPending, StatusCancelled, RoleAdmin}:      true,
+	{StatusPending, StatusCancelled, RoleWebhook}:    true,
+
+	{StatusConfirmed, StatusPreparing, RoleRestaurant}: true,
+	{StatusConfirmed, StatusPickedUp, RoleDasher}:      true,
+	{StatusConfirmed, StatusDelivered, RoleDasher}:     true,
+	{StatusConfirmed, StatusCancelled, RoleCustomer}:   true,
+	{StatusConfirmed, StatusCancelled, RoleRestaurant}: true,
+	{StatusConfirmed, StatusCancelled, RoleAdmin}:      true,
+
+	{StatusPreparing, StatusReady, RoleRestaurant}:     true,
+	{StatusPreparing, StatusCancelled, RoleCustomer}:   true,
+	{StatusPreparing, StatusCancelled, RoleRestaurant}: true,
+	{StatusPreparing, StatusCancelled, RoleAdmin}:      true,
+
+	{StatusReady, StatusPickedUp, RoleDasher}:      true,
+	{StatusReady, StatusDelivered, RoleDasher}:     true,
+	{StatusReady, StatusCancelled, RoleCustomer}:   true,
+	{StatusReady, StatusCancelled, RoleRestaurant}: true,
+	{StatusReady, StatusCancelled, RoleAdmin}:      true,
+
+	{StatusPickedUp, StatusDelivered, RoleDasher}:     true,
+	{StatusPickedUp, StatusCancelled, RoleRestaurant}: true,
+	{StatusPickedUp, StatusCancelled, RoleAdmin}:      true,
+}
+
+func TestTransitions(t *testing.T) {
+	for _, from := range allStatuses {
+		for _, to := range allStatuses {
+			for _, role := range allRoles {
+				want := allowed[transition{from, to, role}]
+				if got := CanTransition(from, to, role); got != want {
+					t.Errorf("CanTransition(%s, %s, %s) = %t, want %t", from, to, role, got, want)
+				}
+
+				err := ValidateTransition(from, to, role)
+				if want && err != nil {
+					t.Errorf("ValidateTransition(%s, %s, %s) = %v", from, to, role, err)
+				}
+				var te *TransitionError
+				if !want && (!errors.Is(err, ErrInvalidTransition) || !errors.As(err, &te) || te.From != from || te.To != to || te.Role != role) {
+					t.Errorf("ValidateTransition(%s, %s, %s) = %v, want a TransitionError", from, to, role, err)
+				}
+			}
+		}
+	}
+}
+
+func TestTerminalStatusesAreFinal(t *testing.T) {
+	for _, from := range []OrderStatus{StatusDelivered, StatusCancelled} {
+		if len(transitions[from]) != 0 {
+			t.Errorf("%s has transitions %v", from, transitions[from])
+		}
+	}
+}
+
+func TestIsValid(t *testing.T) {
+	for _, s := range allStatuses {
+		if !s.IsValid() {
+			t.Errorf("%s is not valid", s)
+		}
+	}
+	for _, s := range []OrderStatus{"", "refunded", "Delivered"} {
+		if s.IsValid() {
+			t.Errorf("%q is valid", s)
+		}
+	}
+}
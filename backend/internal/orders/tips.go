@@ -0,0 +1,236 @@
+package orders
+
+import (
+	"campusDoordash/internal/money"
+	"campusDoordash/internal/payments"
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+)
+
+var (
+	ErrInvalidTip      = errors.New("tip must be a positive amount higher than the current tip")
+	ErrTipNotAllowed   = errors.New("tips can only be added once the order is delivered")
+	ErrTipWindowClosed = errors.New("the window for adding a tip to this order has closed")
+)
+
+// Statuses of an order_tip_charges row.
+const (
+	TipPending   = "pending"
+	TipSucceeded = "succeeded"
+	TipFailed    = "failed"
+	TipCanceled  = "canceled"
+)
+
+// TipCharge is an extra tip added after delivery, paid through its own PaymentIntent.
+type TipCharge struct {
+	ID              uuid.UUID   `json:"id"`
+	OrderID         uuid.UUID   `json:"order_id"`
+	Amount          money.Money `json:"amount"`
+	PaymentIntentID string      `json:"payment_intent_id"`
+	Status          string      `json:"status"`
+	CreatedAt       time.Time   `json:"created_at"`
+}
+
+// DasherEarnings totals what a dasher has made from delivered orders.
+type DasherEarnings struct {
+	Deliveries int         `json:"deliveries"`
+	BaseFees   money.Money `json:"base_fees"`
+	Tips       money.Money `json:"tips"`
+	Total      money.Money `json:"total"`
+}
+
+// AddTip raises the tip on a delivered order to tip. The difference from the
+// tip already paid is charged as a separate PaymentIntent, and is only added
+// to the order once the webhook reports it paid. Tip charges that were never
+// paid are cancelled and replaced by the new one, so an abandoned attempt
+// doesn't count towards the tip. A tip can only go up: lowering it would mean
+// taking back money the dasher has been paid, so a tip at or below the paid
+// one is ErrInvalidTip. Retrying a request that failed gets the same
+// PaymentIntent back from Stripe rather than leaving another one behind.
+func (s *OrderService) AddTip(ctx context.Context, orderID, customerID uuid.UUID, tip money.Money) (*TipCharge, string, error) {
+	tx, err := s.conn.Begin(ctx)
+	if err != nil {
+		return nil, "", err
+	}
+	defer tx.Rollback(ctx)
+
+	// the lock makes concurrent tip requests for the order take turns
+	var status OrderStatus
+	var deliveredAt *time.Time
+	var paidTip money.Money
+	err = tx.QueryRow(ctx, `
+		SELECT status, delivered_at, tip
+		FROM orders
+		WHERE id = $1 AND customer_id = $2
+		FOR UPDATE
+	`, orderID, customerID).Scan(&status, &deliveredAt, &paidTip)
+
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, "", ErrOrderNotFound
+	}
+	if err != nil {
+		return nil, "", err
+	}
+
+	if status != StatusDelivered || deliveredAt == nil {
+		return nil, "", ErrTipNotAllowed
+	}
+	if time.Since(*deliveredAt) > s.pricing.Rules.TipWindow {
+		return nil, "", ErrTipWindowClosed
+	}
+
+	extra, err := tip.Sub(paidTip)
+	if err != nil || extra.IsNegative() || extra.IsZero() {
+		return nil, "", ErrInvalidTip
+	}
+
+	if err := s.supersedeTipCharges(ctx, tx, orderID); err != nil {
+		return nil, "", err
+	}
+
+	// only committed charges count, so a retry after a failure below
+	// reuses the key
+	var attempts int
+	err = tx.QueryRow(ctx, "SELECT COUNT(*) FROM order_tip_charges WHERE order_id = $1", orderID).Scan(&attempts)
+	if err != nil {
+		return nil, "", err
+	}
+
+	intent, err := payments.CreateTipPaymentIntent(orderID.String(), extra, tipIdempotencyKey(orderID, tip, attempts))
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to create tip payment intent %v", err)
+	}
+
+	charge := TipCharge{
+		ID:              uuid.New(),
+		OrderID:         orderID,
+		Amount:          extra,
+		PaymentIntentID: intent.ID,
+		Status:          TipPending,
+		CreatedAt:       time.Now(),
+	}
+
+	_, err = tx.Exec(ctx, `
+		INSERT INTO order_tip_charges (id, order_id, amount, payment_intent_id, status, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6)
+	`, charge.ID, charge.OrderID, charge.Amount, charge.PaymentIntentID, charge.Status, charge.CreatedAt)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to record tip charge: %v", err)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return nil, "", err
+	}
+	return &charge, intent.ClientSecret, nil
+}
+
+// tipIdempotencyKey identifies the PaymentIntent for raising orderID's tip
+// to tip after attempts earlier tip charges. Counting the attempts gives a
+// new key when the customer returns to a tip they superseded before, whose
+// PaymentIntent has been cancelled.
+func tipIdempotencyKey(orderID uuid.UUID, tip money.Money, attempts int) string {
+	return fmt.Sprintf("tip-%s-%s-%d", orderID, tip, attempts)
+}
+
+// supersedeTipCharges cancels the order's unpaid tip PaymentIntents. A charge
+// Stripe is still processing, or has taken but not yet reported, can't be
+// cancelled and makes the new tip wait with payments.ErrPaymentProcessing.
+// Each cancellation is recorded outside tx as soon as Stripe accepts it, so
+// the rows match Stripe even if the new tip is rolled back.
+func (s *OrderService) supersedeTipCharges(ctx context.Context, tx pgx.Tx, orderID uuid.UUID) error {
+	rows, err := tx.Query(ctx, `
+		SELECT payment_intent_id FROM order_tip_charges
+		WHERE order_id = $1 AND status = $2
+	`, orderID, TipPending)
+	if err != nil {
+		return err
+	}
+	intentIDs, err := pgx.CollectRows(rows, pgx.RowTo[string])
+	if err != nil {
+		return err
+	}
+
+	for _, id := range intentIDs {
+		if err := payments.CancelPaymentIntent(id, "supersede-"+id); err != nil {
+			return err
+		}
+		_, err := s.conn.Exec(ctx, `
+			UPDATE order_tip_charges SET status = $1
+			WHERE payment_intent_id = $2 AND status = $3
+		`, TipCanceled, id, TipPending)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ConfirmTip adds a paid tip charge to its order. Charges that were already
+// applied are ignored, so repeated webhook deliveries are harmless. A failed
+// charge can still succeed if the customer retries the same PaymentIntent.
+func (s *OrderService) ConfirmTip(ctx context.Context, paymentIntentID string) error {
+	tx, err := s.conn.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	var orderID uuid.UUID
+	var amount money.Money
+	err = tx.QueryRow(ctx, `
+		UPDATE order_tip_charges
+		SET status = $2, paid_at = NOW()
+		WHERE payment_intent_id = $1 AND status IN ($3, $4)
+		RETURNING order_id, amount
+	`, paymentIntentID, TipSucceeded, TipPending, TipFailed).Scan(&orderID, &amount)
+
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(ctx, `
+		UPDATE orders
+		SET tip = tip + $1, total = total + $1, updated_at = NOW()
+		WHERE id = $2
+	`, amount, orderID)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit(ctx)
+}
+
+// FailTip marks a tip charge whose payment failed or was cancelled, so it
+// no longer shows as pending.
+func (s *OrderService) FailTip(ctx context.Context, paymentIntentID string) error {
+	_, err := s.conn.Exec(ctx, `
+		UPDATE order_tip_charges SET status = $1
+		WHERE payment_intent_id = $2 AND status = $3
+	`, TipFailed, paymentIntentID, TipPending)
+	return err
+}
+
+func (s *OrderService) GetDasherEarnings(ctx context.Context, dasherID uuid.UUID) (*DasherEarnings, error) {
+	var e DasherEarnings
+	err := s.conn.QueryRow(ctx, `
+		SELECT COUNT(*), COALESCE(SUM(dasher_fee), 0), COALESCE(SUM(tip), 0)
+		FROM orders
+		WHERE dasher_id = $1 AND status = $2
+	`, dasherID, StatusDelivered).Scan(&e.Deliveries, &e.BaseFees, &e.Tips)
+	if err != nil {
+		return nil, err
+	}
+
+	if e.Total, err = e.BaseFees.Add(e.Tips); err != nil {
+		return nil, err
+	}
+	return &e, nil
+}
@@ -0,0 +1,28 @@
+package orders
+
+import (
+	"campusDoordash/internal/money"
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+func TestTipIdempotencyKey(t *testing.T) {
+	orderID := uuid.New()
+	key := tipIdempotencyKey(orderID, money.FromCents(500), 1)
+
+	if again := tipIdempotencyKey(orderID, money.FromCents(500), 1); again != key {
+		t.Errorf("a retry got key %q, want %q", again, key)
+	}
+	others := []string{
+		tipIdempotencyKey(orderID, money.FromCents(700), 1),
+		// back to a tip whose PaymentIntent was cancelled when superseded
+		tipIdempotencyKey(orderID, money.FromCents(500), 2),
+		tipIdempotencyKey(uuid.New(), money.FromCents(500), 1),
+	}
+	for _, other := range others {
+		if other == key {
+			t.Errorf("different tips share key %q", key)
+		}
+	}
+}
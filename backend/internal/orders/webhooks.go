@@ -0,0 +1,89 @@
+package orders
+
+import (
+	"campusDoordash/internal/payments"
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+)
+
+// ConfirmPayment records a successful payment and moves the order from
+// pending to confirmed on behalf of the Stripe webhook. eventID is kept as
+// the reason on the order's timeline. An order that has already moved past
+// pending keeps its status.
+func (s *OrderService) ConfirmPayment(ctx context.Context, paymentIntentID string, event payments.EventInfo) error {
+	to := StatusConfirmed
+	return s.applyPayment(ctx, paymentIntentID, payments.PaymentSucceeded, event, &to, "payment succeeded: "+event.ID)
+}
+
+// CancelUnpaidOrder records that the order's PaymentIntent was cancelled and
+// cancels the order if it was still waiting on payment.
+func (s *OrderService) CancelUnpaidOrder(ctx context.Context, paymentIntentID string, event payments.EventInfo) error {
+	to := StatusCancelled
+	return s.applyPayment(ctx, paymentIntentID, payments.PaymentCanceled, event, &to, "payment canceled: "+event.ID)
+}
+
+// SetPaymentStatus records a payment state that doesn't change the order status.
+func (s *OrderService) SetPaymentStatus(ctx context.Context, paymentIntentID string, status payments.PaymentStatus, event payments.EventInfo) error {
+	return s.applyPayment(ctx, paymentIntentID, status, event, nil, "")
+}
+
+// applyPayment sets the payment status of the order behind paymentIntentID
+// and, when to is given, moves the order there in the same transaction.
+// Events older than the one that set the current payment status are skipped.
+// Transitions the state machine rejects are logged and skipped because the
+// order has legitimately moved on; the payment status is still saved.
+func (s *OrderService) applyPayment(ctx context.Context, paymentIntentID string, status payments.PaymentStatus, event payments.EventInfo, to *OrderStatus, reason string) error {
+	tx, err := s.conn.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	var orderID uuid.UUID
+	var current payments.PaymentStatus
+	var currentAt *time.Time
+	err = tx.QueryRow(ctx, `
+		SELECT id, payment_status, payment_event_at
+		FROM orders
+		WHERE payment_intent_id = $1
+		FOR UPDATE
+	`, paymentIntentID).Scan(&orderID, &current, &currentAt)
+
+	if errors.Is(err, pgx.ErrNoRows) {
+		return fmt.Errorf("%w %s", payments.ErrUnknownPaymentIntent, paymentIntentID)
+	}
+	if err != nil {
+		return err
+	}
+
+	if !status.Supersedes(current, currentAt, event.Created) {
+		log.Printf("webhook: ignoring stale %s event %s for order %s", status, event.ID, orderID)
+		return nil
+	}
+
+	_, err = tx.Exec(ctx, `
+		UPDATE orders
+		SET payment_status = $1, payment_event_at = $2, updated_at = NOW()
+		WHERE id = $3
+	`, status, event.Created, orderID)
+	if err != nil {
+		return err
+	}
+
+	if to != nil {
+		err = updateStatus(ctx, tx, orderID, *to, Actor{Role: RoleWebhook}, reason)
+		if errors.Is(err, ErrInvalidTransition) {
+			log.Printf("webhook: leaving order %s as is: %v", orderID, err)
+		} else if err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit(ctx)
+}
@@ -0,0 +1,177 @@
+package payments
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/stripe/stripe-go/v82"
+)
+
+type EventStatus string
+
+const (
+	EventProcessing EventStatus = "processing"
+	EventProcessed  EventStatus = "processed"
+	EventFailed     EventStatus = "failed"
+)
+
+// claimTimeout is how long an event can sit in processing before another
+// delivery may take it over, e.g. after the server crashed mid-event.
+const claimTimeout = 5 * time.Minute
+
+// EventInfo identifies the Stripe event an order update comes from.
+type EventInfo struct {
+	ID      string
+	Created time.Time
+}
+
+// WebhookEvent is a row of stripe_events.
+type WebhookEvent struct {
+	ID              string      `json:"id"`
+	Type            string      `json:"type"`
+	ObjectID        *string     `json:"object_id,omitempty"`
+	StripeCreatedAt time.Time   `json:"stripe_created_at"`
+	Status          EventStatus `json:"status"`
+	Error           *string     `json:"error,omitempty"`
+	Attempts        int         `json:"attempts"`
+	ReceivedAt      time.Time   `json:"received_at"`
+	ClaimedAt       time.Time   `json:"claimed_at"`
+	ProcessedAt     *time.Time  `json:"processed_at,omitempty"`
+}
+
+// claimEvent records a delivery of event and reports whether this delivery
+// should process it. Events already processed, or being processed by another
+// delivery right now, are not claimed.
+func (s *PaymentService) claimEvent(ctx context.Context, event stripe.Event) (bool, error) {
+	var objectID *string
+	if event.Data != nil {
+		if id, ok := event.Data.Object["id"].(string); ok {
+			objectID = &id
+		}
+	}
+
+	token := uuid.New()
+	var claimed bool
+	err := s.Conn.QueryRow(ctx, `
+		INSERT INTO stripe_events (id, type, object_id, stripe_created_at, status, claim_token)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		ON CONFLICT (id) DO UPDATE
+		SET attempts = stripe_events.attempts + 1,
+			status      = CASE WHEN `+claimable+` THEN $5 ELSE stripe_events.status END,
+			claim_token = CASE WHEN `+claimable+` THEN $6 ELSE stripe_events.claim_token END,
+			claimed_at  = CASE WHEN `+claimable+` THEN NOW() ELSE stripe_events.claimed_at END
+		RETURNING claim_token = $6
+	`, event.ID, event.Type, objectID, time.Unix(event.Created, 0), EventProcessing, token,
+		EventFailed, time.Now().Add(-claimTimeout)).Scan(&claimed)
+	if err != nil {
+		return false, err
+	}
+
+	return claimed, nil
+}
+
+// claimable is true for a stored event that failed before, or whose previous
+// claim has been processing for longer than claimTimeout.
+const claimable = `(stripe_events.status = $7 OR (stripe_events.status = $5 AND stripe_events.claimed_at < $8))`
+
+// finishEvent stores the outcome of processing an event.
+func (s *PaymentService) finishEvent(ctx context.Context, eventID string, processErr error) error {
+	status := EventProcessed
+	var errText *string
+	if processErr != nil {
+		status = EventFailed
+		msg := processErr.Error()
+		errText = &msg
+	}
+
+	_, err := s.Conn.Exec(ctx, `
+		UPDATE stripe_events
+		SET status = $1, error = $2,
+			processed_at = CASE WHEN $1 = 'processed' THEN NOW() ELSE processed_at END
+		WHERE id = $3
+	`, status, errText, eventID)
+
+	return err
+}
+
+func (s *PaymentService) ListWebhookEvents(ctx context.Context, status EventStatus, limit int) ([]WebhookEvent, error) {
+	query := `
+		SELECT id, type, object_id, stripe_created_at, status, error,
+			attempts, received_at, claimed_at, processed_at
+		FROM stripe_events
+		WHERE $1 = '' OR status = $1
+		ORDER BY received_at DESC
+		LIMIT $2
+	`
+
+	rows, err := s.Conn.Query(ctx, query, status, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	events := []WebhookEvent{}
+	for rows.Next() {
+		var e WebhookEvent
+		err := rows.Scan(
+			&e.ID,
+			&e.Type,
+			&e.ObjectID,
+			&e.StripeCreatedAt,
+			&e.Status,
+			&e.Error,
+			&e.Attempts,
+			&e.ReceivedAt,
+			&e.ClaimedAt,
+			&e.ProcessedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+		events = append(events, e)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return events, nil
+}
+
+// ListWebhookEventsHandler serves GET /admin/webhooks/events?status=failed&limit=50.
+func (s *PaymentService) ListWebhookEventsHandler(c *gin.Context) {
+	status := EventStatus(c.Query("status"))
+	switch status {
+	case "", EventProcessing, EventProcessed, EventFailed:
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid status"})
+		return
+	}
+
+	limit := 50
+	if v := c.Query("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > 200 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "limit must be between 1 and 200"})
+			return
+		}
+		limit = n
+	}
+
+	events, err := s.ListWebhookEvents(c.Request.Context(), status, limit)
+	if err != nil {
+		log.Printf("failed to list webhook events %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to list webhook events"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"events": events,
+		"count":  len(events),
+	})
+}
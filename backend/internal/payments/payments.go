@@ -2,10 +2,11 @@
 package payments
 
 import (
+	"campusDoordash/internal/money"
 	"context"
-	"encoding/json"
-	"fmt"
+	"errors"
 	"io"
+	"log"
 	"net/http"
 	"os"
 
@@ -18,105 +19,141 @@ import (
 
 type OrderPayment struct {
 	OrderID          string  `json:"order_id"`
-	FoodTotal        float64 `json:"food_total"`
-	DeliveryFee      float64 `json:"delivery_fee"`
-	DasherFee        float64 `json:"dasher_fee"`
-	CustomerTotal    float64 `json:"customer_total"`
-	RestaurantAmount float64 `json:"restaurant_amount"`
-	DasherAmount     float64 `json:"dasher_amount"`
-	PlatformAmount   float64 `json:"platform_amount"`
+	FoodTotal        money.Money `json:"food_total"`
+	DeliveryFee      money.Money `json:"delivery_fee"`
+	DasherFee        money.Money `json:"dasher_fee"`
+	Tax              money.Money `json:"tax"`
+	Tip              money.Money `json:"tip"`
+	PlatformFee      money.Money `json:"platform_fee"` // platform's cut of the food total
+	CustomerTotal    money.Money `json:"customer_total"`
+	// CustomerTotal is split between these four, which always add up to it
+	RestaurantAmount money.Money `json:"restaurant_amount"`
+	DasherAmount     money.Money `json:"dasher_amount"`
+	PlatformAmount   money.Money `json:"platform_amount"`
+	TaxLiability     money.Money `json:"tax_liability"` // sales tax collected, held for remittance
 }
 
-type PaymentRequest struct {
-	FoodTotal float64 `json:"food_total" binding:"required"`
-	OrderID   string  `json:"order_id" binding:"required"`
+// PaymentKindTip marks PaymentIntents created for tips added after delivery.
+const PaymentKindTip = "tip"
+
+// OrderUpdater applies Stripe events to orders. It is implemented by the
+// orders package so webhook updates follow the order state machine.
+type OrderUpdater interface {
+	ConfirmPayment(ctx context.Context, paymentIntentID string, event EventInfo) error
+	ConfirmTip(ctx context.Context, paymentIntentID string) error
+	FailTip(ctx context.Context, paymentIntentID string) error
+	ApplyRefund(ctx context.Context, paymentIntentID string, refunded money.Money, full bool) error
+	CancelUnpaidOrder(ctx context.Context, paymentIntentID string, event EventInfo) error
+	SetPaymentStatus(ctx context.Context, paymentIntentID string, status PaymentStatus, event EventInfo) error
 }
 
 type PaymentService struct{
 	Conn * pgxpool.Pool	
+	Orders OrderUpdater
+	Pricing * PricingEngine
+	WebhookSecret string
 }
 
-func CalculatePayment(foodTotal float64, orderID string) OrderPayment {
-	deliveryFee := foodTotal * 0.05 //5 percent delivery fee
-	dasherFee := 3.00               //dashers will always make base 3 dollars
-	customerTotal := deliveryFee + dasherFee + foodTotal
-	return OrderPayment{
-		OrderID:          orderID, // Was missing
-		FoodTotal:        foodTotal,
-		DeliveryFee:      deliveryFee,
-		DasherFee:        dasherFee,
-		CustomerTotal:    customerTotal,
-		RestaurantAmount: foodTotal,   // Restaurant gets food cost
-		DasherAmount:     dasherFee,   // Dasher gets base fee (+ tips later)
-		PlatformAmount:   deliveryFee, //
-
-	}
+// CreatePaymentIntent charges the customer total of payment. A non-empty
+// idempotencyKey lets Stripe return the same PaymentIntent for retried requests.
+func CreatePaymentIntent(payment OrderPayment, idempotencyKey string) (*stripe.PaymentIntent, error) {
+	return paymentintent.New(paymentIntentParams(payment, idempotencyKey))
 }
 
-func CreatePaymentIntent(payment OrderPayment) (*stripe.PaymentIntent, error) {
+// paymentIntentParams is the PaymentIntent request for payment: the customer
+// total, with the line items that make it up in the metadata.
+func paymentIntentParams(payment OrderPayment, idempotencyKey string) *stripe.PaymentIntentParams {
 	params := &stripe.PaymentIntentParams{
-		Amount:   stripe.Int64(int64(payment.CustomerTotal * 100)),
-		Currency: stripe.String("usd"),
+		Amount:   stripe.Int64(payment.CustomerTotal.Cents),
+		Currency: stripe.String(payment.CustomerTotal.CurrencyCode()),
 		Metadata: map[string]string{
 			"order_id":     payment.OrderID,
-			"food_total":   fmt.Sprintf("%.2f", payment.FoodTotal),
-			"delivery_fee": fmt.Sprintf("%.2f", payment.DeliveryFee),
-			"dasher_fee":   fmt.Sprintf("%.2f", payment.DasherFee),
+			"food_total":   payment.FoodTotal.String(),
+			"delivery_fee": payment.DeliveryFee.String(),
+			"dasher_fee":   payment.DasherFee.String(),
+			"tax":          payment.Tax.String(),
+			"tip":          payment.Tip.String(),
 		},
 	}
-
-	return paymentintent.New(params)
+	if idempotencyKey != "" {
+		params.SetIdempotencyKey(idempotencyKey)
+	}
+	return params
 }
 
-func CreatePaymentHandler(c *gin.Context) {
-	var req PaymentRequest
-	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(400, gin.H{"error": err.Error()})
-		return
+// CreateTipPaymentIntent charges an extra tip on an order that has already
+// been paid for. Retrying with the same idempotencyKey returns the same
+// PaymentIntent instead of creating another one.
+func CreateTipPaymentIntent(orderID string, tip money.Money, idempotencyKey string) (*stripe.PaymentIntent, error) {
+	params := &stripe.PaymentIntentParams{
+		Amount:   stripe.Int64(tip.Cents),
+		Currency: stripe.String(tip.CurrencyCode()),
+		Metadata: map[string]string{
+			"order_id": orderID,
+			"kind":     PaymentKindTip,
+			"tip":      tip.String(),
+		},
 	}
+	params.SetIdempotencyKey(idempotencyKey)
 
-	payment := CalculatePayment(req.FoodTotal, req.OrderID)
-	intent, err := CreatePaymentIntent(payment)
+	return paymentintent.New(params)
+}
 
+// ClientSecret looks up the client secret of an existing PaymentIntent.
+func ClientSecret(paymentIntentID string) (string, error) {
+	intent, err := paymentintent.Get(paymentIntentID, nil)
 	if err != nil {
-		c.JSON(500, gin.H{"error": "failed to create payment intent"})
-		return
+		return "", err
 	}
-
-	c.JSON(200, gin.H{
-		"client_secret":     intent.ClientSecret,
-		"payment_breakdown": payment,
-	})
+	return intent.ClientSecret, nil
 }
 
 func (s * PaymentService) StripeWebhookHandle() gin.HandlerFunc{
 	return func(c * gin.Context){
-		payload, _ := io.ReadAll(c.Request.Body)
+		payload, err := io.ReadAll(c.Request.Body)
+		if err != nil{
+			c.JSON(http.StatusBadRequest, gin.H{"error": "failed to read body"})
+			return
+		}
+
 		event, err := webhook.ConstructEvent(
 			payload, 
 			c.GetHeader("Stripe-Signature"), 
-			os.Getenv("STRIPE_WEBHOOK_SECRET"),
+			s.WebhookSecret,
 		)
 
 		if err != nil{
 			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid signature"})
 			return
 		}
-		
-		if event.Type == "payment_intent.succeeded"{
-			var pi stripe.PaymentIntent
-			_ = json.Unmarshal(event.Data.Raw, &pi)
-			fmt.Printf("Payment successful for %s\n", pi.ID)
-
-			_, err = s.Conn.Exec(context.Background(), 
-				"UPDATE orders SET status = 'confirmed', confirmed_at = NOW() WHERE payment_intent_id = $1",
-				pi.ID,
-			)
-			
-			if err != nil{
-				fmt.Println("DB update error:", err)
-			}
 
+		claimed, err := s.claimEvent(c.Request.Context(), event)
+		if err != nil{
+			log.Printf("webhook %s: failed to record event: %v", event.ID, err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to record event"})
+			return
+		}
+		if !claimed{
+			// already applied, or another delivery of it is being applied right now
+			c.JSON(http.StatusOK, gin.H{"message": "duplicate event ignored"})
+			return
+		}
+
+		err = s.processEvent(c.Request.Context(), event)
+		if finishErr := s.finishEvent(c.Request.Context(), event.ID, err); finishErr != nil{
+			log.Printf("webhook %s: failed to record outcome: %v", event.ID, finishErr)
+		}
+
+		if errors.Is(err, errBadPayload){
+			log.Printf("webhook %s (%s): %v", event.ID, event.Type, err)
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if err != nil{
+			// anything but a 2xx makes Stripe retry the event later
+			log.Printf("webhook %s (%s) failed: %v", event.ID, event.Type, err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to process event"})
+			return
 		}
 
 		c.Status(http.StatusOK)
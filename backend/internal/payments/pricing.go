@@ -0,0 +1,151 @@
+package payments
+
+import (
+	"campusDoordash/internal/money"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+)
+
+// FeeRules configures how an order's fees are worked out. Percentages are in
+// basis points (100 = 1%).
+type FeeRules struct {
+	DeliveryFee        money.Money   // flat delivery fee charged to the customer
+	DeliveryFeePercent int64         // extra delivery fee as a share of the food subtotal
+	DasherFee          money.Money   // flat amount paid to the dasher on every order
+	PlatformPercent    int64         // platform's cut of the food subtotal, taken from the restaurant's share
+	TaxPercent         int64         // sales tax on the food subtotal
+	TipWindow          time.Duration // how long after delivery a customer can still add a tip
+}
+
+// DefaultFeeRules are used for any setting missing from the environment.
+var DefaultFeeRules = FeeRules{
+	DeliveryFee: money.FromCents(399),
+	DasherFee:   money.FromCents(200),
+	TipWindow:   2 * time.Hour,
+}
+
+// LoadFeeRules reads fee settings from the environment:
+//
+//	DELIVERY_FEE, DASHER_FEE                    dollar amounts, e.g. 3.99
+//	DELIVERY_FEE_BPS, PLATFORM_BPS, TAX_BPS     basis points, e.g. 600 for 6%
+//	TIP_WINDOW                                  duration, e.g. 2h
+func LoadFeeRules() (FeeRules, error) {
+	rules := DefaultFeeRules
+	var err error
+
+	if rules.DeliveryFee, err = envMoney("DELIVERY_FEE", rules.DeliveryFee); err != nil {
+		return rules, err
+	}
+	if rules.DasherFee, err = envMoney("DASHER_FEE", rules.DasherFee); err != nil {
+		return rules, err
+	}
+	if rules.DeliveryFeePercent, err = envBasisPoints("DELIVERY_FEE_BPS", rules.DeliveryFeePercent); err != nil {
+		return rules, err
+	}
+	if rules.PlatformPercent, err = envBasisPoints("PLATFORM_BPS", rules.PlatformPercent); err != nil {
+		return rules, err
+	}
+	if rules.TaxPercent, err = envBasisPoints("TAX_BPS", rules.TaxPercent); err != nil {
+		return rules, err
+	}
+	if v := os.Getenv("TIP_WINDOW"); v != "" {
+		if rules.TipWindow, err = time.ParseDuration(v); err != nil {
+			return rules, fmt.Errorf("TIP_WINDOW must be a duration like 2h, got %q", v)
+		}
+	}
+
+	return rules, nil
+}
+
+func envMoney(key string, fallback money.Money) (money.Money, error) {
+	v := os.Getenv(key)
+	if v == "" {
+		return fallback, nil
+	}
+	m, err := money.Parse(v)
+	if err != nil || m.IsNegative() {
+		return fallback, fmt.Errorf("%s must be a non-negative dollar amount, got %q", key, v)
+	}
+	return m, nil
+}
+
+func envBasisPoints(key string, fallback int64) (int64, error) {
+	v := os.Getenv(key)
+	if v == "" {
+		return fallback, nil
+	}
+	bps, err := strconv.ParseInt(v, 10, 64)
+	if err != nil || bps < 0 || bps > 10000 {
+		return fallback, fmt.Errorf("%s must be basis points between 0 and 10000, got %q", key, v)
+	}
+	return bps, nil
+}
+
+// PricingEngine turns a food subtotal into the full payment breakdown. The
+// same breakdown is stored on the order and charged through Stripe.
+type PricingEngine struct {
+	Rules FeeRules
+}
+
+func NewPricingEngine(rules FeeRules) *PricingEngine {
+	return &PricingEngine{Rules: rules}
+}
+
+// ErrSplitMismatch means a quote's split doesn't add up to the customer total.
+var ErrSplitMismatch = errors.New("payment split does not add up to the customer total")
+
+// Quote prices an order. tip goes entirely to the dasher and tax is kept
+// apart as a tax liability, so nobody's share includes it.
+func (e *PricingEngine) Quote(orderID string, foodTotal, tip money.Money) (OrderPayment, error) {
+	platformCut, err := foodTotal.Percent(e.Rules.PlatformPercent)
+	if err != nil {
+		return OrderPayment{}, err
+	}
+	tax, err := foodTotal.Percent(e.Rules.TaxPercent)
+	if err != nil {
+		return OrderPayment{}, err
+	}
+	percentFee, err := foodTotal.Percent(e.Rules.DeliveryFeePercent)
+	if err != nil {
+		return OrderPayment{}, err
+	}
+	deliveryFee, err := e.Rules.DeliveryFee.Add(percentFee)
+	if err != nil {
+		return OrderPayment{}, err
+	}
+
+	p := OrderPayment{
+		OrderID:      orderID,
+		FoodTotal:    foodTotal,
+		DeliveryFee:  deliveryFee,
+		DasherFee:    e.Rules.DasherFee,
+		Tax:          tax,
+		Tip:          tip,
+		PlatformFee:  platformCut,
+		TaxLiability: tax,
+	}
+	if p.CustomerTotal, err = money.Sum(foodTotal, deliveryFee, e.Rules.DasherFee, tax, tip); err != nil {
+		return OrderPayment{}, err
+	}
+	if p.RestaurantAmount, err = foodTotal.Sub(platformCut); err != nil {
+		return OrderPayment{}, err
+	}
+	if p.DasherAmount, err = e.Rules.DasherFee.Add(tip); err != nil {
+		return OrderPayment{}, err
+	}
+	if p.PlatformAmount, err = deliveryFee.Add(platformCut); err != nil {
+		return OrderPayment{}, err
+	}
+
+	split, err := money.Sum(p.RestaurantAmount, p.DasherAmount, p.PlatformAmount, p.TaxLiability)
+	if err != nil {
+		return OrderPayment{}, err
+	}
+	if split != p.CustomerTotal {
+		return OrderPayment{}, fmt.Errorf("%w: %s split, %s charged", ErrSplitMismatch, split, p.CustomerTotal)
+	}
+	return p, nil
+}
@@ -0,0 +1,93 @@
+package payments
+
+import (
+	"campusDoordash/internal/money"
+	"testing"
+)
+
+func TestQuoteMatchesStripeCharge(t *testing.T) {
+	tests := []struct {
+		name     string
+		rules    FeeRules
+		food     int64
+		tip      int64
+		total    int64
+		tax      int64
+		delivery int64
+	}{
+		{
+			name:     "defaults",
+			rules:    DefaultFeeRules,
+			food:     1250,
+			total:    1250 + 399 + 200,
+			delivery: 399,
+		},
+		{
+			name:     "tax and tip",
+			rules:    FeeRules{DeliveryFee: money.FromCents(399), DasherFee: money.FromCents(200), TaxPercent: 600},
+			food:     1999,
+			tip:      300,
+			tax:      120, // 119.94
+			total:    1999 + 399 + 200 + 120 + 300,
+			delivery: 399,
+		},
+		{
+			name: "percentage fees round per line",
+			rules: FeeRules{
+				DeliveryFee:        money.FromCents(99),
+				DeliveryFeePercent: 250,
+				DasherFee:          money.FromCents(150),
+				PlatformPercent:    1500,
+				TaxPercent:         625,
+			},
+			food:     1333,
+			tip:      0,
+			tax:      83,      // 83.3125
+			delivery: 99 + 33, // 33.325
+			total:    1333 + 132 + 150 + 83,
+		},
+		{
+			name:  "empty order",
+			rules: FeeRules{TaxPercent: 600, PlatformPercent: 1000},
+		},
+	}
+
+	for _, tt := range tests {
+		p, err := NewPricingEngine(tt.rules).Quote("order-1", money.FromCents(tt.food), money.FromCents(tt.tip))
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		if p.CustomerTotal.Cents != tt.total || p.Tax.Cents != tt.tax || p.DeliveryFee.Cents != tt.delivery {
+			t.Errorf("%s: total %d, tax %d, delivery %d; want %d, %d, %d", tt.name,
+				p.CustomerTotal.Cents, p.Tax.Cents, p.DeliveryFee.Cents, tt.total, tt.tax, tt.delivery)
+		}
+
+		params := paymentIntentParams(p, "")
+		if *params.Amount != p.CustomerTotal.Cents {
+			t.Errorf("%s: Stripe is charged %d cents, the order total is %d", tt.name, *params.Amount, p.CustomerTotal.Cents)
+		}
+
+		// the line items sent to Stripe add up to exactly what it charges
+		var lines int64
+		for _, key := range []string{"food_total", "delivery_fee", "dasher_fee", "tax", "tip"} {
+			m, err := money.Parse(params.Metadata[key])
+			if err != nil {
+				t.Fatalf("%s: metadata %s: %v", tt.name, key, err)
+			}
+			lines += m.Cents
+		}
+		if lines != *params.Amount {
+			t.Errorf("%s: metadata line items add up to %d cents, Stripe charges %d", tt.name, lines, *params.Amount)
+		}
+
+		// and every cent charged is allocated to someone
+		split := p.RestaurantAmount.Cents + p.DasherAmount.Cents + p.PlatformAmount.Cents + p.TaxLiability.Cents
+		if split != p.CustomerTotal.Cents {
+			t.Errorf("%s: split adds up to %d cents, customer pays %d", tt.name, split, p.CustomerTotal.Cents)
+		}
+		if p.TaxLiability != p.Tax {
+			t.Errorf("%s: tax liability %s, tax %s", tt.name, p.TaxLiability, p.Tax)
+		}
+	}
+}
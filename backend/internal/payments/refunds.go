@@ -0,0 +1,91 @@
+package payments
+
+import (
+	"campusDoordash/internal/money"
+	"errors"
+
+	"github.com/stripe/stripe-go/v82"
+	"github.com/stripe/stripe-go/v82/paymentintent"
+	"github.com/stripe/stripe-go/v82/refund"
+)
+
+// ErrPaymentProcessing is returned when a payment is still being processed by
+// Stripe and can be neither cancelled nor refunded yet.
+var ErrPaymentProcessing = errors.New("payment is still processing, try again shortly")
+
+// Reversal describes how money was returned when an order was cancelled.
+type Reversal struct {
+	Refunded bool        // a refund was issued against a captured payment
+	Amount   money.Money // amount refunded, zero if the PaymentIntent was just cancelled
+	RefundID string
+}
+
+// CancelPaymentIntent cancels a PaymentIntent that hasn't been paid.
+// Already cancelled intents are fine; ones that are processing or have
+// succeeded return ErrPaymentProcessing, since their money is on its way.
+func CancelPaymentIntent(paymentIntentID string, idempotencyKey string) error {
+	intent, err := paymentintent.Get(paymentIntentID, nil)
+	if err != nil {
+		return err
+	}
+
+	switch intent.Status {
+	case stripe.PaymentIntentStatusCanceled:
+		return nil
+	case stripe.PaymentIntentStatusProcessing, stripe.PaymentIntentStatusSucceeded:
+		return ErrPaymentProcessing
+	}
+
+	params := &stripe.PaymentIntentCancelParams{
+		CancellationReason: stripe.String(string(stripe.PaymentIntentCancellationReasonAbandoned)),
+	}
+	params.SetIdempotencyKey(idempotencyKey)
+	_, err = paymentintent.Cancel(paymentIntentID, params)
+	return err
+}
+
+// ReversePayment returns the money for a PaymentIntent. Captured payments
+// are refunded (amount nil means a full refund); payments that never went
+// through have their PaymentIntent cancelled instead. idempotencyKey makes
+// retries safe if the caller fails after Stripe accepted the request.
+func ReversePayment(paymentIntentID string, amount *money.Money, idempotencyKey string) (*Reversal, error) {
+	intent, err := paymentintent.Get(paymentIntentID, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	switch intent.Status {
+	case stripe.PaymentIntentStatusCanceled:
+		return &Reversal{}, nil
+
+	case stripe.PaymentIntentStatusProcessing:
+		return nil, ErrPaymentProcessing
+
+	case stripe.PaymentIntentStatusSucceeded:
+		params := &stripe.RefundParams{
+			PaymentIntent: stripe.String(paymentIntentID),
+			Reason:        stripe.String(string(stripe.RefundReasonRequestedByCustomer)),
+		}
+		if amount != nil {
+			params.Amount = stripe.Int64(amount.Cents)
+		}
+		params.SetIdempotencyKey(idempotencyKey + "-refund")
+
+		r, err := refund.New(params)
+		if err != nil {
+			return nil, err
+		}
+		return &Reversal{Refunded: true, Amount: money.FromCents(r.Amount), RefundID: r.ID}, nil
+
+	default:
+		params := &stripe.PaymentIntentCancelParams{
+			CancellationReason: stripe.String(string(stripe.PaymentIntentCancellationReasonRequestedByCustomer)),
+		}
+		params.SetIdempotencyKey(idempotencyKey + "-cancel")
+
+		if _, err := paymentintent.Cancel(paymentIntentID, params); err != nil {
+			return nil, err
+		}
+		return &Reversal{}, nil
+	}
+}
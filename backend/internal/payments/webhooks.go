@@ -0,0 +1,156 @@
+package payments
+
+import (
+	"campusDoordash/internal/money"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/stripe/stripe-go/v82"
+)
+
+// PaymentStatus is the state of an order's PaymentIntent as last reported by Stripe.
+type PaymentStatus string
+
+const (
+	PaymentRequiresPayment PaymentStatus = "requires_payment"
+	PaymentProcessing      PaymentStatus = "processing"
+	PaymentSucceeded       PaymentStatus = "succeeded"
+	PaymentFailed          PaymentStatus = "failed"
+	PaymentCanceled        PaymentStatus = "canceled"
+	PaymentDisputed        PaymentStatus = "disputed"
+)
+
+// errBadPayload means the event was signed correctly but its data could not
+// be decoded, so retrying would not help.
+var errBadPayload = errors.New("malformed event payload")
+
+// ErrUnknownPaymentIntent is returned by an OrderUpdater when no order or tip
+// belongs to the event's PaymentIntent, e.g. one created outside this app.
+var ErrUnknownPaymentIntent = errors.New("no order for payment intent")
+
+// processEvent applies event and acknowledges events for PaymentIntents this
+// app doesn't know about, which no number of retries would fix.
+func (s *PaymentService) processEvent(ctx context.Context, event stripe.Event) error {
+	err := s.handleEvent(ctx, event)
+	if errors.Is(err, ErrUnknownPaymentIntent) {
+		log.Printf("webhook %s (%s): ignoring: %v", event.ID, event.Type, err)
+		return nil
+	}
+	return err
+}
+
+// handleEvent applies a verified Stripe event to the matching order. Any error
+// other than errBadPayload is treated as temporary and makes Stripe retry.
+//
+//	payment_intent.succeeded       payment succeeded, order pending -> confirmed (or tip added)
+//	payment_intent.processing      payment processing, order unchanged
+//	payment_intent.payment_failed  payment failed, order stays pending so the customer can retry (or tip failed)
+//	payment_intent.canceled        payment canceled, order pending -> cancelled (or tip failed)
+//	charge.refunded                refunded amount and refund status updated
+//	charge.dispute.created         payment disputed, order unchanged
+func (s *PaymentService) handleEvent(ctx context.Context, event stripe.Event) error {
+	info := EventInfo{ID: event.ID, Created: time.Unix(event.Created, 0)}
+
+	switch event.Type {
+	case stripe.EventTypePaymentIntentSucceeded:
+		pi, err := decodePaymentIntent(event)
+		if err != nil {
+			return err
+		}
+		if pi.Metadata["kind"] == PaymentKindTip {
+			return s.Orders.ConfirmTip(ctx, pi.ID)
+		}
+		return s.Orders.ConfirmPayment(ctx, pi.ID, info)
+
+	case stripe.EventTypePaymentIntentProcessing:
+		return s.setPaymentStatus(ctx, event, info, PaymentProcessing)
+
+	case stripe.EventTypePaymentIntentPaymentFailed:
+		return s.setPaymentStatus(ctx, event, info, PaymentFailed)
+
+	case stripe.EventTypePaymentIntentCanceled:
+		pi, err := decodePaymentIntent(event)
+		if err != nil {
+			return err
+		}
+		if pi.Metadata["kind"] == PaymentKindTip {
+			return s.Orders.FailTip(ctx, pi.ID)
+		}
+		return s.Orders.CancelUnpaidOrder(ctx, pi.ID, info)
+
+	case stripe.EventTypeChargeRefunded:
+		var ch stripe.Charge
+		if err := json.Unmarshal(event.Data.Raw, &ch); err != nil {
+			return fmt.Errorf("%w: %v", errBadPayload, err)
+		}
+		if ch.PaymentIntent == nil {
+			return nil
+		}
+		return s.Orders.ApplyRefund(ctx, ch.PaymentIntent.ID, money.FromCents(ch.AmountRefunded), ch.Refunded)
+
+	case stripe.EventTypeChargeDisputeCreated:
+		var dispute stripe.Dispute
+		if err := json.Unmarshal(event.Data.Raw, &dispute); err != nil {
+			return fmt.Errorf("%w: %v", errBadPayload, err)
+		}
+		if dispute.PaymentIntent == nil {
+			return nil
+		}
+		log.Printf("payment %s disputed: %s", dispute.PaymentIntent.ID, dispute.Reason)
+		return s.Orders.SetPaymentStatus(ctx, dispute.PaymentIntent.ID, PaymentDisputed, info)
+	}
+
+	return nil
+}
+
+func (s *PaymentService) setPaymentStatus(ctx context.Context, event stripe.Event, info EventInfo, status PaymentStatus) error {
+	pi, err := decodePaymentIntent(event)
+	if err != nil {
+		return err
+	}
+	if pi.Metadata["kind"] == PaymentKindTip {
+		if status == PaymentFailed {
+			return s.Orders.FailTip(ctx, pi.ID)
+		}
+		return nil
+	}
+	return s.Orders.SetPaymentStatus(ctx, pi.ID, status, info)
+}
+
+// paymentRank orders payment states that can share a Stripe timestamp (one
+// second resolution) so the later state in a PaymentIntent's life wins a tie.
+var paymentRank = map[PaymentStatus]int{
+	PaymentRequiresPayment: 0,
+	PaymentProcessing:      1,
+	PaymentFailed:          2,
+	PaymentSucceeded:       3,
+	PaymentCanceled:        3,
+	PaymentDisputed:        4,
+}
+
+// Supersedes reports whether an event creating status at created should
+// replace current, which was set by an event created at currentAt. It keeps a
+// late redelivery of an older event from overwriting newer payment state. A
+// tie only goes to a later state, so an event that is applied a second time
+// (say its outcome couldn't be saved and it was claimed again) changes nothing.
+func (status PaymentStatus) Supersedes(current PaymentStatus, currentAt *time.Time, created time.Time) bool {
+	if currentAt == nil || created.After(*currentAt) {
+		return true
+	}
+	if created.Before(*currentAt) {
+		return false
+	}
+	return paymentRank[status] > paymentRank[current]
+}
+
+func decodePaymentIntent(event stripe.Event) (*stripe.PaymentIntent, error) {
+	var pi stripe.PaymentIntent
+	if err := json.Unmarshal(event.Data.Raw, &pi); err != nil {
+		return nil, fmt.Errorf("%w: %v", errBadPayload, err)
+	}
+	return &pi, nil
+}
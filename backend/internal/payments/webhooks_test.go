@@ -0,0 +1,200 @@
+package payments
+
+import (
+	"bytes"
+	"campusDoordash/internal/money"
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stripe/stripe-go/v82"
+	"github.com/stripe/stripe-go/v82/webhook"
+)
+
+const testWebhookSecret = "whsec_test_fixtures"
+
+// fakeOrders records the calls webhook events turn into.
+type fakeOrders struct {
+	calls []string
+	err   error
+}
+
+func (f *fakeOrders) record(format string, args ...any) error {
+	f.calls = append(f.calls, fmt.Sprintf(format, args...))
+	return f.err
+}
+
+func (f *fakeOrders) ConfirmPayment(ctx context.Context, paymentIntentID string, event EventInfo) error {
+	return f.record("ConfirmPayment %s %s", paymentIntentID, event.ID)
+}
+
+func (f *fakeOrders) ConfirmTip(ctx context.Context, paymentIntentID string) error {
+	return f.record("ConfirmTip %s", paymentIntentID)
+}
+
+func (f *fakeOrders) FailTip(ctx context.Context, paymentIntentID string) error {
+	return f.record("FailTip %s", paymentIntentID)
+}
+
+func (f *fakeOrders) ApplyRefund(ctx context.Context, paymentIntentID string, refunded money.Money, full bool) error {
+	return f.record("ApplyRefund %s %s full=%t", paymentIntentID, refunded, full)
+}
+
+func (f *fakeOrders) CancelUnpaidOrder(ctx context.Context, paymentIntentID string, event EventInfo) error {
+	return f.record("CancelUnpaidOrder %s %s", paymentIntentID, event.ID)
+}
+
+func (f *fakeOrders) SetPaymentStatus(ctx context.Context, paymentIntentID string, status PaymentStatus, event EventInfo) error {
+	return f.record("SetPaymentStatus %s %s", paymentIntentID, status)
+}
+
+// signedFixture reads testdata/webhooks/name.json and signs it the way Stripe would.
+func signedFixture(t *testing.T, name, secret string) (payload []byte, header string) {
+	t.Helper()
+	payload, err := os.ReadFile(filepath.Join("testdata", "webhooks", name+".json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	signed := webhook.GenerateTestSignedPayload(&webhook.UnsignedPayload{Payload: payload, Secret: secret})
+	return payload, signed.Header
+}
+
+func constructFixture(t *testing.T, name string) stripe.Event {
+	t.Helper()
+	payload, header := signedFixture(t, name, testWebhookSecret)
+	event, err := webhook.ConstructEvent(payload, header, testWebhookSecret)
+	if err != nil {
+		t.Fatalf("%s: %v", name, err)
+	}
+	return event
+}
+
+func TestWebhookFixtures(t *testing.T) {
+	tests := []struct {
+		fixture string
+		want    []string
+	}{
+		{"payment_succeeded", []string{"ConfirmPayment pi_order evt_payment_succeeded"}},
+		{"payment_processing", []string{"SetPaymentStatus pi_order processing"}},
+		{"payment_failed", []string{"SetPaymentStatus pi_order failed"}},
+		{"payment_canceled", []string{"CancelUnpaidOrder pi_order evt_payment_canceled"}},
+		{"tip_succeeded", []string{"ConfirmTip pi_tip"}},
+		{"tip_failed", []string{"FailTip pi_tip"}},
+		{"tip_canceled", []string{"FailTip pi_tip"}},
+		{"tip_processing", nil},
+		{"charge_refunded", []string{"ApplyRefund pi_order 12.50 full=false"}},
+		{"dispute_created", []string{"SetPaymentStatus pi_order disputed"}},
+		{"customer_created", nil},
+	}
+
+	for _, tt := range tests {
+		orders := &fakeOrders{}
+		s := &PaymentService{Orders: orders, WebhookSecret: testWebhookSecret}
+
+		if err := s.processEvent(context.Background(), constructFixture(t, tt.fixture)); err != nil {
+			t.Errorf("%s: %v", tt.fixture, err)
+			continue
+		}
+		if !reflect.DeepEqual(orders.calls, tt.want) {
+			t.Errorf("%s: calls = %q, want %q", tt.fixture, orders.calls, tt.want)
+		}
+	}
+}
+
+func TestWebhookAcknowledgesUnknownPaymentIntents(t *testing.T) {
+	orders := &fakeOrders{err: fmt.Errorf("%w pi_order", ErrUnknownPaymentIntent)}
+	s := &PaymentService{Orders: orders, WebhookSecret: testWebhookSecret}
+
+	if err := s.processEvent(context.Background(), constructFixture(t, "payment_succeeded")); err != nil {
+		t.Fatalf("unknown PaymentIntent returned %v, want it acknowledged", err)
+	}
+
+	// other failures are still returned so Stripe retries them
+	orders.err = errors.New("connection reset")
+	if err := s.processEvent(context.Background(), constructFixture(t, "payment_succeeded")); err == nil {
+		t.Fatal("a failed update was acknowledged")
+	}
+}
+
+func TestWebhookMalformedPayload(t *testing.T) {
+	orders := &fakeOrders{}
+	s := &PaymentService{Orders: orders, WebhookSecret: testWebhookSecret}
+
+	err := s.processEvent(context.Background(), constructFixture(t, "malformed_payment_intent"))
+	if !errors.Is(err, errBadPayload) {
+		t.Fatalf("error = %v, want errBadPayload", err)
+	}
+	if len(orders.calls) != 0 {
+		t.Errorf("malformed event reached the orders: %q", orders.calls)
+	}
+}
+
+func TestWebhookRejectsBadSignatures(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	payload, forged := signedFixture(t, "payment_succeeded", "whsec_someone_else")
+	_, valid := signedFixture(t, "payment_succeeded", testWebhookSecret)
+
+	tests := []struct {
+		name    string
+		payload []byte
+		header  string
+	}{
+		{"no signature", payload, ""},
+		{"wrong secret", payload, forged},
+		{"tampered body", bytes.Replace(payload, []byte("1849"), []byte("1"), 1), valid},
+	}
+
+	for _, tt := range tests {
+		orders := &fakeOrders{}
+		// no database: a rejected event must never get as far as recording it
+		s := &PaymentService{Orders: orders, WebhookSecret: testWebhookSecret}
+
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		c.Request = httptest.NewRequest(http.MethodPost, "/webhooks/stripe", bytes.NewReader(tt.payload))
+		if tt.header != "" {
+			c.Request.Header.Set("Stripe-Signature", tt.header)
+		}
+		s.StripeWebhookHandle()(c)
+
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("%s: status %d, want 400", tt.name, w.Code)
+		}
+		if len(orders.calls) != 0 {
+			t.Errorf("%s: unsigned event reached the orders: %q", tt.name, orders.calls)
+		}
+	}
+}
+
+func TestSupersedes(t *testing.T) {
+	at := time.Unix(1760000000, 0)
+	tests := []struct {
+		name      string
+		status    PaymentStatus
+		current   PaymentStatus
+		currentAt *time.Time
+		created   time.Time
+		want      bool
+	}{
+		{"first event", PaymentSucceeded, PaymentRequiresPayment, nil, at, true},
+		{"newer event", PaymentFailed, PaymentProcessing, &at, at.Add(time.Second), true},
+		{"older event", PaymentProcessing, PaymentSucceeded, &at, at.Add(-time.Second), false},
+		{"same second, later state", PaymentSucceeded, PaymentProcessing, &at, at, true},
+		{"same second, earlier state", PaymentProcessing, PaymentSucceeded, &at, at, false},
+		{"same event applied again", PaymentSucceeded, PaymentSucceeded, &at, at, false},
+		{"failure after processing", PaymentFailed, PaymentProcessing, &at, at, true},
+	}
+	for _, tt := range tests {
+		if got := tt.status.Supersedes(tt.current, tt.currentAt, tt.created); got != tt.want {
+			t.Errorf("%s: Supersedes = %t, want %t", tt.name, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,109 @@
+package restaurants
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+)
+
+type MenuEntity string
+
+const (
+	EntityFood     MenuEntity = "food"
+	EntityCategory MenuEntity = "category"
+)
+
+type MenuAction string
+
+const (
+	ActionCreate       MenuAction = "create"
+	ActionUpdate       MenuAction = "update"
+	ActionArchive      MenuAction = "archive"
+	ActionReorder      MenuAction = "reorder"
+	ActionAvailability MenuAction = "availability"
+	ActionPrice        MenuAction = "price"
+)
+
+// FieldChange is the old and new value of one field.
+type FieldChange struct {
+	From any `json:"from"`
+	To   any `json:"to"`
+}
+
+// MenuChange is one audited edit to a food item or category.
+type MenuChange struct {
+	ID           int64                  `json:"id"`
+	RestaurantID uuid.UUID              `json:"restaurant_id"`
+	EntityType   MenuEntity             `json:"entity_type"`
+	EntityID     uuid.UUID              `json:"entity_id"`
+	Action       MenuAction             `json:"action"`
+	Changes      map[string]FieldChange `json:"changes"`
+	ChangedBy    uuid.UUID              `json:"changed_by"`
+	ChangedAt    time.Time              `json:"changed_at"`
+}
+
+// changeSet collects the fields an edit actually changed.
+type changeSet map[string]FieldChange
+
+// add records field if from and to differ once encoded, so pointers and
+// Money compare by value.
+func (c changeSet) add(field string, from, to any) {
+	a, errA := json.Marshal(from)
+	b, errB := json.Marshal(to)
+	if errA == nil && errB == nil && string(a) == string(b) {
+		return
+	}
+	c[field] = FieldChange{From: from, To: to}
+}
+
+// RecordMenuChange writes one audit row inside tx. Menu edits made outside
+// this package, such as imports, record theirs with it too.
+func RecordMenuChange(ctx context.Context, tx pgx.Tx, restaurantID uuid.UUID, entity MenuEntity, entityID uuid.UUID, action MenuAction, changes map[string]FieldChange, editorID uuid.UUID) error {
+	if changes == nil {
+		changes = map[string]FieldChange{}
+	}
+	changesJSON, err := json.Marshal(changes)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(ctx, `
+		INSERT INTO menu_changes (restaurant_id, entity_type, entity_id, action, changes, changed_by)
+		VALUES ($1, $2, $3, $4, $5, $6)
+	`, restaurantID, entity, entityID, action, changesJSON, editorID)
+	return err
+}
+
+// ListMenuChanges returns a restaurant's menu edits, newest first,
+// optionally only those to entityID.
+func (s *RestaurantService) ListMenuChanges(ctx context.Context, restaurantID uuid.UUID, entityID *uuid.UUID, limit int) ([]MenuChange, error) {
+	rows, err := s.conn.Query(ctx, `
+		SELECT id, restaurant_id, entity_type, entity_id, action, changes, changed_by, changed_at
+		FROM menu_changes
+		WHERE restaurant_id = $1 AND ($2::uuid IS NULL OR entity_id = $2)
+		ORDER BY changed_at DESC, id DESC
+		LIMIT $3
+	`, restaurantID, entityID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	changes := []MenuChange{}
+	for rows.Next() {
+		var c MenuChange
+		var changesJSON []byte
+		err := rows.Scan(&c.ID, &c.RestaurantID, &c.EntityType, &c.EntityID, &c.Action, &changesJSON, &c.ChangedBy, &c.ChangedAt)
+		if err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal(changesJSON, &c.Changes); err != nil {
+			return nil, err
+		}
+		changes = append(changes, c)
+	}
+	return changes, rows.Err()
+}
@@ -1,8 +1,12 @@
 package restaurants
 
 import(
+	"campusDoordash/internal/auth"
+	"campusDoordash/internal/money"
+	"errors"
 	"log"
 	"net/http"
+	"strconv"
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
 )
@@ -19,6 +23,7 @@ func NewRestaurantHandler(service * RestaurantService) *RestaurantHandlers{
 	return &RestaurantHandlers{service: service} 
 }
 
+// GetAllRestaurantHandlers serves GET /api/restaurants, or only the open ones with ?open_now=true.
 func (h * RestaurantHandlers) GetAllRestaurantHandlers(c *gin.Context){
 	restaurants, err := h.service.GetAllRestaurants(c.Request.Context())
 	if err != nil{
@@ -27,6 +32,16 @@ func (h * RestaurantHandlers) GetAllRestaurantHandlers(c *gin.Context){
 		return
 	}
 
+	if c.Query("open_now") == "true"{
+		open := []Restaurant{}
+		for _, r := range restaurants{
+			if r.IsOpenNow{
+				open = append(open, r)
+			}
+		}
+		restaurants = open
+	}
+
 	c.JSON(http.StatusOK, gin.H{
 		"restaurants": restaurants,
 	})
@@ -108,3 +123,351 @@ func (h* RestaurantHandlers) GetRestaurantMenuHandler(c * gin.Context){
 	
 }
 
+// GetRestaurantHoursHandler serves GET /api/restaurants/:id/hours.
+func (h * RestaurantHandlers) GetRestaurantHoursHandler(c * gin.Context){
+	restaurantID, err := uuid.Parse(c.Param("id"))
+	if err != nil{
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid restaurant ID"})
+		return
+	}
+
+	restaurant, err := h.service.GetRestaurantsByID(c.Request.Context(), restaurantID)
+	if err != nil{
+		c.JSON(http.StatusNotFound, gin.H{"error": "restaurant not found"})
+		return
+	}
+
+	schedule, err := h.service.GetSchedule(c.Request.Context(), restaurantID)
+	if err != nil{
+		log.Printf("failed to fetch hours %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch hours"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"hours": schedule,
+		"timezone": h.service.campus.String(),
+		"is_open_now": restaurant.IsOpenNow,
+		"next_open_at": restaurant.NextOpenAt,
+	})
+}
+
+// staffEditor returns the restaurant from :id and the signed in editor,
+// writing a 403 unless they are staff there or an admin.
+func staffEditor(c * gin.Context) (restaurantID uuid.UUID, editorID uuid.UUID, ok bool){
+	restaurantID, err := uuid.Parse(c.Param("id"))
+	if err != nil{
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid restaurant ID"})
+		return restaurantID, editorID, false
+	}
+	editorID, err = uuid.Parse(c.GetString("user_id"))
+	if err != nil{
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "invalid user ID"})
+		return restaurantID, editorID, false
+	}
+
+	roles := auth.RolesFrom(c)
+	if !roles.StaffOf(restaurantID) && !roles.Has(auth.RoleAdmin){
+		c.JSON(http.StatusForbidden, gin.H{"error": "you do not manage this restaurant's menu"})
+		return restaurantID, editorID, false
+	}
+	return restaurantID, editorID, true
+}
+
+// respondMenuError writes the response for a failed menu edit and reports whether there was one.
+func respondMenuError(c * gin.Context, err error) bool{
+	switch{
+	case err == nil:
+		return false
+	case errors.Is(err, ErrFoodNotFound), errors.Is(err, ErrCategoryNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, ErrCategoryNotEmpty):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	case errors.Is(err, ErrInvalidMenuEdit):
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error()})
+	default:
+		log.Printf("failed to edit menu %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to edit menu"})
+	}
+	return true
+}
+
+// ListCategoriesHandler serves GET /api/restaurants/:id/categories?include_archived=true.
+func (h * RestaurantHandlers) ListCategoriesHandler(c * gin.Context){
+	restaurantID, _, ok := staffEditor(c)
+	if !ok{
+		return
+	}
+
+	categories, err := h.service.ListCategories(c.Request.Context(), restaurantID, c.Query("include_archived") == "true")
+	if respondMenuError(c, err){
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"categories": categories})
+}
+
+// CreateCategoryHandler serves POST /api/restaurants/:id/categories.
+func (h * RestaurantHandlers) CreateCategoryHandler(c * gin.Context){
+	restaurantID, editorID, ok := staffEditor(c)
+	if !ok{
+		return
+	}
+	var req CategoryRequest
+	if err := c.ShouldBindJSON(&req); err != nil{
+		c.JSON(http.StatusBadRequest, gin.H{"error": "name is required"})
+		return
+	}
+
+	category, err := h.service.CreateCategory(c.Request.Context(), restaurantID, editorID, req)
+	if respondMenuError(c, err){
+		return
+	}
+	c.JSON(http.StatusCreated, gin.H{"category": category})
+}
+
+// RenameCategoryHandler serves PATCH /api/restaurants/:id/categories/:category_id.
+func (h * RestaurantHandlers) RenameCategoryHandler(c * gin.Context){
+	restaurantID, editorID, ok := staffEditor(c)
+	if !ok{
+		return
+	}
+	categoryID, err := uuid.Parse(c.Param("category_id"))
+	if err != nil{
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid category ID"})
+		return
+	}
+	var req CategoryRequest
+	if err := c.ShouldBindJSON(&req); err != nil{
+		c.JSON(http.StatusBadRequest, gin.H{"error": "name is required"})
+		return
+	}
+
+	category, err := h.service.RenameCategory(c.Request.Context(), restaurantID, categoryID, editorID, req)
+	if respondMenuError(c, err){
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"category": category})
+}
+
+// ArchiveCategoryHandler serves POST /api/restaurants/:id/categories/:category_id/archive.
+func (h * RestaurantHandlers) ArchiveCategoryHandler(c * gin.Context){
+	restaurantID, editorID, ok := staffEditor(c)
+	if !ok{
+		return
+	}
+	categoryID, err := uuid.Parse(c.Param("category_id"))
+	if err != nil{
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid category ID"})
+		return
+	}
+
+	category, err := h.service.ArchiveCategory(c.Request.Context(), restaurantID, categoryID, editorID)
+	if respondMenuError(c, err){
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"category": category})
+}
+
+// ReorderCategoriesHandler serves PUT /api/restaurants/:id/categories/order with {"ids": [...]}.
+func (h * RestaurantHandlers) ReorderCategoriesHandler(c * gin.Context){
+	restaurantID, editorID, ok := staffEditor(c)
+	if !ok{
+		return
+	}
+	var req ReorderRequest
+	if err := c.ShouldBindJSON(&req); err != nil{
+		c.JSON(http.StatusBadRequest, gin.H{"error": "ids is required"})
+		return
+	}
+
+	categories, err := h.service.ReorderCategories(c.Request.Context(), restaurantID, editorID, req.IDs)
+	if respondMenuError(c, err){
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"categories": categories})
+}
+
+// ListFoodItemsHandler serves GET /api/restaurants/:id/items?include_archived=true,
+// including items that are unavailable.
+func (h * RestaurantHandlers) ListFoodItemsHandler(c * gin.Context){
+	restaurantID, _, ok := staffEditor(c)
+	if !ok{
+		return
+	}
+
+	items, err := h.service.ListFoodItems(c.Request.Context(), restaurantID, c.Query("include_archived") == "true")
+	if respondMenuError(c, err){
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"items": items, "count": len(items)})
+}
+
+// CreateFoodItemHandler serves POST /api/restaurants/:id/items.
+func (h * RestaurantHandlers) CreateFoodItemHandler(c * gin.Context){
+	restaurantID, editorID, ok := staffEditor(c)
+	if !ok{
+		return
+	}
+	var req CreateFoodItemRequest
+	if err := c.ShouldBindJSON(&req); err != nil{
+		c.JSON(http.StatusBadRequest, gin.H{"error": "food_name and price are required"})
+		return
+	}
+
+	item, err := h.service.CreateFoodItem(c.Request.Context(), restaurantID, editorID, req)
+	if respondMenuError(c, err){
+		return
+	}
+	c.JSON(http.StatusCreated, gin.H{"item": item})
+}
+
+// UpdateFoodItemHandler serves PATCH /api/restaurants/:id/items/:food_id.
+func (h * RestaurantHandlers) UpdateFoodItemHandler(c * gin.Context){
+	restaurantID, editorID, ok := staffEditor(c)
+	if !ok{
+		return
+	}
+	foodID, err := uuid.Parse(c.Param("food_id"))
+	if err != nil{
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid food ID"})
+		return
+	}
+	var req UpdateFoodItemRequest
+	if err := c.ShouldBindJSON(&req); err != nil{
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request body"})
+		return
+	}
+
+	item, err := h.service.UpdateFoodItem(c.Request.Context(), restaurantID, foodID, editorID, req)
+	if respondMenuError(c, err){
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"item": item})
+}
+
+// SetPriceHandler serves PUT /api/restaurants/:id/items/:food_id/price with {"price": 4.75}.
+func (h * RestaurantHandlers) SetPriceHandler(c * gin.Context){
+	restaurantID, editorID, ok := staffEditor(c)
+	if !ok{
+		return
+	}
+	foodID, err := uuid.Parse(c.Param("food_id"))
+	if err != nil{
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid food ID"})
+		return
+	}
+	var req struct{
+		Price *money.Money `json:"price" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil{
+		c.JSON(http.StatusBadRequest, gin.H{"error": "price is required"})
+		return
+	}
+
+	item, err := h.service.SetPrice(c.Request.Context(), restaurantID, foodID, editorID, *req.Price)
+	if respondMenuError(c, err){
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"item": item})
+}
+
+// SetAvailabilityHandler serves PUT /api/restaurants/:id/items/:food_id/availability
+// with {"availability": false} to 86 an item.
+func (h * RestaurantHandlers) SetAvailabilityHandler(c * gin.Context){
+	restaurantID, editorID, ok := staffEditor(c)
+	if !ok{
+		return
+	}
+	foodID, err := uuid.Parse(c.Param("food_id"))
+	if err != nil{
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid food ID"})
+		return
+	}
+	var req struct{
+		Availability *bool `json:"availability" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil{
+		c.JSON(http.StatusBadRequest, gin.H{"error": "availability is required"})
+		return
+	}
+
+	item, err := h.service.SetAvailability(c.Request.Context(), restaurantID, foodID, editorID, *req.Availability)
+	if respondMenuError(c, err){
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"item": item})
+}
+
+// ArchiveFoodItemHandler serves POST /api/restaurants/:id/items/:food_id/archive.
+func (h * RestaurantHandlers) ArchiveFoodItemHandler(c * gin.Context){
+	restaurantID, editorID, ok := staffEditor(c)
+	if !ok{
+		return
+	}
+	foodID, err := uuid.Parse(c.Param("food_id"))
+	if err != nil{
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid food ID"})
+		return
+	}
+
+	item, err := h.service.ArchiveFoodItem(c.Request.Context(), restaurantID, foodID, editorID)
+	if respondMenuError(c, err){
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"item": item})
+}
+
+// ReorderFoodItemsHandler serves PUT /api/restaurants/:id/items/order with {"ids": [...]}.
+func (h * RestaurantHandlers) ReorderFoodItemsHandler(c * gin.Context){
+	restaurantID, editorID, ok := staffEditor(c)
+	if !ok{
+		return
+	}
+	var req ReorderRequest
+	if err := c.ShouldBindJSON(&req); err != nil{
+		c.JSON(http.StatusBadRequest, gin.H{"error": "ids is required"})
+		return
+	}
+
+	items, err := h.service.ReorderFoodItems(c.Request.Context(), restaurantID, editorID, req.IDs)
+	if respondMenuError(c, err){
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"items": items})
+}
+
+// ListMenuChangesHandler serves GET /api/restaurants/:id/menu/changes?entity_id=&limit=50.
+func (h * RestaurantHandlers) ListMenuChangesHandler(c * gin.Context){
+	restaurantID, _, ok := staffEditor(c)
+	if !ok{
+		return
+	}
+
+	var entityID *uuid.UUID
+	if v := c.Query("entity_id"); v != ""{
+		id, err := uuid.Parse(v)
+		if err != nil{
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid entity_id"})
+			return
+		}
+		entityID = &id
+	}
+
+	limit := 50
+	if v := c.Query("limit"); v != ""{
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > 200{
+			c.JSON(http.StatusBadRequest, gin.H{"error": "limit must be between 1 and 200"})
+			return
+		}
+		limit = n
+	}
+
+	changes, err := h.service.ListMenuChanges(c.Request.Context(), restaurantID, entityID, limit)
+	if err != nil{
+		log.Printf("failed to list menu changes %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to list menu changes"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"changes": changes, "count": len(changes)})
+}
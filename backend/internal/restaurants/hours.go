@@ -0,0 +1,344 @@
+package restaurants
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// DefaultCampusTimezone is used when CAMPUS_TIMEZONE is not set.
+const DefaultCampusTimezone = "America/New_York"
+
+// nextOpenLookahead is how far ahead next_open_at is searched for, long
+// enough to see past winter break.
+const nextOpenLookahead = 60 * 24 * time.Hour
+
+// LoadCampusTimezone reads CAMPUS_TIMEZONE, an IANA name like America/New_York.
+func LoadCampusTimezone() (*time.Location, error) {
+	name := os.Getenv("CAMPUS_TIMEZONE")
+	if name == "" {
+		name = DefaultCampusTimezone
+	}
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		return nil, fmt.Errorf("CAMPUS_TIMEZONE must be an IANA time zone like America/New_York, got %q", name)
+	}
+	return loc, nil
+}
+
+// Clock is a time of day in minutes after midnight, written as "15:04".
+type Clock int
+
+func parseClock(s string) (Clock, error) {
+	// Postgres TIME values come back as 15:04:05
+	parts := strings.Split(s, ":")
+	if len(parts) < 2 {
+		return 0, fmt.Errorf("invalid time of day %q", s)
+	}
+	h, err := strconv.Atoi(parts[0])
+	if err != nil || h < 0 || h > 23 {
+		return 0, fmt.Errorf("invalid time of day %q", s)
+	}
+	m, err := strconv.Atoi(parts[1])
+	if err != nil || m < 0 || m > 59 {
+		return 0, fmt.Errorf("invalid time of day %q", s)
+	}
+	return Clock(h*60 + m), nil
+}
+
+func (c Clock) String() string {
+	return fmt.Sprintf("%02d:%02d", int(c)/60, int(c)%60)
+}
+
+func (c Clock) MarshalText() ([]byte, error) {
+	return []byte(c.String()), nil
+}
+
+// WeeklyHours is one opening on a day of the week. ClosesAt at or before
+// OpensAt means closing after midnight.
+type WeeklyHours struct {
+	DayOfWeek time.Weekday `json:"day_of_week"`
+	OpensAt   Clock        `json:"opens_at"`
+	ClosesAt  Clock        `json:"closes_at"`
+}
+
+// HoursException overrides the weekly hours from StartsOn to EndsOn
+// inclusive, both dates as 2006-01-02. It either closes the restaurant or
+// replaces the day's hours with OpensAt-ClosesAt. A nil RestaurantID means
+// it applies campus wide, e.g. an academic break.
+type HoursException struct {
+	RestaurantID *uuid.UUID `json:"restaurant_id"`
+	StartsOn     string     `json:"starts_on"`
+	EndsOn       string     `json:"ends_on"`
+	Closed       bool       `json:"closed"`
+	OpensAt      *Clock     `json:"opens_at,omitempty"`
+	ClosesAt     *Clock     `json:"closes_at,omitempty"`
+	Reason       *string    `json:"reason,omitempty"`
+}
+
+// Schedule is everything that decides when a restaurant is open. Without
+// weekly hours it is open all day, every day, unless an exception says
+// otherwise. An exception for the restaurant itself takes precedence over
+// campus wide ones on the same date, so a cafe can open during a break.
+type Schedule struct {
+	Weekly     []WeeklyHours    `json:"weekly"`
+	Exceptions []HoursException `json:"exceptions"`
+}
+
+type opening struct {
+	start, end time.Time
+}
+
+// openings returns the openings that start on day's date, in day's location.
+func (s Schedule) openings(day time.Time) []opening {
+	date := day.Format(time.DateOnly)
+
+	hours, special := s.exceptionHours(date, true)
+	if !special {
+		hours, special = s.exceptionHours(date, false)
+	}
+	if !special && len(s.Weekly) == 0 {
+		// closing at the opening time runs to the same time the next day
+		hours = [][2]Clock{{0, 0}}
+	}
+	if !special {
+		for _, w := range s.Weekly {
+			if w.DayOfWeek == day.Weekday() {
+				hours = append(hours, [2]Clock{w.OpensAt, w.ClosesAt})
+			}
+		}
+	}
+
+	y, m, d := day.Date()
+	at := func(days int, c Clock) time.Time {
+		return time.Date(y, m, d+days, int(c)/60, int(c)%60, 0, 0, day.Location())
+	}
+	openings := make([]opening, 0, len(hours))
+	for _, h := range hours {
+		o := opening{start: at(0, h[0]), end: at(0, h[1])}
+		if h[1] <= h[0] {
+			o.end = at(1, h[1])
+		}
+		openings = append(openings, o)
+	}
+	sort.Slice(openings, func(i, j int) bool { return openings[i].start.Before(openings[j].start) })
+	return openings
+}
+
+// exceptionHours returns the hours that the restaurant's own exceptions, or
+// the campus wide ones when own is false, set for date. found is false if
+// none of them cover it; a closure leaves hours empty.
+func (s Schedule) exceptionHours(date string, own bool) (hours [][2]Clock, found bool) {
+	for _, e := range s.Exceptions {
+		if (e.RestaurantID != nil) != own || date < e.StartsOn || date > e.EndsOn {
+			continue
+		}
+		if e.Closed {
+			return nil, true
+		}
+		found = true
+		hours = append(hours, [2]Clock{*e.OpensAt, *e.ClosesAt})
+	}
+	return hours, found
+}
+
+// IsOpen reports whether the restaurant is open at t. t should be in the
+// campus time zone.
+func (s Schedule) IsOpen(t time.Time) bool {
+	// yesterday's hours matter for places open past midnight
+	for _, day := range []time.Time{t.AddDate(0, 0, -1), t} {
+		for _, o := range s.openings(day) {
+			if !t.Before(o.start) && t.Before(o.end) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// NextOpen returns when the restaurant next opens after t, or nil if it
+// doesn't open within the lookahead.
+func (s Schedule) NextOpen(t time.Time) *time.Time {
+	y, m, d := t.Date()
+	for i := 0; i <= int(nextOpenLookahead/(24*time.Hour)); i++ {
+		day := time.Date(y, m, d+i, 0, 0, 0, 0, t.Location())
+		for _, o := range s.openings(day) {
+			if o.start.After(t) {
+				return &o.start
+			}
+		}
+	}
+	return nil
+}
+
+// ClosedError is returned when ordering from a restaurant outside its hours.
+type ClosedError struct {
+	RestaurantName string
+	NextOpenAt     *time.Time
+}
+
+func (e *ClosedError) Error() string {
+	if e.NextOpenAt == nil {
+		return fmt.Sprintf("%s is closed", e.RestaurantName)
+	}
+	return fmt.Sprintf("%s is closed and opens again %s", e.RestaurantName, e.NextOpenAt.Format("Mon Jan 2 at 3:04 PM"))
+}
+
+// schedules holds loaded restaurant schedules apart from the campus wide
+// exceptions, which forRestaurant adds to each one.
+type schedules struct {
+	restaurants map[uuid.UUID]Schedule
+	campus      []HoursException
+}
+
+func (s schedules) forRestaurant(restaurantID uuid.UUID) Schedule {
+	schedule := s.restaurants[restaurantID]
+	schedule.Exceptions = append(append([]HoursException{}, schedule.Exceptions...), s.campus...)
+	if schedule.Weekly == nil {
+		schedule.Weekly = []WeeklyHours{}
+	}
+	return schedule
+}
+
+// loadSchedules returns the schedules of restaurantIDs, or of every
+// restaurant when restaurantIDs is nil. Only exceptions that can affect the
+// next lookahead period are loaded.
+func (s *RestaurantService) loadSchedules(ctx context.Context, now time.Time, restaurantIDs []uuid.UUID) (*schedules, error) {
+	loaded := &schedules{restaurants: map[uuid.UUID]Schedule{}}
+
+	rows, err := s.conn.Query(ctx, `
+		SELECT restaurant_id, day_of_week, opens_at::text, closes_at::text
+		FROM restaurant_hours
+		WHERE $1::uuid[] IS NULL OR restaurant_id = ANY($1)
+	`, restaurantIDs)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var restaurantID uuid.UUID
+		var w WeeklyHours
+		var opensAt, closesAt string
+		if err := rows.Scan(&restaurantID, &w.DayOfWeek, &opensAt, &closesAt); err != nil {
+			return nil, err
+		}
+		if w.OpensAt, err = parseClock(opensAt); err != nil {
+			return nil, err
+		}
+		if w.ClosesAt, err = parseClock(closesAt); err != nil {
+			return nil, err
+		}
+		schedule := loaded.restaurants[restaurantID]
+		schedule.Weekly = append(schedule.Weekly, w)
+		loaded.restaurants[restaurantID] = schedule
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	exceptions, err := s.loadExceptions(ctx, now, restaurantIDs)
+	if err != nil {
+		return nil, err
+	}
+	for _, e := range exceptions {
+		if e.RestaurantID == nil {
+			loaded.campus = append(loaded.campus, e)
+			continue
+		}
+		schedule := loaded.restaurants[*e.RestaurantID]
+		schedule.Exceptions = append(schedule.Exceptions, e)
+		loaded.restaurants[*e.RestaurantID] = schedule
+	}
+
+	return loaded, nil
+}
+
+func (s *RestaurantService) loadExceptions(ctx context.Context, now time.Time, restaurantIDs []uuid.UUID) ([]HoursException, error) {
+	from := now.AddDate(0, 0, -1).Format(time.DateOnly)
+	to := now.Add(nextOpenLookahead).AddDate(0, 0, 1).Format(time.DateOnly)
+
+	rows, err := s.conn.Query(ctx, `
+		SELECT restaurant_id, starts_on::text, ends_on::text, closed, opens_at::text, closes_at::text, reason
+		FROM restaurant_hour_exceptions
+		WHERE ends_on >= $1::date AND starts_on <= $2::date
+			AND (restaurant_id IS NULL OR $3::uuid[] IS NULL OR restaurant_id = ANY($3))
+		ORDER BY starts_on
+	`, from, to, restaurantIDs)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	exceptions := []HoursException{}
+	for rows.Next() {
+		var e HoursException
+		var opensAt, closesAt *string
+		if err := rows.Scan(&e.RestaurantID, &e.StartsOn, &e.EndsOn, &e.Closed, &opensAt, &closesAt, &e.Reason); err != nil {
+			return nil, err
+		}
+		if opensAt != nil && closesAt != nil {
+			o, err := parseClock(*opensAt)
+			if err != nil {
+				return nil, err
+			}
+			c, err := parseClock(*closesAt)
+			if err != nil {
+				return nil, err
+			}
+			e.OpensAt, e.ClosesAt = &o, &c
+		}
+		exceptions = append(exceptions, e)
+	}
+	return exceptions, rows.Err()
+}
+
+// setOpenStatus fills in IsOpenNow and NextOpenAt from the restaurants' schedules.
+func (s *RestaurantService) setOpenStatus(ctx context.Context, restaurants []Restaurant) error {
+	now := s.now().In(s.campus)
+	var ids []uuid.UUID
+	if len(restaurants) == 1 {
+		ids = []uuid.UUID{restaurants[0].RestaurantID}
+	}
+	schedules, err := s.loadSchedules(ctx, now, ids)
+	if err != nil {
+		return fmt.Errorf("failed to load opening hours: %v", err)
+	}
+
+	for i := range restaurants {
+		schedule := schedules.forRestaurant(restaurants[i].RestaurantID)
+		restaurants[i].IsOpenNow = schedule.IsOpen(now)
+		if !restaurants[i].IsOpenNow {
+			restaurants[i].NextOpenAt = schedule.NextOpen(now)
+		}
+	}
+	return nil
+}
+
+// GetSchedule returns a restaurant's weekly hours and upcoming exceptions.
+func (s *RestaurantService) GetSchedule(ctx context.Context, restaurantID uuid.UUID) (*Schedule, error) {
+	schedules, err := s.loadSchedules(ctx, s.now().In(s.campus), []uuid.UUID{restaurantID})
+	if err != nil {
+		return nil, err
+	}
+	schedule := schedules.forRestaurant(restaurantID)
+	return &schedule, nil
+}
+
+// CheckOpen returns a *ClosedError if restaurantID is closed right now.
+func (s *RestaurantService) CheckOpen(ctx context.Context, restaurantID uuid.UUID) error {
+	restaurant, err := s.GetRestaurantsByID(ctx, restaurantID)
+	if err != nil {
+		return err
+	}
+	if !restaurant.IsOpenNow {
+		return &ClosedError{RestaurantName: restaurant.RestaurantName, NextOpenAt: restaurant.NextOpenAt}
+	}
+	return nil
+}
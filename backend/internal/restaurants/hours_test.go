@@ -0,0 +1,88 @@
+package restaurants
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func clock(t *testing.T, s string) Clock {
+	t.Helper()
+	c, err := parseClock(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return c
+}
+
+func TestScheduleIsOpen(t *testing.T) {
+	campus, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Skip("time zone data is not available")
+	}
+	at := func(s string) time.Time {
+		v, err := time.ParseInLocation("2006-01-02 15:04", s, campus)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return v
+	}
+	restaurantID := uuid.New()
+	lateOpens, lateCloses := clock(t, "12:00"), clock(t, "16:00")
+
+	// Mondays 11:00-14:00 and 17:00-01:00
+	weekly := []WeeklyHours{
+		{DayOfWeek: time.Monday, OpensAt: clock(t, "11:00"), ClosesAt: clock(t, "14:00")},
+		{DayOfWeek: time.Monday, OpensAt: clock(t, "17:00"), ClosesAt: clock(t, "01:00")},
+	}
+	winterBreak := HoursException{StartsOn: "2026-12-20", EndsOn: "2027-01-10", Closed: true}
+	openOverBreak := HoursException{RestaurantID: &restaurantID, StartsOn: "2026-12-21", EndsOn: "2026-12-21",
+		OpensAt: &lateOpens, ClosesAt: &lateCloses}
+	closedForRepairs := HoursException{RestaurantID: &restaurantID, StartsOn: "2026-10-26", EndsOn: "2026-10-26", Closed: true}
+
+	tests := []struct {
+		name     string
+		schedule Schedule
+		at       string
+		want     bool
+	}{
+		{"lunch", Schedule{Weekly: weekly}, "2026-10-19 12:30", true},
+		{"between lunch and dinner", Schedule{Weekly: weekly}, "2026-10-19 15:00", false},
+		{"after midnight", Schedule{Weekly: weekly}, "2026-10-20 00:30", true},
+		{"after closing", Schedule{Weekly: weekly}, "2026-10-20 01:00", false},
+		{"other day", Schedule{Weekly: weekly}, "2026-10-21 12:30", false},
+		{"no hours set", Schedule{Weekly: []WeeklyHours{}}, "2026-10-21 03:00", true},
+		{"no hours set, campus closed", Schedule{Exceptions: []HoursException{winterBreak}}, "2026-12-22 12:00", false},
+		{"restaurant closure", Schedule{Weekly: weekly, Exceptions: []HoursException{closedForRepairs}}, "2026-10-26 12:30", false},
+		{"campus closure", Schedule{Weekly: weekly, Exceptions: []HoursException{winterBreak}}, "2026-12-21 12:30", false},
+		{"open during a campus closure", Schedule{Weekly: weekly, Exceptions: []HoursException{winterBreak, openOverBreak}},
+			"2026-12-21 15:00", true},
+		{"special hours replace weekly ones", Schedule{Weekly: weekly, Exceptions: []HoursException{winterBreak, openOverBreak}},
+			"2026-12-21 11:30", false},
+	}
+	for _, tt := range tests {
+		if got := tt.schedule.IsOpen(at(tt.at)); got != tt.want {
+			t.Errorf("%s: IsOpen(%s) = %t, want %t", tt.name, tt.at, got, tt.want)
+		}
+	}
+
+	// the campus closure ends on the 10th, a Sunday
+	schedule := Schedule{Weekly: weekly, Exceptions: []HoursException{winterBreak}}
+	next := schedule.NextOpen(at("2026-12-21 12:30"))
+	if next == nil || !next.Equal(at("2027-01-11 11:00")) {
+		t.Errorf("NextOpen = %v, want 2027-01-11 11:00", next)
+	}
+}
+
+func TestForRestaurantKeepsCampusExceptions(t *testing.T) {
+	restaurantID := uuid.New()
+	loaded := schedules{
+		restaurants: map[uuid.UUID]Schedule{},
+		campus:      []HoursException{{StartsOn: "2026-12-20", EndsOn: "2027-01-10", Closed: true}},
+	}
+	schedule := loaded.forRestaurant(restaurantID)
+	if schedule.Weekly == nil || len(schedule.Exceptions) != 1 {
+		t.Errorf("schedule = %+v, want no weekly hours and the campus closure", schedule)
+	}
+}
@@ -0,0 +1,567 @@
+package restaurants
+
+import (
+	"campusDoordash/internal/money"
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+)
+
+const (
+	maxMenuNameLength    = 100
+	maxDescriptionLength = 500
+)
+
+var (
+	ErrFoodNotFound     = errors.New("food item not found")
+	ErrCategoryNotFound = errors.New("category not found")
+	ErrCategoryNotEmpty = errors.New("move or archive the items in this category before archiving it")
+	// ErrInvalidMenuEdit is wrapped by every error caused by the edit itself
+	// rather than by the database.
+	ErrInvalidMenuEdit = errors.New("invalid menu edit")
+)
+
+type Category struct {
+	CategoryID   uuid.UUID  `json:"category_id"`
+	RestaurantID uuid.UUID  `json:"restaurant_id"`
+	Name         string     `json:"name"`
+	DisplayOrder int        `json:"display_order"`
+	ArchivedAt   *time.Time `json:"archived_at,omitempty"`
+	UpdatedAt    time.Time  `json:"updated_at"`
+}
+
+// ManagedFoodItem is a food item as staff see it, archived or not.
+type ManagedFoodItem struct {
+	FoodItem
+	Description  *string    `json:"description"`
+	DisplayOrder int        `json:"display_order"`
+	ArchivedAt   *time.Time `json:"archived_at,omitempty"`
+	UpdatedAt    time.Time  `json:"updated_at"`
+}
+
+type CategoryRequest struct {
+	Name string `json:"name" binding:"required"`
+}
+
+type CreateFoodItemRequest struct {
+	FoodName     string       `json:"food_name" binding:"required"`
+	CategoryID   *uuid.UUID   `json:"category_id"`
+	Price        *money.Money `json:"price" binding:"required"`
+	Description  string       `json:"description"`
+	Availability *bool        `json:"availability"`
+}
+
+// UpdateFoodItemRequest changes the fields that are set. An empty
+// description clears it. Price and availability have their own endpoints.
+type UpdateFoodItemRequest struct {
+	FoodName    *string    `json:"food_name"`
+	CategoryID  *uuid.UUID `json:"category_id"`
+	Description *string    `json:"description"`
+}
+
+type ReorderRequest struct {
+	IDs []uuid.UUID `json:"ids" binding:"required"`
+}
+
+func validName(name string) (string, error) {
+	name = strings.TrimSpace(name)
+	if name == "" || len(name) > maxMenuNameLength {
+		return "", fmt.Errorf("%w: name is required and must be at most %d characters", ErrInvalidMenuEdit, maxMenuNameLength)
+	}
+	return name, nil
+}
+
+func validDescription(description string) (*string, error) {
+	description = strings.TrimSpace(description)
+	if len(description) > maxDescriptionLength {
+		return nil, fmt.Errorf("%w: description must be at most %d characters", ErrInvalidMenuEdit, maxDescriptionLength)
+	}
+	if description == "" {
+		return nil, nil
+	}
+	return &description, nil
+}
+
+func validPrice(price money.Money) error {
+	if price.IsNegative() {
+		return fmt.Errorf("%w: price can't be negative", ErrInvalidMenuEdit)
+	}
+	return nil
+}
+
+const categoryColumns = `category_id, restaurant_id, category_name, display_order, archived_at, updated_at`
+
+func scanCategory(row pgx.Row) (Category, error) {
+	var c Category
+	err := row.Scan(&c.CategoryID, &c.RestaurantID, &c.Name, &c.DisplayOrder, &c.ArchivedAt, &c.UpdatedAt)
+	return c, err
+}
+
+const foodColumns = `food_id, restaurant_id, category_id, food_name, price, availability,
+	description, display_order, archived_at, updated_at`
+
+func scanFood(row pgx.Row) (ManagedFoodItem, error) {
+	var f ManagedFoodItem
+	err := row.Scan(
+		&f.FoodID,
+		&f.RestaurantID,
+		&f.CategoryID,
+		&f.FoodName,
+		&f.Price,
+		&f.Availability,
+		&f.Description,
+		&f.DisplayOrder,
+		&f.ArchivedAt,
+		&f.UpdatedAt,
+	)
+	return f, err
+}
+
+// ListCategories returns a restaurant's categories in display order.
+func (s *RestaurantService) ListCategories(ctx context.Context, restaurantID uuid.UUID, includeArchived bool) ([]Category, error) {
+	rows, err := s.conn.Query(ctx, `
+		SELECT `+categoryColumns+`
+		FROM categories
+		WHERE restaurant_id = $1 AND ($2 OR archived_at IS NULL)
+		ORDER BY display_order, category_name
+	`, restaurantID, includeArchived)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	categories := []Category{}
+	for rows.Next() {
+		c, err := scanCategory(rows)
+		if err != nil {
+			return nil, err
+		}
+		categories = append(categories, c)
+	}
+	return categories, rows.Err()
+}
+
+// ListFoodItems returns every item at a restaurant, unavailable ones
+// included, in display order.
+func (s *RestaurantService) ListFoodItems(ctx context.Context, restaurantID uuid.UUID, includeArchived bool) ([]ManagedFoodItem, error) {
+	rows, err := s.conn.Query(ctx, `
+		SELECT `+foodColumns+`
+		FROM food
+		WHERE restaurant_id = $1 AND ($2 OR archived_at IS NULL)
+		ORDER BY display_order, food_name
+	`, restaurantID, includeArchived)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	items := []ManagedFoodItem{}
+	for rows.Next() {
+		f, err := scanFood(rows)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, f)
+	}
+	return items, rows.Err()
+}
+
+func (s *RestaurantService) CreateCategory(ctx context.Context, restaurantID, editorID uuid.UUID, req CategoryRequest) (*Category, error) {
+	name, err := validName(req.Name)
+	if err != nil {
+		return nil, err
+	}
+
+	tx, err := s.conn.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback(ctx)
+
+	// new categories go at the end of the menu
+	c, err := scanCategory(tx.QueryRow(ctx, `
+		INSERT INTO categories (category_id, restaurant_id, category_name, display_order)
+		SELECT $1, $2, $3, COALESCE(MAX(display_order) + 1, 0) FROM categories WHERE restaurant_id = $2
+		RETURNING `+categoryColumns,
+		uuid.New(), restaurantID, name))
+	if err != nil {
+		return nil, err
+	}
+
+	changes := changeSet{"name": {To: c.Name}, "display_order": {To: c.DisplayOrder}}
+	if err := RecordMenuChange(ctx, tx, restaurantID, EntityCategory, c.CategoryID, ActionCreate, changes, editorID); err != nil {
+		return nil, fmt.Errorf("failed to record menu change: %v", err)
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return nil, err
+	}
+	return &c, nil
+}
+
+// lockCategory loads an unarchived category of restaurantID for update.
+func lockCategory(ctx context.Context, tx pgx.Tx, restaurantID, categoryID uuid.UUID) (Category, error) {
+	c, err := scanCategory(tx.QueryRow(ctx, `
+		SELECT `+categoryColumns+`
+		FROM categories
+		WHERE category_id = $1 AND restaurant_id = $2 AND archived_at IS NULL
+		FOR UPDATE
+	`, categoryID, restaurantID))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return c, ErrCategoryNotFound
+	}
+	return c, err
+}
+
+func (s *RestaurantService) RenameCategory(ctx context.Context, restaurantID, categoryID, editorID uuid.UUID, req CategoryRequest) (*Category, error) {
+	name, err := validName(req.Name)
+	if err != nil {
+		return nil, err
+	}
+
+	tx, err := s.conn.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback(ctx)
+
+	before, err := lockCategory(ctx, tx, restaurantID, categoryID)
+	if err != nil {
+		return nil, err
+	}
+	c, err := scanCategory(tx.QueryRow(ctx, `
+		UPDATE categories SET category_name = $1, updated_at = NOW()
+		WHERE category_id = $2
+		RETURNING `+categoryColumns,
+		name, categoryID))
+	if err != nil {
+		return nil, err
+	}
+
+	changes := changeSet{}
+	changes.add("name", before.Name, c.Name)
+	if err := RecordMenuChange(ctx, tx, restaurantID, EntityCategory, categoryID, ActionUpdate, changes, editorID); err != nil {
+		return nil, fmt.Errorf("failed to record menu change: %v", err)
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return nil, err
+	}
+	return &c, nil
+}
+
+// ArchiveCategory hides an empty category. Categories that still hold
+// unarchived items fail with ErrCategoryNotEmpty.
+func (s *RestaurantService) ArchiveCategory(ctx context.Context, restaurantID, categoryID, editorID uuid.UUID) (*Category, error) {
+	tx, err := s.conn.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback(ctx)
+
+	if _, err := lockCategory(ctx, tx, restaurantID, categoryID); err != nil {
+		return nil, err
+	}
+	var items int
+	err = tx.QueryRow(ctx, `
+		SELECT COUNT(*) FROM food WHERE category_id = $1 AND archived_at IS NULL
+	`, categoryID).Scan(&items)
+	if err != nil {
+		return nil, err
+	}
+	if items > 0 {
+		return nil, ErrCategoryNotEmpty
+	}
+
+	c, err := scanCategory(tx.QueryRow(ctx, `
+		UPDATE categories SET archived_at = NOW(), updated_at = NOW()
+		WHERE category_id = $1
+		RETURNING `+categoryColumns,
+		categoryID))
+	if err != nil {
+		return nil, err
+	}
+
+	changes := changeSet{"archived_at": {From: nil, To: c.ArchivedAt}}
+	if err := RecordMenuChange(ctx, tx, restaurantID, EntityCategory, categoryID, ActionArchive, changes, editorID); err != nil {
+		return nil, fmt.Errorf("failed to record menu change: %v", err)
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return nil, err
+	}
+	return &c, nil
+}
+
+// ReorderCategories gives the categories in ids display orders 0, 1, 2...
+// in that order. Every ID must be an unarchived category of restaurantID.
+func (s *RestaurantService) ReorderCategories(ctx context.Context, restaurantID, editorID uuid.UUID, ids []uuid.UUID) ([]Category, error) {
+	if err := s.reorder(ctx, restaurantID, editorID, EntityCategory, ids); err != nil {
+		return nil, err
+	}
+	return s.ListCategories(ctx, restaurantID, false)
+}
+
+// ReorderFoodItems gives the items in ids display orders 0, 1, 2... in that
+// order, usually the items of one category. Every ID must be an unarchived
+// item of restaurantID.
+func (s *RestaurantService) ReorderFoodItems(ctx context.Context, restaurantID, editorID uuid.UUID, ids []uuid.UUID) ([]ManagedFoodItem, error) {
+	if err := s.reorder(ctx, restaurantID, editorID, EntityFood, ids); err != nil {
+		return nil, err
+	}
+	return s.ListFoodItems(ctx, restaurantID, false)
+}
+
+func (s *RestaurantService) reorder(ctx context.Context, restaurantID, editorID uuid.UUID, entity MenuEntity, ids []uuid.UUID) error {
+	seen := make(map[uuid.UUID]bool, len(ids))
+	for _, id := range ids {
+		if seen[id] {
+			return fmt.Errorf("%w: %s is listed twice", ErrInvalidMenuEdit, id)
+		}
+		seen[id] = true
+	}
+
+	table, idColumn, notFound := "food", "food_id", ErrFoodNotFound
+	if entity == EntityCategory {
+		table, idColumn, notFound = "categories", "category_id", ErrCategoryNotFound
+	}
+
+	tx, err := s.conn.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	rows, err := tx.Query(ctx, `
+		SELECT `+idColumn+`, display_order FROM `+table+`
+		WHERE `+idColumn+` = ANY($1) AND restaurant_id = $2 AND archived_at IS NULL
+		FOR UPDATE
+	`, ids, restaurantID)
+	if err != nil {
+		return err
+	}
+	before := make(map[uuid.UUID]int, len(ids))
+	for rows.Next() {
+		var id uuid.UUID
+		var order int
+		if err := rows.Scan(&id, &order); err != nil {
+			rows.Close()
+			return err
+		}
+		before[id] = order
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	if len(before) != len(ids) {
+		return notFound
+	}
+
+	for i, id := range ids {
+		if before[id] == i {
+			continue
+		}
+		if _, err := tx.Exec(ctx, `
+			UPDATE `+table+` SET display_order = $1, updated_at = NOW() WHERE `+idColumn+` = $2
+		`, i, id); err != nil {
+			return err
+		}
+		changes := changeSet{"display_order": {From: before[id], To: i}}
+		if err := RecordMenuChange(ctx, tx, restaurantID, entity, id, ActionReorder, changes, editorID); err != nil {
+			return fmt.Errorf("failed to record menu change: %v", err)
+		}
+	}
+	return tx.Commit(ctx)
+}
+
+// checkCategory makes sure categoryID is an unarchived category of restaurantID.
+func checkCategory(ctx context.Context, tx pgx.Tx, restaurantID, categoryID uuid.UUID) error {
+	var exists bool
+	err := tx.QueryRow(ctx, `
+		SELECT EXISTS (
+			SELECT 1 FROM categories WHERE category_id = $1 AND restaurant_id = $2 AND archived_at IS NULL
+		)
+	`, categoryID, restaurantID).Scan(&exists)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return ErrCategoryNotFound
+	}
+	return nil
+}
+
+func (s *RestaurantService) CreateFoodItem(ctx context.Context, restaurantID, editorID uuid.UUID, req CreateFoodItemRequest) (*ManagedFoodItem, error) {
+	name, err := validName(req.FoodName)
+	if err != nil {
+		return nil, err
+	}
+	description, err := validDescription(req.Description)
+	if err != nil {
+		return nil, err
+	}
+	if err := validPrice(*req.Price); err != nil {
+		return nil, err
+	}
+	available := req.Availability == nil || *req.Availability
+
+	tx, err := s.conn.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback(ctx)
+
+	if req.CategoryID != nil {
+		if err := checkCategory(ctx, tx, restaurantID, *req.CategoryID); err != nil {
+			return nil, err
+		}
+	}
+
+	f, err := scanFood(tx.QueryRow(ctx, `
+		INSERT INTO food (food_id, restaurant_id, category_id, food_name, price, availability, description, display_order)
+		SELECT $1, $2, $3, $4, $5, $6, $7, COALESCE(MAX(display_order) + 1, 0) FROM food WHERE restaurant_id = $2
+		RETURNING `+foodColumns,
+		uuid.New(), restaurantID, req.CategoryID, name, *req.Price, available, description))
+	if err != nil {
+		return nil, err
+	}
+
+	changes := changeSet{
+		"food_name":    {To: f.FoodName},
+		"category_id":  {To: f.CategoryID},
+		"price":        {To: f.Price},
+		"availability": {To: f.Availability},
+		"description":  {To: f.Description},
+	}
+	if err := RecordMenuChange(ctx, tx, restaurantID, EntityFood, f.FoodID, ActionCreate, changes, editorID); err != nil {
+		return nil, fmt.Errorf("failed to record menu change: %v", err)
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return nil, err
+	}
+	return &f, nil
+}
+
+// lockFood loads an unarchived item of restaurantID for update.
+func lockFood(ctx context.Context, tx pgx.Tx, restaurantID, foodID uuid.UUID) (ManagedFoodItem, error) {
+	f, err := scanFood(tx.QueryRow(ctx, `
+		SELECT `+foodColumns+`
+		FROM food
+		WHERE food_id = $1 AND restaurant_id = $2 AND archived_at IS NULL
+		FOR UPDATE
+	`, foodID, restaurantID))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return f, ErrFoodNotFound
+	}
+	return f, err
+}
+
+// editFood locks an item, lets edit change it, saves the result and audits
+// whatever changed under action.
+func (s *RestaurantService) editFood(ctx context.Context, restaurantID, foodID, editorID uuid.UUID, action MenuAction, edit func(tx pgx.Tx, f *ManagedFoodItem) error) (*ManagedFoodItem, error) {
+	tx, err := s.conn.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback(ctx)
+
+	before, err := lockFood(ctx, tx, restaurantID, foodID)
+	if err != nil {
+		return nil, err
+	}
+	after := before
+	if err := edit(tx, &after); err != nil {
+		return nil, err
+	}
+
+	changes := changeSet{}
+	changes.add("food_name", before.FoodName, after.FoodName)
+	changes.add("category_id", before.CategoryID, after.CategoryID)
+	changes.add("description", before.Description, after.Description)
+	changes.add("price", before.Price, after.Price)
+	changes.add("availability", before.Availability, after.Availability)
+	changes.add("archived_at", before.ArchivedAt, after.ArchivedAt)
+	if len(changes) == 0 {
+		return &before, nil
+	}
+
+	f, err := scanFood(tx.QueryRow(ctx, `
+		UPDATE food
+		SET food_name = $1, category_id = $2, description = $3, price = $4, availability = $5,
+			archived_at = $6, updated_at = NOW()
+		WHERE food_id = $7
+		RETURNING `+foodColumns,
+		after.FoodName, after.CategoryID, after.Description, after.Price, after.Availability,
+		after.ArchivedAt, foodID))
+	if err != nil {
+		return nil, err
+	}
+
+	if err := RecordMenuChange(ctx, tx, restaurantID, EntityFood, foodID, action, changes, editorID); err != nil {
+		return nil, fmt.Errorf("failed to record menu change: %v", err)
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return nil, err
+	}
+	return &f, nil
+}
+
+func (s *RestaurantService) UpdateFoodItem(ctx context.Context, restaurantID, foodID, editorID uuid.UUID, req UpdateFoodItemRequest) (*ManagedFoodItem, error) {
+	return s.editFood(ctx, restaurantID, foodID, editorID, ActionUpdate, func(tx pgx.Tx, f *ManagedFoodItem) error {
+		if req.FoodName != nil {
+			name, err := validName(*req.FoodName)
+			if err != nil {
+				return err
+			}
+			f.FoodName = name
+		}
+		if req.Description != nil {
+			description, err := validDescription(*req.Description)
+			if err != nil {
+				return err
+			}
+			f.Description = description
+		}
+		if req.CategoryID != nil {
+			if err := checkCategory(ctx, tx, restaurantID, *req.CategoryID); err != nil {
+				return err
+			}
+			f.CategoryID = req.CategoryID
+		}
+		return nil
+	})
+}
+
+// SetPrice changes an item's base price. Orders already placed keep the
+// price they were charged.
+func (s *RestaurantService) SetPrice(ctx context.Context, restaurantID, foodID, editorID uuid.UUID, price money.Money) (*ManagedFoodItem, error) {
+	if err := validPrice(price); err != nil {
+		return nil, err
+	}
+	return s.editFood(ctx, restaurantID, foodID, editorID, ActionPrice, func(tx pgx.Tx, f *ManagedFoodItem) error {
+		f.Price = &price
+		return nil
+	})
+}
+
+// SetAvailability takes an item off the menu when it runs out (86s it) or
+// puts it back.
+func (s *RestaurantService) SetAvailability(ctx context.Context, restaurantID, foodID, editorID uuid.UUID, available bool) (*ManagedFoodItem, error) {
+	return s.editFood(ctx, restaurantID, foodID, editorID, ActionAvailability, func(tx pgx.Tx, f *ManagedFoodItem) error {
+		f.Availability = available
+		return nil
+	})
+}
+
+// ArchiveFoodItem removes an item from the menu for good. It stays in the
+// database so past orders still resolve.
+func (s *RestaurantService) ArchiveFoodItem(ctx context.Context, restaurantID, foodID, editorID uuid.UUID) (*ManagedFoodItem, error) {
+	return s.editFood(ctx, restaurantID, foodID, editorID, ActionArchive, func(tx pgx.Tx, f *ManagedFoodItem) error {
+		now := time.Now()
+		f.ArchivedAt = &now
+		return nil
+	})
+}
@@ -0,0 +1,194 @@
+package restaurants
+
+import (
+	"campusDoordash/internal/money"
+	"context"
+
+	"github.com/google/uuid"
+)
+
+// Menu is a restaurant's available items grouped into categories, both in
+// display order. Items without a category come last, in a category with no ID.
+type Menu struct {
+	RestaurantID uuid.UUID      `json:"restaurant_id"`
+	Categories   []MenuCategory `json:"categories"`
+}
+
+type MenuCategory struct {
+	CategoryID   *uuid.UUID `json:"category_id"`
+	Name         string     `json:"name"`
+	DisplayOrder int        `json:"display_order"`
+	Items        []MenuItem `json:"items"`
+}
+
+type MenuItem struct {
+	FoodItem
+	Description    *string         `json:"description,omitempty"`
+	DisplayOrder   int             `json:"display_order"`
+	ModifierGroups []ModifierGroup `json:"modifier_groups"`
+}
+
+// ModifierGroup is a choice on an item, like size or bread type. Customers
+// pick between MinSelections and MaxSelections of its options.
+type ModifierGroup struct {
+	ModifierGroupID uuid.UUID        `json:"modifier_group_id"`
+	FoodID          uuid.UUID        `json:"food_id"`
+	Name            string           `json:"name"`
+	MinSelections   int              `json:"min_selections"`
+	MaxSelections   int              `json:"max_selections"`
+	Required        bool             `json:"required"`
+	DisplayOrder    int              `json:"display_order"`
+	Options         []ModifierOption `json:"options"`
+}
+
+type ModifierOption struct {
+	ModifierOptionID uuid.UUID   `json:"modifier_option_id"`
+	Name             string      `json:"name"`
+	PriceDelta       money.Money `json:"price_delta"`
+	Available        bool        `json:"available"`
+	DisplayOrder     int         `json:"display_order"`
+}
+
+// uncategorized names the category holding items without one.
+const uncategorized = "Other"
+
+// GetRestaurantMenu returns the restaurant's available items by category,
+// with their modifier groups and available options.
+func (s *RestaurantService) GetRestaurantMenu(ctx context.Context, restaurantID uuid.UUID) (*Menu, error) {
+	rows, err := s.conn.Query(ctx, `
+		SELECT f.food_id, f.restaurant_id, f.category_id, f.food_name, f.price, f.availability,
+			f.description, f.display_order, c.category_name, COALESCE(c.display_order, 0)
+		FROM food f
+		LEFT JOIN categories c ON c.category_id = f.category_id
+		WHERE f.restaurant_id = $1 AND f.availability = true AND f.archived_at IS NULL
+		ORDER BY c.category_id IS NULL, c.display_order, c.category_name, f.display_order, f.food_name
+	`, restaurantID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	menu := Menu{RestaurantID: restaurantID, Categories: []MenuCategory{}}
+	var foodIDs []uuid.UUID
+	for rows.Next() {
+		var item MenuItem
+		var categoryName *string
+		var categoryOrder int
+		err := rows.Scan(
+			&item.FoodID, &item.RestaurantID, &item.CategoryID, &item.FoodName, &item.Price, &item.Availability,
+			&item.Description, &item.DisplayOrder, &categoryName, &categoryOrder,
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		// a category_id pointing at a missing category is treated as none
+		if categoryName == nil {
+			item.CategoryID = nil
+		}
+		n := len(menu.Categories)
+		if n == 0 || !sameCategory(menu.Categories[n-1].CategoryID, item.CategoryID) {
+			category := MenuCategory{CategoryID: item.CategoryID, Name: uncategorized, DisplayOrder: categoryOrder}
+			if categoryName != nil {
+				category.Name = *categoryName
+			}
+			menu.Categories = append(menu.Categories, category)
+			n++
+		}
+		menu.Categories[n-1].Items = append(menu.Categories[n-1].Items, item)
+		foodIDs = append(foodIDs, item.FoodID)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	groups, err := s.GetModifierGroups(ctx, foodIDs)
+	if err != nil {
+		return nil, err
+	}
+	for i := range menu.Categories {
+		for j := range menu.Categories[i].Items {
+			item := &menu.Categories[i].Items[j]
+			item.ModifierGroups = availableOptions(groups[item.FoodID])
+		}
+	}
+
+	return &menu, nil
+}
+
+func sameCategory(a, b *uuid.UUID) bool {
+	if a == nil || b == nil {
+		return a == nil && b == nil
+	}
+	return *a == *b
+}
+
+// availableOptions drops options that are sold out. Groups are kept even if
+// that leaves them empty, so a required group still blocks ordering.
+func availableOptions(groups []ModifierGroup) []ModifierGroup {
+	out := make([]ModifierGroup, 0, len(groups))
+	for _, g := range groups {
+		options := []ModifierOption{}
+		for _, o := range g.Options {
+			if o.Available {
+				options = append(options, o)
+			}
+		}
+		g.Options = options
+		out = append(out, g)
+	}
+	return out
+}
+
+// GetModifierGroups returns the modifier groups of each of foodIDs, including
+// unavailable options, in display order.
+func (s *RestaurantService) GetModifierGroups(ctx context.Context, foodIDs []uuid.UUID) (map[uuid.UUID][]ModifierGroup, error) {
+	rows, err := s.conn.Query(ctx, `
+		SELECT g.modifier_group_id, g.food_id, g.name, g.min_selections, g.max_selections, g.display_order,
+			o.modifier_option_id, o.name, o.price_delta, o.available, o.display_order
+		FROM modifier_groups g
+		LEFT JOIN modifier_options o ON o.modifier_group_id = g.modifier_group_id
+		WHERE g.food_id = ANY($1)
+		ORDER BY g.food_id, g.display_order, g.name, g.modifier_group_id, o.display_order, o.name
+	`, foodIDs)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	groups := map[uuid.UUID][]ModifierGroup{}
+	for rows.Next() {
+		var g ModifierGroup
+		var optionID *uuid.UUID
+		var optionName *string
+		var priceDelta *money.Money
+		var available *bool
+		var optionOrder *int
+		err := rows.Scan(
+			&g.ModifierGroupID, &g.FoodID, &g.Name, &g.MinSelections, &g.MaxSelections, &g.DisplayOrder,
+			&optionID, &optionName, &priceDelta, &available, &optionOrder,
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		foodGroups := groups[g.FoodID]
+		if n := len(foodGroups); n == 0 || foodGroups[n-1].ModifierGroupID != g.ModifierGroupID {
+			g.Required = g.MinSelections > 0
+			g.Options = []ModifierOption{}
+			foodGroups = append(foodGroups, g)
+		}
+		if optionID != nil {
+			last := &foodGroups[len(foodGroups)-1]
+			last.Options = append(last.Options, ModifierOption{
+				ModifierOptionID: *optionID,
+				Name:             *optionName,
+				PriceDelta:       *priceDelta,
+				Available:        *available,
+				DisplayOrder:     *optionOrder,
+			})
+		}
+		groups[g.FoodID] = foodGroups
+	}
+	return groups, rows.Err()
+}
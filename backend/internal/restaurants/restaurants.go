@@ -2,8 +2,9 @@
 package restaurants
 
 import (
+	"campusDoordash/internal/money"
 	"context"
-	"log"
+	"time"
 
 	"github.com/google/uuid"
 	_ "github.com/jackc/pgx/v5"
@@ -14,6 +15,9 @@ type Restaurant struct {
 	RestaurantID uuid.UUID `json:"restaurant_id" db:"restaurant_id"`
 	RestaurantName string `json:"restaurant_name" db:"restaurant_name"`
 	LocationID *uuid.UUID `json:"location_id,omitempty" db:"location_id"`
+	// IsOpenNow and NextOpenAt are worked out from the schedule in the campus time zone
+	IsOpenNow bool `json:"is_open_now"`
+	NextOpenAt *time.Time `json:"next_open_at"`
 }
 
 type FoodItem struct{
@@ -21,16 +25,18 @@ type FoodItem struct{
 	RestaurantID  *uuid.UUID `json:"restaurant_id" db:"restaurant_id"`
 	CategoryID *uuid.UUID `json:"category_id" db:"category_id"`
 	FoodName string `json:"food_name" db:"food_name"`
-	Price *float64 `json:"price" db:"price"`
+	Price *money.Money `json:"price" db:"price"`
 	Availability bool `json:"availability" db:"availability"`
 }
 
 type RestaurantService struct{
 	conn *pgxpool.Pool
+	campus *time.Location
+	now func() time.Time
 }
 
-func NewRestaurantService(conn * pgxpool.Pool) *RestaurantService{ 
-	return &RestaurantService{conn: conn}	
+func NewRestaurantService(conn * pgxpool.Pool, campus *time.Location) *RestaurantService{ 
+	return &RestaurantService{conn: conn, campus: campus, now: time.Now}	
 }
 
 func (s* RestaurantService) GetAllRestaurants(ctx context.Context) ([]Restaurant, error){
@@ -61,7 +67,13 @@ func (s* RestaurantService) GetAllRestaurants(ctx context.Context) ([]Restaurant
 
 		restaurants = append(restaurants, r)
 	}
+	if err := rows.Err(); err != nil{
+		return nil, err
+	}
 
+	if err := s.setOpenStatus(ctx, restaurants); err != nil{
+		return nil, err
+	}
 	return restaurants, nil
 }
 
@@ -79,42 +91,12 @@ func (s *RestaurantService) GetRestaurantsByID(ctx context.Context, restaurantID
 	if err != nil{
 		return nil, err	
 	}
-		
-	return &r, nil
-}
-
-func (s * RestaurantService) GetRestaurantMenu (ctx context.Context, restaurantID uuid.UUID)([]FoodItem, error){
-	var foodItems []FoodItem
-	query := `
-	SELECT food_id, restaurant_id, category_id, food_name, price, availability 
-	FROM food 
-	WHERE restaurant_id = $1 AND availability = true 
-	ORDER BY food_name
-	`
-	rows, err := s.conn.Query(ctx, query, restaurantID,)
-
-	if err != nil{
-		return nil, err
-	}
-	
-	defer rows.Close()
-	for rows.Next(){
-		var item FoodItem
-		err := rows.Scan(&item.FoodID, &item.RestaurantID, &item.CategoryID, &item.FoodName, &item.Price, &item.Availability,)
 
-		if err != nil{
-			return nil, err				
-		}
-
-		foodItems= append(foodItems, item) 
-	}
-
-	if err := rows.Err(); err != nil{
+	restaurants := []Restaurant{r}
+	if err := s.setOpenStatus(ctx, restaurants); err != nil{
 		return nil, err
 	}
-
-	log.Print("problem with function not true")
-	return foodItems, nil
+	return &restaurants[0], nil
 }
 
 func (s * RestaurantService) GetFoodItemByID(ctx context.Context, foodID uuid.UUID) (*FoodItem, error){
@@ -142,7 +124,7 @@ func (s * RestaurantService) GetFoodItemBYIDs(ctx context.Context, foodIDs[] uui
 	query := `
 		SELECT food_id, restaurant_id, category_id, food_name, price, availability 
 		FROM food 
-		WHERE food_id = ANY($1)
+		WHERE food_id = ANY($1) AND archived_at IS NULL
 	`	
 	rows, err := s.conn.Query(ctx, query, foodIDs)
 	
@@ -0,0 +1,74 @@
+package users
+
+import (
+	"campusDoordash/internal/auth"
+	"errors"
+	"log"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+type UserHandlers struct {
+	service *UserService
+}
+
+func NewUserHandlers(service *UserService) *UserHandlers {
+	return &UserHandlers{service: service}
+}
+
+// GetMeHandler serves GET /api/me.
+func (h *UserHandlers) GetMeHandler(c *gin.Context) {
+	user, ok := identityFrom(c)
+	if !ok {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "invalid user"})
+		return
+	}
+
+	profile, err := h.service.GetProfile(c.Request.Context(), user)
+	if err != nil {
+		log.Printf("failed to fetch profile: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch profile"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"profile": profile})
+}
+
+// UpdateMeHandler serves PATCH /api/me.
+func (h *UserHandlers) UpdateMeHandler(c *gin.Context) {
+	user, ok := identityFrom(c)
+	if !ok {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "invalid user"})
+		return
+	}
+
+	var req UpdateProfileRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request body"})
+		return
+	}
+
+	profile, err := h.service.UpdateProfile(c.Request.Context(), user, req)
+	var validationErr *ValidationError
+	switch {
+	case errors.As(err, &validationErr):
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error(), "fields": validationErr.Fields})
+		return
+	case errors.Is(err, ErrNotADasher):
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+		return
+	case err != nil:
+		log.Printf("failed to update profile: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to update profile"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"profile": profile})
+}
+
+func identityFrom(c *gin.Context) (*auth.Identity, bool) {
+	v, _ := c.Get("user")
+	user, ok := v.(*auth.Identity)
+	return user, ok && user != nil
+}
@@ -0,0 +1,209 @@
+// Package users serves the signed in user's profile.
+package users
+
+import (
+	"campusDoordash/internal/auth"
+	"campusDoordash/internal/dashers"
+	"campusDoordash/internal/locations"
+	"context"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type PayoutStatus string
+
+const (
+	PayoutNotSetUp   PayoutStatus = "not_set_up"
+	PayoutPending    PayoutStatus = "pending"
+	PayoutActive     PayoutStatus = "active"
+	PayoutRestricted PayoutStatus = "restricted"
+)
+
+// ErrNotADasher is returned when someone without the dasher role tries to
+// update dasher-only fields.
+var ErrNotADasher = errors.New("only dashers can set transport_mode or vehicle")
+
+// Profile is the signed in user's profile. DefaultAddressID is their default
+// saved address, managed with the addresses under /api/me/addresses.
+type Profile struct {
+	UserID             uuid.UUID      `json:"user_id"`
+	Email              string         `json:"email"`
+	Name               *string        `json:"name"`
+	Phone              *string        `json:"phone"`
+	DefaultAddressID   *uuid.UUID     `json:"default_address_id"`
+	DietaryPreferences []string       `json:"dietary_preferences"`
+	Roles              auth.Roles     `json:"roles"`
+	Dasher             *DasherProfile `json:"dasher,omitempty"`
+	UpdatedAt          *time.Time     `json:"updated_at,omitempty"`
+}
+
+// DasherProfile is only present for users holding the dasher role.
+type DasherProfile struct {
+	TransportMode *dashers.TransportMode `json:"transport_mode"`
+	Vehicle       *string                `json:"vehicle"`
+	PayoutStatus  PayoutStatus           `json:"payout_status"`
+}
+
+// UpdateProfileRequest is a partial update: fields left out are unchanged and
+// an empty string clears a text field. DefaultAddressID must be one of the
+// user's saved addresses.
+type UpdateProfileRequest struct {
+	Name               *string                `json:"name"`
+	Phone              *string                `json:"phone"`
+	DefaultAddressID   *uuid.UUID             `json:"default_address_id"`
+	DietaryPreferences *[]string              `json:"dietary_preferences"`
+	TransportMode      *dashers.TransportMode `json:"transport_mode"`
+	Vehicle            *string                `json:"vehicle"`
+}
+
+type UserService struct {
+	conn      *pgxpool.Pool
+	locations *locations.LocationService
+}
+
+func NewUserService(conn *pgxpool.Pool, locations *locations.LocationService) *UserService {
+	return &UserService{conn: conn, locations: locations}
+}
+
+// GetProfile loads the profile for identity. Accounts without a users row
+// yet get an empty profile.
+func (s *UserService) GetProfile(ctx context.Context, identity *auth.Identity) (*Profile, error) {
+	p := Profile{
+		UserID:             identity.UserID,
+		Email:              identity.Email,
+		DietaryPreferences: []string{},
+		Roles:              identity.Roles,
+	}
+
+	var updatedAt time.Time
+	err := s.conn.QueryRow(ctx, `
+		SELECT name, phone, dietary_preferences, updated_at
+		FROM users
+		WHERE user_id = $1
+	`, identity.UserID).Scan(&p.Name, &p.Phone, &p.DietaryPreferences, &updatedAt)
+	switch {
+	case errors.Is(err, pgx.ErrNoRows):
+	case err != nil:
+		return nil, err
+	default:
+		p.UpdatedAt = &updatedAt
+	}
+
+	err = s.conn.QueryRow(ctx, `
+		SELECT address_id FROM saved_addresses WHERE user_id = $1 AND is_default
+	`, identity.UserID).Scan(&p.DefaultAddressID)
+	if err != nil && !errors.Is(err, pgx.ErrNoRows) {
+		return nil, err
+	}
+
+	if identity.Roles.Has(auth.RoleDasher) {
+		d := DasherProfile{PayoutStatus: PayoutNotSetUp}
+		err := s.conn.QueryRow(ctx, `
+			SELECT transport_mode, vehicle, payout_status FROM dashers WHERE dasher_id = $1
+		`, identity.UserID).Scan(&d.TransportMode, &d.Vehicle, &d.PayoutStatus)
+		if err != nil && !errors.Is(err, pgx.ErrNoRows) {
+			return nil, err
+		}
+		p.Dasher = &d
+	}
+
+	return &p, nil
+}
+
+// UpdateProfile validates req and applies it. It returns a *ValidationError
+// listing every bad field, or ErrNotADasher. Dashers approved before the
+// dashers table existed get their row created here.
+func (s *UserService) UpdateProfile(ctx context.Context, identity *auth.Identity, req UpdateProfileRequest) (*Profile, error) {
+	if err := req.normalize(); err != nil {
+		return nil, err
+	}
+	isDasher := identity.Roles.Has(auth.RoleDasher)
+	if !isDasher && (req.TransportMode != nil || req.Vehicle != nil) {
+		return nil, ErrNotADasher
+	}
+
+	current, err := s.GetProfile(ctx, identity)
+	if err != nil {
+		return nil, err
+	}
+	apply(&current.Name, req.Name)
+	apply(&current.Phone, req.Phone)
+	if req.DietaryPreferences != nil {
+		current.DietaryPreferences = *req.DietaryPreferences
+	}
+
+	tx, err := s.conn.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback(ctx)
+
+	_, err = tx.Exec(ctx, `
+		INSERT INTO users (user_id, email, name, phone, dietary_preferences, updated_at)
+		VALUES ($1, $2, $3, $4, $5, NOW())
+		ON CONFLICT (user_id) DO UPDATE
+		SET name = EXCLUDED.name, phone = EXCLUDED.phone,
+			dietary_preferences = EXCLUDED.dietary_preferences, updated_at = NOW()
+	`, identity.UserID, identity.Email, current.Name, current.Phone, current.DietaryPreferences)
+	if err != nil {
+		return nil, err
+	}
+
+	if req.DefaultAddressID != nil {
+		err := s.locations.SetDefaultAddressTx(ctx, tx, identity.UserID, *req.DefaultAddressID)
+		if errors.Is(err, locations.ErrAddressNotFound) {
+			return nil, &ValidationError{Fields: map[string]string{
+				"default_address_id": "must be one of your saved addresses",
+			}}
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if isDasher && (req.TransportMode != nil || req.Vehicle != nil) {
+		d := current.Dasher
+		if req.TransportMode != nil {
+			d.TransportMode = req.TransportMode
+		}
+		apply(&d.Vehicle, req.Vehicle)
+
+		tag, err := tx.Exec(ctx, `
+			UPDATE dashers SET transport_mode = $1, vehicle = $2 WHERE dasher_id = $3
+		`, d.TransportMode, d.Vehicle, identity.UserID)
+		if err != nil {
+			return nil, err
+		}
+		if tag.RowsAffected() == 0 {
+			_, err = tx.Exec(ctx, `
+				INSERT INTO dashers (dasher_id, email, transport_mode, vehicle) VALUES ($1, $2, $3, $4)
+			`, identity.UserID, identity.Email, d.TransportMode, d.Vehicle)
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return nil, err
+	}
+
+	return s.GetProfile(ctx, identity)
+}
+
+// apply sets *field to update, treating an empty update as clearing it.
+func apply(field **string, update *string) {
+	if update == nil {
+		return
+	}
+	if *update == "" {
+		*field = nil
+		return
+	}
+	v := *update
+	*field = &v
+}
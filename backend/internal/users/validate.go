@@ -0,0 +1,125 @@
+package users
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// DietaryPreferences are the values accepted in dietary_preferences.
+var DietaryPreferences = []string{
+	"vegetarian", "vegan", "pescatarian", "gluten_free", "dairy_free",
+	"nut_free", "halal", "kosher",
+}
+
+const (
+	maxNameLength    = 100
+	maxVehicleLength = 100
+)
+
+// ValidationError lists what is wrong with each field of a profile update.
+type ValidationError struct {
+	Fields map[string]string
+}
+
+func (e *ValidationError) Error() string {
+	return "invalid profile"
+}
+
+// normalize trims and checks every field that was sent, rewriting phone
+// numbers to digits with an optional leading + and dietary preferences to
+// a sorted list without duplicates.
+func (r *UpdateProfileRequest) normalize() error {
+	fields := map[string]string{}
+
+	trim := func(name string, v *string, max int) {
+		if v == nil {
+			return
+		}
+		*v = strings.TrimSpace(*v)
+		if len(*v) > max {
+			fields[name] = fmt.Sprintf("must be at most %d characters", max)
+		}
+	}
+	trim("name", r.Name, maxNameLength)
+	trim("vehicle", r.Vehicle, maxVehicleLength)
+
+	if r.Phone != nil && *r.Phone != "" {
+		phone, ok := normalizePhone(*r.Phone)
+		if !ok {
+			fields["phone"] = "must be a phone number with 10 to 15 digits"
+		}
+		*r.Phone = phone
+	}
+
+	if r.DietaryPreferences != nil {
+		prefs, bad := normalizePreferences(*r.DietaryPreferences)
+		if bad != "" {
+			fields["dietary_preferences"] = "unknown preference " + bad + ", expected one of " + strings.Join(DietaryPreferences, ", ")
+		}
+		*r.DietaryPreferences = prefs
+	}
+
+	if r.TransportMode != nil && !r.TransportMode.IsValid() {
+		fields["transport_mode"] = "must be one of walking, bike, scooter or car"
+	}
+
+	if len(fields) > 0 {
+		return &ValidationError{Fields: fields}
+	}
+	return nil
+}
+
+// normalizePhone keeps the digits of phone and a leading +. Ten digit
+// numbers without a country code are assumed to be US numbers.
+func normalizePhone(phone string) (string, bool) {
+	var digits strings.Builder
+	international := false
+	for i, r := range strings.TrimSpace(phone) {
+		switch {
+		case unicode.IsDigit(r):
+			digits.WriteRune(r)
+		case r == '+' && i == 0:
+			international = true
+		case r == ' ' || r == '-' || r == '.' || r == '(' || r == ')':
+		default:
+			return phone, false
+		}
+	}
+
+	d := digits.String()
+	if len(d) < 10 || len(d) > 15 {
+		return phone, false
+	}
+	if len(d) == 10 && !international {
+		d = "1" + d
+	}
+	return "+" + d, true
+}
+
+func normalizePreferences(prefs []string) ([]string, string) {
+	seen := map[string]bool{}
+	out := []string{}
+	for _, p := range prefs {
+		p = strings.ToLower(strings.TrimSpace(p))
+		if !isDietaryPreference(p) {
+			return prefs, p
+		}
+		if !seen[p] {
+			seen[p] = true
+			out = append(out, p)
+		}
+	}
+	sort.Strings(out)
+	return out, ""
+}
+
+func isDietaryPreference(p string) bool {
+	for _, known := range DietaryPreferences {
+		if p == known {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,18 @@
+-- Append-only history of every order status change.
+CREATE TABLE IF NOT EXISTS public.order_events (
+    id          BIGSERIAL PRIMARY KEY,
+    order_id    UUID NOT NULL REFERENCES public.orders(id),
+    actor_id    UUID,
+    actor_role  TEXT NOT NULL,
+    from_status TEXT,
+    to_status   TEXT NOT NULL,
+    reason      TEXT,
+    created_at  TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS order_events_order_id_idx
+    ON public.order_events (order_id, created_at);
+
+-- Events are never edited once written (017 also stops deletes).
+CREATE OR REPLACE RULE order_events_no_update AS
+    ON UPDATE TO public.order_events DO INSTEAD NOTHING;
@@ -0,0 +1,4 @@
+-- Store the full fee breakdown that was charged through Stripe.
+ALTER TABLE public.orders
+    ADD COLUMN IF NOT EXISTS tax          NUMERIC(10, 2) NOT NULL DEFAULT 0,
+    ADD COLUMN IF NOT EXISTS platform_fee NUMERIC(10, 2) NOT NULL DEFAULT 0;
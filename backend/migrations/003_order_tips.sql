@@ -0,0 +1,17 @@
+-- Tips go entirely to the dasher. orders.tip is the total tip that has been paid.
+ALTER TABLE public.orders
+    ADD COLUMN IF NOT EXISTS tip NUMERIC(10, 2) NOT NULL DEFAULT 0;
+
+-- Extra tips added after delivery are charged with their own PaymentIntent.
+CREATE TABLE IF NOT EXISTS public.order_tip_charges (
+    id                UUID PRIMARY KEY,
+    order_id          UUID NOT NULL REFERENCES public.orders(id),
+    amount            NUMERIC(10, 2) NOT NULL,
+    payment_intent_id TEXT NOT NULL UNIQUE,
+    status            TEXT NOT NULL DEFAULT 'pending',
+    created_at        TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    paid_at           TIMESTAMPTZ
+);
+
+CREATE INDEX IF NOT EXISTS order_tip_charges_order_id_idx
+    ON public.order_tip_charges (order_id);
@@ -0,0 +1,6 @@
+-- Cancellation and refund state. refunded_amount mirrors Stripe's
+-- charge.amount_refunded and is updated by the charge.refunded webhook.
+ALTER TABLE public.orders
+    ADD COLUMN IF NOT EXISTS cancelled_at    TIMESTAMPTZ,
+    ADD COLUMN IF NOT EXISTS refund_status   TEXT NOT NULL DEFAULT 'none',
+    ADD COLUMN IF NOT EXISTS refunded_amount NUMERIC(10, 2) NOT NULL DEFAULT 0;
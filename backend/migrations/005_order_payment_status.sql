@@ -0,0 +1,3 @@
+-- Latest known state of the order's Stripe PaymentIntent, kept in step by the webhook.
+ALTER TABLE public.orders
+    ADD COLUMN IF NOT EXISTS payment_status TEXT NOT NULL DEFAULT 'requires_payment';
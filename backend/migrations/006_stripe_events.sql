@@ -0,0 +1,22 @@
+-- Every Stripe webhook event received, so each one is applied exactly once.
+CREATE TABLE IF NOT EXISTS public.stripe_events (
+    id                TEXT PRIMARY KEY,
+    type              TEXT NOT NULL,
+    object_id         TEXT,
+    stripe_created_at TIMESTAMPTZ NOT NULL,
+    status            TEXT NOT NULL,
+    error             TEXT,
+    attempts          INT NOT NULL DEFAULT 1,
+    received_at       TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    claimed_at        TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    claim_token       UUID,
+    processed_at      TIMESTAMPTZ
+);
+
+CREATE INDEX IF NOT EXISTS stripe_events_received_at_idx
+    ON public.stripe_events (received_at DESC);
+
+-- When the Stripe event behind orders.payment_status was created, so an
+-- older event delivered late can't overwrite a newer payment state.
+ALTER TABLE public.orders
+    ADD COLUMN IF NOT EXISTS payment_event_at TIMESTAMPTZ;
@@ -0,0 +1,13 @@
+-- Idempotency-Key headers seen on POST /api/orders, scoped per customer.
+-- order_id is picked when the key is first seen so retries reuse it.
+CREATE TABLE IF NOT EXISTS public.order_idempotency_keys (
+    customer_id   UUID NOT NULL,
+    key           TEXT NOT NULL,
+    request_hash  TEXT NOT NULL,
+    order_id      UUID NOT NULL,
+    client_secret TEXT,
+    created_at    TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    locked_at     TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    completed_at  TIMESTAMPTZ,
+    PRIMARY KEY (customer_id, key)
+);
@@ -0,0 +1,21 @@
+-- Roles a user holds. restaurant_staff rows are scoped to one restaurant;
+-- every other role has restaurant_id NULL. A user can hold several roles.
+CREATE TABLE IF NOT EXISTS public.user_roles (
+    user_id       UUID NOT NULL,
+    role          TEXT NOT NULL CHECK (role IN ('customer', 'dasher', 'restaurant_staff', 'admin')),
+    restaurant_id UUID REFERENCES public.restaurants(restaurant_id),
+    created_at    TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    CHECK ((role = 'restaurant_staff') = (restaurant_id IS NOT NULL))
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS user_roles_unique_idx
+    ON public.user_roles (user_id, role, COALESCE(restaurant_id, '00000000-0000-0000-0000-000000000000'));
+
+-- Existing accounts keep the access they had through the users and dashers tables.
+INSERT INTO public.user_roles (user_id, role)
+SELECT user_id, 'customer' FROM public.users
+ON CONFLICT DO NOTHING;
+
+INSERT INTO public.user_roles (user_id, role)
+SELECT dasher_id, 'dasher' FROM public.dashers
+ON CONFLICT DO NOTHING;
@@ -0,0 +1,24 @@
+-- One application per user wanting to deliver. Only approved applicants hold
+-- the dasher role; suspending a dasher takes the role away again.
+CREATE TABLE IF NOT EXISTS public.dasher_applications (
+    user_id        UUID PRIMARY KEY,
+    email          TEXT NOT NULL,
+    student_id     TEXT,
+    availability   TEXT,
+    transport_mode TEXT CHECK (transport_mode IN ('walking', 'bike', 'scooter', 'car')),
+    status         TEXT NOT NULL DEFAULT 'pending_review'
+                   CHECK (status IN ('pending_review', 'approved', 'rejected', 'suspended')),
+    review_reason  TEXT,
+    reviewed_by    UUID,
+    reviewed_at    TIMESTAMPTZ,
+    created_at     TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at     TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS dasher_applications_status_idx
+    ON public.dasher_applications (status, created_at);
+
+-- Dashers who signed up before applications existed are treated as approved.
+INSERT INTO public.dasher_applications (user_id, email, status, reviewed_at)
+SELECT dasher_id, email, 'approved', NOW() FROM public.dashers
+ON CONFLICT DO NOTHING;
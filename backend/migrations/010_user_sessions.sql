@@ -0,0 +1,10 @@
+-- Session state kept on our side of the identity provider. Access tokens
+-- issued before revoked_at are rejected, and refresh tokens only keep working
+-- if the user signed in again after the revocation.
+CREATE TABLE IF NOT EXISTS public.user_sessions (
+    user_id       UUID PRIMARY KEY,
+    signed_in_at  TIMESTAMPTZ,
+    revoked_at    TIMESTAMPTZ,
+    revoked_by    UUID,
+    revoke_reason TEXT
+);
@@ -0,0 +1,20 @@
+-- Profile details for GET/PATCH /api/me.
+ALTER TABLE public.users
+    ADD COLUMN IF NOT EXISTS name                      TEXT,
+    ADD COLUMN IF NOT EXISTS phone                     TEXT,
+    ADD COLUMN IF NOT EXISTS default_delivery_location TEXT,
+    ADD COLUMN IF NOT EXISTS dietary_preferences       TEXT[] NOT NULL DEFAULT '{}',
+    ADD COLUMN IF NOT EXISTS updated_at                TIMESTAMPTZ NOT NULL DEFAULT NOW();
+
+-- payout_status tracks the dasher's Stripe payout setup, which is managed
+-- outside this API.
+ALTER TABLE public.dashers
+    ADD COLUMN IF NOT EXISTS transport_mode TEXT CHECK (transport_mode IN ('walking', 'bike', 'scooter', 'car')),
+    ADD COLUMN IF NOT EXISTS vehicle        TEXT,
+    ADD COLUMN IF NOT EXISTS payout_status  TEXT NOT NULL DEFAULT 'not_set_up'
+        CHECK (payout_status IN ('not_set_up', 'pending', 'active', 'restricted'));
+
+UPDATE public.dashers d
+SET transport_mode = a.transport_mode
+FROM public.dasher_applications a
+WHERE a.user_id = d.dasher_id AND d.transport_mode IS NULL;
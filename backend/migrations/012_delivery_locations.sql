@@ -0,0 +1,52 @@
+-- Campus buildings come from the DineOnCampus locations scrape
+-- (data_retrieval/ExternalAPIs/alllocations_json.py); restaurants.location_id
+-- already points at them.
+CREATE TABLE IF NOT EXISTS public.locations (
+    location_id   UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    location_name TEXT NOT NULL,
+    location_type TEXT
+);
+
+ALTER TABLE public.locations
+    ADD COLUMN IF NOT EXISTS latitude    DOUBLE PRECISION,
+    ADD COLUMN IF NOT EXISTS longitude   DOUBLE PRECISION,
+    ADD COLUMN IF NOT EXISTS deliverable BOOLEAN NOT NULL DEFAULT TRUE;
+
+-- Rooms, lobbies and other spots inside a building where dashers hand off orders.
+CREATE TABLE IF NOT EXISTS public.drop_off_points (
+    drop_off_point_id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    location_id       UUID NOT NULL REFERENCES public.locations (location_id) ON DELETE CASCADE,
+    name              TEXT NOT NULL,
+    kind              TEXT NOT NULL DEFAULT 'entrance'
+        CHECK (kind IN ('entrance', 'lobby', 'front_desk', 'room', 'locker')),
+    latitude          DOUBLE PRECISION,
+    longitude         DOUBLE PRECISION,
+    instructions      TEXT,
+    active            BOOLEAN NOT NULL DEFAULT TRUE
+);
+
+CREATE INDEX IF NOT EXISTS drop_off_points_location_idx ON public.drop_off_points (location_id);
+
+CREATE TABLE IF NOT EXISTS public.saved_addresses (
+    address_id        UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    user_id           UUID NOT NULL,
+    label             TEXT NOT NULL,
+    location_id       UUID NOT NULL REFERENCES public.locations (location_id),
+    drop_off_point_id UUID REFERENCES public.drop_off_points (drop_off_point_id),
+    room              TEXT,
+    instructions      TEXT,
+    is_default        BOOLEAN NOT NULL DEFAULT FALSE,
+    created_at        TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at        TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS saved_addresses_user_idx ON public.saved_addresses (user_id);
+CREATE UNIQUE INDEX IF NOT EXISTS saved_addresses_one_default
+    ON public.saved_addresses (user_id) WHERE is_default;
+
+-- delivery_address keeps the rendered label so existing clients still have
+-- something to show; older orders have no structured location.
+ALTER TABLE public.orders
+    ADD COLUMN IF NOT EXISTS delivery_location_id       UUID REFERENCES public.locations (location_id),
+    ADD COLUMN IF NOT EXISTS delivery_drop_off_point_id UUID REFERENCES public.drop_off_points (drop_off_point_id),
+    ADD COLUMN IF NOT EXISTS delivery_room              TEXT;
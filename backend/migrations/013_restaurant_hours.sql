@@ -0,0 +1,33 @@
+-- Weekly opening hours, evaluated in the campus time zone (CAMPUS_TIMEZONE).
+-- day_of_week follows EXTRACT(DOW): 0 is Sunday. A day can have several
+-- rows (e.g. lunch and dinner). closes_at at or before opens_at means the
+-- restaurant closes after midnight, on the next day.
+-- Restaurants without any rows here are open around the clock, apart from
+-- exceptions, so listing a restaurant doesn't require entering its hours.
+CREATE TABLE IF NOT EXISTS public.restaurant_hours (
+    restaurant_id UUID NOT NULL REFERENCES public.restaurants (restaurant_id) ON DELETE CASCADE,
+    day_of_week   SMALLINT NOT NULL CHECK (day_of_week BETWEEN 0 AND 6),
+    opens_at      TIME NOT NULL,
+    closes_at     TIME NOT NULL,
+    PRIMARY KEY (restaurant_id, day_of_week, opens_at)
+);
+
+-- Days that don't follow the weekly schedule: holidays, academic breaks and
+-- special hours. A NULL restaurant_id applies to every restaurant on campus.
+-- When closed is false, opens_at/closes_at replace the weekly hours for
+-- each day in the range.
+CREATE TABLE IF NOT EXISTS public.restaurant_hour_exceptions (
+    exception_id  UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    restaurant_id UUID REFERENCES public.restaurants (restaurant_id) ON DELETE CASCADE,
+    starts_on     DATE NOT NULL,
+    ends_on       DATE NOT NULL,
+    closed        BOOLEAN NOT NULL DEFAULT TRUE,
+    opens_at      TIME,
+    closes_at     TIME,
+    reason        TEXT,
+    CHECK (ends_on >= starts_on),
+    CHECK (closed OR (opens_at IS NOT NULL AND closes_at IS NOT NULL))
+);
+
+CREATE INDEX IF NOT EXISTS restaurant_hour_exceptions_dates_idx
+    ON public.restaurant_hour_exceptions (ends_on, starts_on);
@@ -0,0 +1,39 @@
+-- Structured menus: categories in display order, and modifier groups such as
+-- size or bread type whose options change an item's price.
+CREATE TABLE IF NOT EXISTS public.categories (
+    category_id   UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    category_name TEXT NOT NULL
+);
+
+ALTER TABLE public.categories
+    ADD COLUMN IF NOT EXISTS restaurant_id UUID REFERENCES public.restaurants (restaurant_id) ON DELETE CASCADE,
+    ADD COLUMN IF NOT EXISTS display_order INT NOT NULL DEFAULT 0;
+
+ALTER TABLE public.food
+    ADD COLUMN IF NOT EXISTS description   TEXT,
+    ADD COLUMN IF NOT EXISTS display_order INT NOT NULL DEFAULT 0;
+
+-- A group is required when min_selections is at least 1.
+CREATE TABLE IF NOT EXISTS public.modifier_groups (
+    modifier_group_id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    food_id           UUID NOT NULL REFERENCES public.food (food_id) ON DELETE CASCADE,
+    name              TEXT NOT NULL,
+    min_selections    INT NOT NULL DEFAULT 0 CHECK (min_selections >= 0),
+    max_selections    INT NOT NULL DEFAULT 1,
+    display_order     INT NOT NULL DEFAULT 0,
+    CHECK (max_selections >= 1 AND max_selections >= min_selections)
+);
+
+CREATE INDEX IF NOT EXISTS modifier_groups_food_idx ON public.modifier_groups (food_id);
+
+-- price_delta is in dollars like food.price and may be negative.
+CREATE TABLE IF NOT EXISTS public.modifier_options (
+    modifier_option_id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    modifier_group_id  UUID NOT NULL REFERENCES public.modifier_groups (modifier_group_id) ON DELETE CASCADE,
+    name               TEXT NOT NULL,
+    price_delta        NUMERIC(10, 2) NOT NULL DEFAULT 0,
+    available          BOOLEAN NOT NULL DEFAULT TRUE,
+    display_order      INT NOT NULL DEFAULT 0
+);
+
+CREATE INDEX IF NOT EXISTS modifier_options_group_idx ON public.modifier_options (modifier_group_id);
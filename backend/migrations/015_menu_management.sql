@@ -0,0 +1,25 @@
+-- Menu editing by restaurant staff. Archived items and categories are hidden
+-- from menus and can't be ordered, but stay in the database for old orders.
+ALTER TABLE public.food
+    ADD COLUMN IF NOT EXISTS archived_at TIMESTAMPTZ,
+    ADD COLUMN IF NOT EXISTS updated_at  TIMESTAMPTZ NOT NULL DEFAULT NOW();
+
+ALTER TABLE public.categories
+    ADD COLUMN IF NOT EXISTS archived_at TIMESTAMPTZ,
+    ADD COLUMN IF NOT EXISTS updated_at  TIMESTAMPTZ NOT NULL DEFAULT NOW();
+
+-- One row per change to a food item or category. changes maps each field
+-- that changed to its old and new value: {"price": {"from": 4.5, "to": 4.75}}.
+CREATE TABLE IF NOT EXISTS public.menu_changes (
+    id            BIGSERIAL PRIMARY KEY,
+    restaurant_id UUID NOT NULL REFERENCES public.restaurants (restaurant_id) ON DELETE CASCADE,
+    entity_type   TEXT NOT NULL CHECK (entity_type IN ('food', 'category')),
+    entity_id     UUID NOT NULL,
+    action        TEXT NOT NULL CHECK (action IN ('create', 'update', 'archive', 'reorder', 'availability', 'price')),
+    changes       JSONB NOT NULL DEFAULT '{}',
+    changed_by    UUID NOT NULL,
+    changed_at    TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS menu_changes_restaurant_idx ON public.menu_changes (restaurant_id, changed_at DESC);
+CREATE INDEX IF NOT EXISTS menu_changes_entity_idx ON public.menu_changes (entity_id, changed_at DESC);
@@ -0,0 +1,11 @@
+-- IDs from DineOnCampus for rows created by cmd/menuimport. The importer
+-- derives each row's UUID from this ID, so re-running it updates rows in place.
+ALTER TABLE public.locations   ADD COLUMN IF NOT EXISTS external_id TEXT;
+ALTER TABLE public.restaurants ADD COLUMN IF NOT EXISTS external_id TEXT;
+ALTER TABLE public.categories  ADD COLUMN IF NOT EXISTS external_id TEXT;
+ALTER TABLE public.food        ADD COLUMN IF NOT EXISTS external_id TEXT;
+
+CREATE UNIQUE INDEX IF NOT EXISTS locations_external_id_idx   ON public.locations (external_id)   WHERE external_id IS NOT NULL;
+CREATE UNIQUE INDEX IF NOT EXISTS restaurants_external_id_idx ON public.restaurants (external_id) WHERE external_id IS NOT NULL;
+CREATE UNIQUE INDEX IF NOT EXISTS categories_external_id_idx  ON public.categories (external_id)  WHERE external_id IS NOT NULL;
+CREATE UNIQUE INDEX IF NOT EXISTS food_external_id_idx        ON public.food (external_id)        WHERE external_id IS NOT NULL;
@@ -0,0 +1,3 @@
+-- order_events is append-only: 001 stops updates, this stops deletes too.
+CREATE OR REPLACE RULE order_events_no_delete AS
+    ON DELETE TO public.order_events DO INSTEAD NOTHING;
@@ -0,0 +1,3 @@
+-- Replayed order requests fetch the client secret from Stripe again, so it
+-- no longer needs to be (and shouldn't be) stored.
+ALTER TABLE public.order_idempotency_keys DROP COLUMN IF EXISTS client_secret;
@@ -0,0 +1,12 @@
+-- Sessions started through /auth/login, keyed by the session_id claim in
+-- their access tokens. Once a user's sessions are revoked, only sessions
+-- that started afterwards are accepted, so signing in again on one device
+-- doesn't bring back the others. That replaces user_sessions.signed_in_at,
+-- which was per user.
+CREATE TABLE IF NOT EXISTS public.auth_sessions (
+    session_id  UUID PRIMARY KEY,
+    user_id     UUID NOT NULL,
+    started_at  TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+ALTER TABLE public.user_sessions DROP COLUMN IF EXISTS signed_in_at;
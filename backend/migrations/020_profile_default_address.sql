@@ -0,0 +1,4 @@
+-- The profile's default delivery location is now the user's default saved
+-- address (saved_addresses.is_default). The free-text column duplicated it
+-- and was never used for orders, so it is dropped.
+ALTER TABLE public.users DROP COLUMN IF EXISTS default_delivery_location;